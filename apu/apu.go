@@ -3,6 +3,7 @@ package apu
 import (
 	"errors"
 
+	"github.com/maxpoletaev/dendy/consts"
 	"github.com/maxpoletaev/dendy/internal/binario"
 )
 
@@ -11,6 +12,31 @@ var lengthTable = []byte{
 	12, 16, 24, 18, 48, 20, 96, 22, 192, 24, 72, 26, 16, 28, 32, 30,
 }
 
+// frameSteps are the APU cycles at which the frame counter clocks the envelopes
+// (all four steps) and the length counters (second and fourth steps), followed by
+// the length of the sequence. One row for each of the two frame counter modes.
+type frameSteps [2][5]uint64
+
+var (
+	frameStepsNTSC = frameSteps{
+		{3728, 7456, 11185, 14914, 14915},
+		{3728, 7456, 11185, 18640, 18641},
+	}
+	frameStepsPAL = frameSteps{
+		{4156, 8313, 12469, 16626, 16627},
+		{4156, 8313, 12469, 20782, 20783},
+	}
+)
+
+// Expansion is implemented by cartridges with an additional sound chip, which
+// output is mixed with the APU channels.
+type Expansion interface {
+	// TickAudio advances the sound chip by one CPU cycle.
+	TickAudio()
+	// AudioOutput returns the current output level of the sound chip.
+	AudioOutput() float32
+}
+
 type APU struct {
 	Enabled    bool
 	PendingIRQ bool
@@ -25,18 +51,45 @@ type APU struct {
 	triangle triangle
 	filters  []*filter
 
+	expansion Expansion
+
 	irqDisable bool
 	frameIRQ   bool
+	frameSteps *frameSteps
 }
 
 func New() *APU {
-	return &APU{
+	a := &APU{
 		Enabled: true,
 		filters: []*filter{
 			highPassFilter(44100.0, 90.0),
 			lowPassFilter(44100.0, 14000.0),
 		},
 	}
+
+	a.SetRegion(consts.RegionNTSC)
+
+	return a
+}
+
+// SetRegion selects the frame counter and the noise/DMC period tables of the
+// given region. Dendy uses an NTSC-compatible APU, so it gets the NTSC tables.
+func (a *APU) SetRegion(region consts.Region) {
+	switch region {
+	case consts.RegionPAL:
+		a.frameSteps = &frameStepsPAL
+		a.noise.periods = &noiseTablePAL
+		a.dmc.periods = &dmcTimerTablePAL
+	default:
+		a.frameSteps = &frameStepsNTSC
+		a.noise.periods = &noiseTableNTSC
+		a.dmc.periods = &dmcTimerTableNTSC
+	}
+}
+
+// SetExpansion connects the cartridge sound chip to the APU mixer.
+func (a *APU) SetExpansion(exp Expansion) {
+	a.expansion = exp
 }
 
 func (a *APU) Reset() {
@@ -130,6 +183,10 @@ func (a *APU) Output() float32 {
 	d := a.dmc.output()
 
 	out := a.mix(p1, p2, t, n, d)
+	if a.expansion != nil {
+		out += a.expansion.AudioOutput()
+	}
+
 	for _, f := range a.filters {
 		out = f.do(out)
 	}
@@ -145,20 +202,16 @@ func (a *APU) Tick() {
 	// Triangle is clocked at CPU speed.
 	a.triangle.tickTimer()
 
+	if a.expansion != nil {
+		a.expansion.TickAudio()
+	}
+
 	// Everything else is clocked at half CPU speed.
 	if a.cycle%2 == 0 {
-		var quarterFrame, halfFrame bool
-		var maxFrame uint64
-
-		if a.mode == 0 {
-			quarterFrame = a.frame == 3728 || a.frame == 7456 || a.frame == 11185 || a.frame == 14914
-			halfFrame = a.frame == 7456 || a.frame == 14914
-			maxFrame = 14915
-		} else {
-			quarterFrame = a.frame == 3728 || a.frame == 7456 || a.frame == 11185 || a.frame == 18640
-			halfFrame = a.frame == 7456 || a.frame == 18640
-			maxFrame = 18641
-		}
+		steps := &a.frameSteps[a.mode]
+		quarterFrame := a.frame == steps[0] || a.frame == steps[1] || a.frame == steps[2] || a.frame == steps[3]
+		halfFrame := a.frame == steps[1] || a.frame == steps[3]
+		maxFrame := steps[4]
 
 		if quarterFrame {
 			a.pulse1.tickEnvelope()
@@ -6,10 +6,14 @@ import (
 	"github.com/maxpoletaev/dendy/internal/binario"
 )
 
-var dmcTimerTable = []uint16{
+var dmcTimerTableNTSC = [16]uint16{
 	214, 190, 170, 160, 143, 127, 113, 107, 95, 80, 71, 64, 53, 42, 36, 27,
 }
 
+var dmcTimerTablePAL = [16]uint16{
+	199, 177, 158, 149, 138, 118, 105, 99, 88, 74, 66, 59, 49, 39, 33, 25,
+}
+
 type dmc struct {
 	enabled    bool
 	loop       bool
@@ -30,6 +34,7 @@ type dmc struct {
 	isEmpty  bool
 	isSilent bool
 
+	periods     *[16]uint16
 	dmaCallback func(addr uint16) byte
 }
 
@@ -57,7 +62,7 @@ func (d *dmc) reset() {
 func (d *dmc) write(addr uint16, value byte) {
 	switch addr {
 	case 0x4010:
-		d.timerLoad = dmcTimerTable[value&0b1111]
+		d.timerLoad = d.periods[value&0b1111]
 		d.irqEnabled = (value>>7)&1 != 0
 		d.loop = (value>>6)&1 != 0
 
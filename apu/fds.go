@@ -0,0 +1,329 @@
+package apu
+
+import (
+	"errors"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// fdsModSteps is how the modulation counter changes for each value of the
+// modulation table. The value 4 resets the counter instead.
+var fdsModSteps = [8]int8{0, 1, 2, 4, 0, -4, -2, -1}
+
+// fdsMasterVolumes is the output scale for the master volume setting of $4089.
+var fdsMasterVolumes = [4]float32{2.0 / 2, 2.0 / 3, 2.0 / 4, 2.0 / 5}
+
+type fdsEnvelope struct {
+	speed    uint8
+	gain     uint8 // 0-32
+	increase bool
+	disabled bool
+	timer    uint32
+}
+
+func (e *fdsEnvelope) write(value byte, master uint8) {
+	e.speed = value & 0x3F
+	e.increase = value&0x40 != 0
+	e.disabled = value&0x80 != 0
+
+	if e.disabled {
+		e.gain = e.speed
+	}
+
+	e.reload(master)
+}
+
+func (e *fdsEnvelope) reload(master uint8) {
+	e.timer = 8 * (uint32(e.speed) + 1) * uint32(master)
+}
+
+func (e *fdsEnvelope) tick(master uint8) {
+	if e.disabled || master == 0 {
+		return
+	}
+
+	if e.timer > 0 {
+		e.timer--
+	}
+
+	if e.timer > 0 {
+		return
+	}
+
+	e.reload(master)
+
+	if e.increase && e.gain < 32 {
+		e.gain++
+	} else if !e.increase && e.gain > 0 {
+		e.gain--
+	}
+}
+
+func (e *fdsEnvelope) saveState(w *binario.Writer) error {
+	return errors.Join(
+		w.WriteUint8(e.speed),
+		w.WriteUint8(e.gain),
+		w.WriteBool(e.increase),
+		w.WriteBool(e.disabled),
+		w.WriteUint32(e.timer),
+	)
+}
+
+func (e *fdsEnvelope) loadState(r *binario.Reader) error {
+	return errors.Join(
+		r.ReadUint8To(&e.speed),
+		r.ReadUint8To(&e.gain),
+		r.ReadBoolTo(&e.increase),
+		r.ReadBoolTo(&e.disabled),
+		r.ReadUint32To(&e.timer),
+	)
+}
+
+// FDSAudio is the sound channel of the Famicom Disk System RAM adapter. It
+// plays a single 64-step wavetable, which pitch can be modulated by another
+// table, and both have their own volume envelopes.
+// https://www.nesdev.org/wiki/FDS_audio
+type FDSAudio struct {
+	wave      [64]byte // 6-bit samples
+	waveWrite bool
+	waveHalt  bool
+	wavePos   uint8
+	waveAcc   uint32
+	freq      uint16
+	output    uint8
+
+	modTable [64]byte // 3-bit values
+	modPos   uint8
+	modAcc   uint32
+	modFreq  uint16
+	modHalt  bool
+	modCount int8 // 7-bit signed
+
+	vol         fdsEnvelope
+	mod         fdsEnvelope
+	envHalt     bool
+	envSpeed    uint8
+	masterLevel uint8
+}
+
+func (f *FDSAudio) Reset() {
+	*f = FDSAudio{
+		envSpeed: 0xE8,
+		waveHalt: true,
+		modHalt:  true,
+	}
+}
+
+// Read handles reads from the wavetable ($4040-$407F) and the gain registers
+// ($4090, $4092).
+func (f *FDSAudio) Read(addr uint16) byte {
+	switch {
+	case addr >= 0x4040 && addr <= 0x407F:
+		if f.waveWrite {
+			return f.wave[addr-0x4040]
+		}
+		return f.wave[f.wavePos]
+	case addr == 0x4090:
+		return f.vol.gain | 0x40
+	case addr == 0x4092:
+		return f.mod.gain | 0x40
+	default:
+		return 0
+	}
+}
+
+// Write handles writes to the sound registers ($4040-$408A).
+func (f *FDSAudio) Write(addr uint16, value byte) {
+	switch {
+	case addr >= 0x4040 && addr <= 0x407F:
+		if f.waveWrite {
+			f.wave[addr-0x4040] = value & 0x3F
+		}
+	case addr == 0x4080:
+		f.vol.write(value, f.envSpeed)
+	case addr == 0x4082:
+		f.freq = f.freq&0x0F00 | uint16(value)
+	case addr == 0x4083:
+		f.freq = f.freq&0x00FF | uint16(value&0x0F)<<8
+		f.envHalt = value&0x40 != 0
+		f.waveHalt = value&0x80 != 0
+
+		if f.waveHalt {
+			f.wavePos = 0
+			f.waveAcc = 0
+		}
+
+		if f.envHalt {
+			f.vol.reload(f.envSpeed)
+			f.mod.reload(f.envSpeed)
+		}
+	case addr == 0x4084:
+		f.mod.write(value, f.envSpeed)
+	case addr == 0x4085:
+		f.modCount = int8(value<<1) >> 1
+	case addr == 0x4086:
+		f.modFreq = f.modFreq&0x0F00 | uint16(value)
+	case addr == 0x4087:
+		f.modFreq = f.modFreq&0x00FF | uint16(value&0x0F)<<8
+		f.modHalt = value&0x80 != 0
+
+		if f.modHalt {
+			f.modAcc = 0
+		}
+	case addr == 0x4088:
+		// The table is only writable while the modulator is halted, and each
+		// write fills two entries, pushing the position forward.
+		if f.modHalt {
+			f.modTable[f.modPos] = value & 0x07
+			f.modTable[(f.modPos+1)&0x3F] = value & 0x07
+			f.modPos = (f.modPos + 2) & 0x3F
+		}
+	case addr == 0x4089:
+		f.waveWrite = value&0x80 != 0
+		f.masterLevel = value & 0x03
+	case addr == 0x408A:
+		f.envSpeed = value
+		f.vol.reload(f.envSpeed)
+		f.mod.reload(f.envSpeed)
+	}
+}
+
+func (f *FDSAudio) tickModulator() {
+	if f.modHalt || f.modFreq == 0 {
+		return
+	}
+
+	if f.modAcc += uint32(f.modFreq); f.modAcc < 0x10000 {
+		return
+	}
+
+	f.modAcc &= 0xFFFF
+
+	step := f.modTable[f.modPos]
+	f.modPos = (f.modPos + 1) & 0x3F
+
+	if step == 4 {
+		f.modCount = 0
+	} else {
+		// Wrap around within the 7-bit range.
+		f.modCount = int8(byte(f.modCount+fdsModSteps[step])<<1) >> 1
+	}
+}
+
+// pitch returns the wave frequency, adjusted by the modulator, as described in
+// the nesdev wiki (which in turn follows the hardware behavior).
+func (f *FDSAudio) pitch() int32 {
+	temp := int32(f.modCount) * int32(f.mod.gain)
+	remainder := temp & 0x0F
+	temp >>= 4
+
+	if remainder > 0 && temp&0x80 == 0 {
+		if f.modCount < 0 {
+			temp--
+		} else {
+			temp += 2
+		}
+	}
+
+	if temp >= 192 {
+		temp -= 256
+	} else if temp < -64 {
+		temp += 256
+	}
+
+	temp *= int32(f.freq)
+	remainder = temp & 0x3F
+	temp >>= 6
+
+	if remainder >= 32 {
+		temp++
+	}
+
+	return int32(f.freq) + temp
+}
+
+// Tick advances the sound channel by one CPU cycle.
+func (f *FDSAudio) Tick() {
+	if !f.envHalt && !f.waveHalt {
+		f.vol.tick(f.envSpeed)
+		f.mod.tick(f.envSpeed)
+	}
+
+	f.tickModulator()
+
+	if f.waveHalt {
+		return
+	}
+
+	if pitch := f.pitch(); pitch > 0 {
+		if f.waveAcc += uint32(pitch); f.waveAcc >= 0x10000 {
+			f.waveAcc &= 0xFFFF
+			f.wavePos = (f.wavePos + 1) & 0x3F
+		}
+	}
+
+	// The output is held while the wavetable is being written.
+	if !f.waveWrite {
+		f.output = f.wave[f.wavePos]
+	}
+}
+
+// Output returns the current output level, on the same scale as the APU.
+func (f *FDSAudio) Output() float32 {
+	gain := min(f.vol.gain, 32)
+	level := float32(f.output) * float32(gain) / (63 * 32)
+
+	return 0.25 * level * fdsMasterVolumes[f.masterLevel]
+}
+
+func (f *FDSAudio) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		w.WriteByteSlice(f.wave[:]),
+		w.WriteBool(f.waveWrite),
+		w.WriteBool(f.waveHalt),
+		w.WriteUint8(f.wavePos),
+		w.WriteUint32(f.waveAcc),
+		w.WriteUint16(f.freq),
+		w.WriteUint8(f.output),
+		w.WriteByteSlice(f.modTable[:]),
+		w.WriteUint8(f.modPos),
+		w.WriteUint32(f.modAcc),
+		w.WriteUint16(f.modFreq),
+		w.WriteBool(f.modHalt),
+		w.WriteUint8(uint8(f.modCount)),
+		f.vol.saveState(w),
+		f.mod.saveState(w),
+		w.WriteBool(f.envHalt),
+		w.WriteUint8(f.envSpeed),
+		w.WriteUint8(f.masterLevel),
+	)
+}
+
+func (f *FDSAudio) LoadState(r *binario.Reader) error {
+	var modCount uint8
+
+	err := errors.Join(
+		r.ReadByteSliceTo(f.wave[:]),
+		r.ReadBoolTo(&f.waveWrite),
+		r.ReadBoolTo(&f.waveHalt),
+		r.ReadUint8To(&f.wavePos),
+		r.ReadUint32To(&f.waveAcc),
+		r.ReadUint16To(&f.freq),
+		r.ReadUint8To(&f.output),
+		r.ReadByteSliceTo(f.modTable[:]),
+		r.ReadUint8To(&f.modPos),
+		r.ReadUint32To(&f.modAcc),
+		r.ReadUint16To(&f.modFreq),
+		r.ReadBoolTo(&f.modHalt),
+		r.ReadUint8To(&modCount),
+		f.vol.loadState(r),
+		f.mod.loadState(r),
+		r.ReadBoolTo(&f.envHalt),
+		r.ReadUint8To(&f.envSpeed),
+		r.ReadUint8To(&f.masterLevel),
+	)
+
+	f.modCount = int8(modCount)
+
+	return err
+}
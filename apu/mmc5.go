@@ -0,0 +1,121 @@
+package apu
+
+import (
+	"errors"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// mmc5FrameCycles is the period of the MMC5 frame counter in CPU cycles. Unlike
+// the APU, it clocks envelopes and length counters at the same fixed rate (240 Hz).
+const mmc5FrameCycles = 7457
+
+// MMC5Audio is the sound part of the MMC5 mapper. It has two pulse channels,
+// identical to the APU ones except for the missing sweep unit, and a raw 8-bit
+// PCM channel. https://www.nesdev.org/wiki/MMC5_audio
+type MMC5Audio struct {
+	pulse1  square
+	pulse2  square
+	pcm     uint8
+	pcmRead bool
+	cycle   uint64
+}
+
+func (m *MMC5Audio) Reset() {
+	m.pulse1.reset()
+	m.pulse2.reset()
+	m.pcm = 0
+	m.pcmRead = false
+	m.cycle = 0
+}
+
+// Read handles reads from the status register ($5015).
+func (m *MMC5Audio) Read(addr uint16) (status byte) {
+	if addr == 0x5015 {
+		if m.pulse1.length > 0 {
+			status |= 1 << 0
+		}
+
+		if m.pulse2.length > 0 {
+			status |= 1 << 1
+		}
+	}
+
+	return status
+}
+
+// Write handles writes to the sound registers ($5000-$5015).
+func (m *MMC5Audio) Write(addr uint16, value byte) {
+	switch {
+	case addr >= 0x5000 && addr <= 0x5003:
+		if addr != 0x5001 { // no sweep
+			m.pulse1.write(addr, value)
+		}
+	case addr >= 0x5004 && addr <= 0x5007:
+		if addr != 0x5005 {
+			m.pulse2.write(addr, value)
+		}
+	case addr == 0x5010:
+		m.pcmRead = value&0x01 != 0
+	case addr == 0x5011:
+		// Writing zero has no effect, it is reserved for the IRQ.
+		if !m.pcmRead && value != 0 {
+			m.pcm = value
+		}
+	case addr == 0x5015:
+		m.pulse1.enabled = value&0x01 != 0
+		m.pulse2.enabled = value&0x02 != 0
+
+		if !m.pulse1.enabled {
+			m.pulse1.length = 0
+		}
+
+		if !m.pulse2.enabled {
+			m.pulse2.length = 0
+		}
+	}
+}
+
+// Tick advances the sound chip by one CPU cycle.
+func (m *MMC5Audio) Tick() {
+	if m.cycle%2 == 0 {
+		m.pulse1.tickTimer()
+		m.pulse2.tickTimer()
+	}
+
+	if m.cycle%mmc5FrameCycles == 0 {
+		m.pulse1.tickEnvelope()
+		m.pulse1.tickLength()
+		m.pulse2.tickEnvelope()
+		m.pulse2.tickLength()
+	}
+
+	m.cycle++
+}
+
+// Output returns the current output level, on the same scale as the APU.
+func (m *MMC5Audio) Output() float32 {
+	pulseOut := 0.00752 * (float32(m.pulse1.output()) + float32(m.pulse2.output()))
+	pcmOut := 0.00335 * float32(m.pcm) / 2
+	return pulseOut + pcmOut
+}
+
+func (m *MMC5Audio) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.pulse1.saveState(w),
+		m.pulse2.saveState(w),
+		w.WriteUint8(m.pcm),
+		w.WriteBool(m.pcmRead),
+		w.WriteUint64(m.cycle),
+	)
+}
+
+func (m *MMC5Audio) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		m.pulse1.loadState(r),
+		m.pulse2.loadState(r),
+		r.ReadUint8To(&m.pcm),
+		r.ReadBoolTo(&m.pcmRead),
+		r.ReadUint64To(&m.cycle),
+	)
+}
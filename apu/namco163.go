@@ -0,0 +1,140 @@
+package apu
+
+import (
+	"errors"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// namco163UpdateCycles is the number of CPU cycles it takes the chip to update
+// a single channel.
+const namco163UpdateCycles = 15
+
+// Namco163Audio is the sound part of the Namco 163 mapper. It plays up to 8
+// wavetable channels, which waveforms and registers are all stored in the 128
+// bytes of the chip's internal RAM (owned by the mapper, since it is also used
+// as general purpose battery-backed memory by some games).
+//
+// The chip does not mix the channels, but updates and outputs them one at a
+// time, each for 15 CPU cycles. Such switching is too fast to be heard, so the
+// result is their average, which means each channel gets quieter as more of
+// them are enabled.
+// https://www.nesdev.org/wiki/Namco_163_audio
+type Namco163Audio struct {
+	ram      []byte
+	cycle    uint8
+	channel  uint8
+	outputs  [8]int8
+	disabled bool
+}
+
+// NewNamco163Audio creates the sound chip working on the given 128 bytes of RAM.
+func NewNamco163Audio(ram []byte) *Namco163Audio {
+	return &Namco163Audio{ram: ram}
+}
+
+func (n *Namco163Audio) Reset() {
+	n.cycle = 0
+	n.channel = 7
+	n.outputs = [8]int8{}
+	n.disabled = false
+}
+
+// SetDisabled mutes the chip (bit 6 of $E000).
+func (n *Namco163Audio) SetDisabled(disabled bool) {
+	n.disabled = disabled
+}
+
+// channels returns the number of enabled channels. The enabled ones are always
+// the last, i.e. with a single channel it is channel 7.
+func (n *Namco163Audio) channels() uint8 {
+	return (n.ram[0x7F]>>4)&0x07 + 1
+}
+
+func (n *Namco163Audio) updateChannel(ch uint8) {
+	var (
+		regs   = n.ram[0x40+int(ch)*8:][:8]
+		freq   = uint32(regs[0]) | uint32(regs[2])<<8 | uint32(regs[4]&0x03)<<16
+		phase  = uint32(regs[1]) | uint32(regs[3])<<8 | uint32(regs[5])<<16
+		length = 256 - uint32(regs[4]&0xFC)
+	)
+
+	// The phase is 24-bit, with the upper 8 bits being the sample index.
+	phase = (phase + freq) % (length << 16)
+	regs[1], regs[3], regs[5] = byte(phase), byte(phase>>8), byte(phase>>16)
+
+	// Samples are 4-bit, packed two per byte, low nibble first.
+	addr := (uint32(regs[6]) + phase>>16) & 0xFF
+	sample := n.ram[addr/2] >> (addr & 0x01 * 4) & 0x0F
+
+	n.outputs[ch] = (int8(sample) - 8) * int8(regs[7]&0x0F)
+}
+
+// Tick advances the sound chip by one CPU cycle.
+func (n *Namco163Audio) Tick() {
+	if n.disabled {
+		return
+	}
+
+	if n.cycle++; n.cycle < namco163UpdateCycles {
+		return
+	}
+
+	n.cycle = 0
+	n.updateChannel(n.channel)
+
+	if n.channel == 8-n.channels() || n.channel == 0 {
+		n.channel = 7
+	} else {
+		n.channel--
+	}
+}
+
+// Output returns the current output level, on the same scale as the APU.
+func (n *Namco163Audio) Output() float32 {
+	if n.disabled {
+		return 0
+	}
+
+	var (
+		count = n.channels()
+		sum   int
+	)
+
+	for ch := 8 - count; ch < 8; ch++ {
+		sum += int(n.outputs[ch])
+	}
+
+	return 0.00125 * float32(sum) / float32(count)
+}
+
+func (n *Namco163Audio) SaveState(w *binario.Writer) error {
+	outputs := make([]byte, len(n.outputs))
+	for i, v := range n.outputs {
+		outputs[i] = byte(v)
+	}
+
+	return errors.Join(
+		w.WriteUint8(n.cycle),
+		w.WriteUint8(n.channel),
+		w.WriteByteSlice(outputs),
+		w.WriteBool(n.disabled),
+	)
+}
+
+func (n *Namco163Audio) LoadState(r *binario.Reader) error {
+	outputs := make([]byte, len(n.outputs))
+
+	err := errors.Join(
+		r.ReadUint8To(&n.cycle),
+		r.ReadUint8To(&n.channel),
+		r.ReadByteSliceTo(outputs),
+		r.ReadBoolTo(&n.disabled),
+	)
+
+	for i, v := range outputs {
+		n.outputs[i] = int8(v)
+	}
+
+	return err
+}
@@ -6,11 +6,16 @@ import (
 	"github.com/maxpoletaev/dendy/internal/binario"
 )
 
-var noiseTable = [16]uint16{
+var noiseTableNTSC = [16]uint16{
 	0, 4, 8, 16, 32, 64, 96, 128,
 	160, 202, 254, 380, 508, 1016, 2034, 4068,
 }
 
+var noiseTablePAL = [16]uint16{
+	4, 8, 14, 30, 60, 88, 118, 148,
+	188, 236, 354, 472, 708, 944, 1890, 3778,
+}
+
 type noise struct {
 	enabled  bool
 	sample   uint8
@@ -18,6 +23,7 @@ type noise struct {
 	mode6    bool
 	volume   uint8
 	envelope envelope
+	periods  *[16]uint16
 
 	// Timer
 	timerLoad uint16
@@ -46,7 +52,7 @@ func (n *noise) reset() {
 func (n *noise) write(addr uint16, value byte) {
 	switch addr {
 	case 0x400E:
-		n.timerLoad = noiseTable[value&0x0F]
+		n.timerLoad = n.periods[value&0x0F]
 		n.mode6 = value&0x80 != 0
 	case 0x400C:
 		n.lengthHalt = value&0x20 != 0
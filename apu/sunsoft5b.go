@@ -0,0 +1,259 @@
+package apu
+
+import (
+	"errors"
+	"math"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// sunsoft5BLevels is the output amplitude for the 5-bit envelope levels. The
+// chip has logarithmic volume, with about 1.5 dB per level, and level 0 is silent.
+var sunsoft5BLevels = func() (levels [32]float32) {
+	for i := 1; i < len(levels); i++ {
+		levels[i] = float32(math.Pow(10, -1.5*float64(31-i)/20))
+	}
+
+	return levels
+}()
+
+type sunsoft5BTone struct {
+	period  uint16
+	counter uint16
+	out     bool
+}
+
+func (t *sunsoft5BTone) tick() {
+	if t.counter++; t.counter >= max(t.period, 1) {
+		t.counter = 0
+		t.out = !t.out
+	}
+}
+
+type sunsoft5BEnvelope struct {
+	period  uint16
+	counter uint16
+	step    uint8 // 0-31
+	mask    uint8 // 0 when rising, 0x1F when falling
+	holding bool
+	shape   uint8
+}
+
+func (e *sunsoft5BEnvelope) restart() {
+	e.counter = 0
+	e.step = 0
+	e.holding = false
+
+	if e.shape&0x04 != 0 { // attack
+		e.mask = 0
+	} else {
+		e.mask = 0x1F
+	}
+}
+
+func (e *sunsoft5BEnvelope) tick() {
+	if e.holding {
+		return
+	}
+
+	if e.counter++; e.counter < max(e.period, 1) {
+		return
+	}
+
+	e.counter = 0
+
+	if e.step++; e.step <= 31 {
+		return
+	}
+
+	var (
+		cont      = e.shape&0x08 != 0
+		alternate = e.shape&0x02 != 0
+		hold      = e.shape&0x01 != 0
+	)
+
+	switch {
+	case !cont: // Single decay or attack, then silence.
+		e.holding = true
+		e.step = 31
+		e.mask = 0x1F
+	case hold: // Hold at the last level, or at the opposite one when alternating.
+		if alternate {
+			e.mask ^= 0x1F
+		}
+		e.holding = true
+		e.step = 31
+	default:
+		if alternate {
+			e.mask ^= 0x1F
+		}
+		e.step = 0
+	}
+}
+
+func (e *sunsoft5BEnvelope) level() uint8 {
+	return e.step ^ e.mask
+}
+
+// Sunsoft5BAudio is the sound part of the Sunsoft 5B mapper, a variant of the
+// Yamaha YM2149 (AY-3-8910), which has three square channels, a noise generator
+// and an envelope generator shared by all channels.
+// https://www.nesdev.org/wiki/Sunsoft_5B_audio
+type Sunsoft5BAudio struct {
+	regs      [16]byte
+	addr      uint8
+	tones     [3]sunsoft5BTone
+	env       sunsoft5BEnvelope
+	noise     uint32 // 17-bit LFSR
+	noiseCnt  uint8
+	noiseHalf bool
+	cycle     uint64
+}
+
+func (s *Sunsoft5BAudio) Reset() {
+	s.regs = [16]byte{}
+	s.addr = 0
+	s.tones = [3]sunsoft5BTone{}
+	s.env = sunsoft5BEnvelope{}
+	s.noise = 1
+	s.noiseCnt = 0
+	s.noiseHalf = false
+	s.cycle = 0
+}
+
+// Write handles writes to the register select ($C000-$DFFF) and data
+// ($E000-$FFFF) ports.
+func (s *Sunsoft5BAudio) Write(addr uint16, value byte) {
+	switch {
+	case addr >= 0xC000 && addr <= 0xDFFF:
+		s.addr = value
+	case addr >= 0xE000:
+		// The upper bits of the address select another chip, which is absent.
+		if s.addr <= 0x0F {
+			s.writeRegister(s.addr, value)
+		}
+	}
+}
+
+func (s *Sunsoft5BAudio) writeRegister(reg uint8, value byte) {
+	s.regs[reg] = value
+
+	switch reg {
+	case 0x00, 0x01, 0x02, 0x03, 0x04, 0x05:
+		t := &s.tones[reg/2]
+		t.period = uint16(s.regs[reg&^1]) | uint16(s.regs[reg|1]&0x0F)<<8
+	case 0x0B, 0x0C:
+		s.env.period = uint16(s.regs[0x0B]) | uint16(s.regs[0x0C])<<8
+	case 0x0D:
+		s.env.shape = value & 0x0F
+		s.env.restart()
+	}
+}
+
+// Tick advances the sound chip by one CPU cycle.
+func (s *Sunsoft5BAudio) Tick() {
+	// The envelope runs twice as fast as the tone generators,
+	// since it has 32 steps instead of 16 of the original AY.
+	if s.cycle%8 == 0 {
+		s.env.tick()
+	}
+
+	if s.cycle%16 == 0 {
+		for i := range s.tones {
+			s.tones[i].tick()
+		}
+
+		if s.noiseCnt++; s.noiseCnt >= max(s.regs[0x06]&0x1F, 1) {
+			s.noiseCnt = 0
+
+			// The noise is clocked at half the rate of the tone generators.
+			if s.noiseHalf = !s.noiseHalf; s.noiseHalf {
+				bit := (s.noise ^ s.noise>>3) & 0x01
+				s.noise = s.noise>>1 | bit<<16
+			}
+		}
+	}
+
+	s.cycle++
+}
+
+// Output returns the current output level, on the same scale as the APU.
+func (s *Sunsoft5BAudio) Output() float32 {
+	var (
+		out   float32
+		mixer = s.regs[0x07]
+		noise = s.noise&0x01 != 0
+	)
+
+	for i := range s.tones {
+		toneOff := mixer&(1<<i) != 0
+		noiseOff := mixer&(8<<i) != 0
+
+		if (!toneOff && !s.tones[i].out) || (!noiseOff && !noise) {
+			continue
+		}
+
+		vol := s.regs[0x08+i]
+		if vol&0x10 != 0 {
+			out += sunsoft5BLevels[s.env.level()]
+		} else if vol&0x0F != 0 {
+			out += sunsoft5BLevels[vol&0x0F*2+1]
+		}
+	}
+
+	return 0.1 * out
+}
+
+func (s *Sunsoft5BAudio) SaveState(w *binario.Writer) error {
+	err := errors.Join(
+		w.WriteByteSlice(s.regs[:]),
+		w.WriteUint8(s.addr),
+		w.WriteUint16(s.env.counter),
+		w.WriteUint8(s.env.step),
+		w.WriteUint8(s.env.mask),
+		w.WriteBool(s.env.holding),
+		w.WriteUint32(s.noise),
+		w.WriteUint8(s.noiseCnt),
+		w.WriteBool(s.noiseHalf),
+		w.WriteUint64(s.cycle),
+	)
+
+	for i := range s.tones {
+		err = errors.Join(err,
+			w.WriteUint16(s.tones[i].counter),
+			w.WriteBool(s.tones[i].out),
+		)
+	}
+
+	return err
+}
+
+func (s *Sunsoft5BAudio) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		r.ReadByteSliceTo(s.regs[:]),
+		r.ReadUint8To(&s.addr),
+		r.ReadUint16To(&s.env.counter),
+		r.ReadUint8To(&s.env.step),
+		r.ReadUint8To(&s.env.mask),
+		r.ReadBoolTo(&s.env.holding),
+		r.ReadUint32To(&s.noise),
+		r.ReadUint8To(&s.noiseCnt),
+		r.ReadBoolTo(&s.noiseHalf),
+		r.ReadUint64To(&s.cycle),
+	)
+
+	for i := range s.tones {
+		t := &s.tones[i]
+		t.period = uint16(s.regs[i*2]) | uint16(s.regs[i*2+1]&0x0F)<<8
+
+		err = errors.Join(err,
+			r.ReadUint16To(&t.counter),
+			r.ReadBoolTo(&t.out),
+		)
+	}
+
+	s.env.period = uint16(s.regs[0x0B]) | uint16(s.regs[0x0C])<<8
+	s.env.shape = s.regs[0x0D] & 0x0F
+
+	return err
+}
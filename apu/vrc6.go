@@ -0,0 +1,240 @@
+package apu
+
+import (
+	"errors"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+type vrc6Pulse struct {
+	enabled bool
+	mode    bool // ignore duty and output constant volume
+	volume  uint8
+	duty    uint8
+	step    uint8
+	period  uint16
+	timer   uint16
+}
+
+func (p *vrc6Pulse) write(reg uint16, value byte) {
+	switch reg {
+	case 0:
+		p.volume = value & 0x0F
+		p.duty = (value >> 4) & 0x07
+		p.mode = value&0x80 != 0
+	case 1:
+		p.period = p.period&0x0F00 | uint16(value)
+	case 2:
+		p.period = p.period&0x00FF | uint16(value&0x0F)<<8
+		p.enabled = value&0x80 != 0
+
+		if !p.enabled {
+			p.step = 15
+		}
+	}
+}
+
+func (p *vrc6Pulse) tick(shift uint8) {
+	if !p.enabled {
+		return
+	}
+
+	if p.timer > 0 {
+		p.timer--
+		return
+	}
+
+	p.timer = p.period >> shift
+	p.step = (p.step - 1) & 0x0F
+}
+
+func (p *vrc6Pulse) output() uint8 {
+	if !p.enabled {
+		return 0
+	}
+
+	if p.mode || p.step <= p.duty {
+		return p.volume
+	}
+
+	return 0
+}
+
+func (p *vrc6Pulse) saveState(w *binario.Writer) error {
+	return errors.Join(
+		w.WriteBool(p.enabled),
+		w.WriteBool(p.mode),
+		w.WriteUint8(p.volume),
+		w.WriteUint8(p.duty),
+		w.WriteUint8(p.step),
+		w.WriteUint16(p.period),
+		w.WriteUint16(p.timer),
+	)
+}
+
+func (p *vrc6Pulse) loadState(r *binario.Reader) error {
+	return errors.Join(
+		r.ReadBoolTo(&p.enabled),
+		r.ReadBoolTo(&p.mode),
+		r.ReadUint8To(&p.volume),
+		r.ReadUint8To(&p.duty),
+		r.ReadUint8To(&p.step),
+		r.ReadUint16To(&p.period),
+		r.ReadUint16To(&p.timer),
+	)
+}
+
+type vrc6Saw struct {
+	enabled     bool
+	rate        uint8
+	accumulator uint8
+	step        uint8
+	period      uint16
+	timer       uint16
+}
+
+func (s *vrc6Saw) write(reg uint16, value byte) {
+	switch reg {
+	case 0:
+		s.rate = value & 0x3F
+	case 1:
+		s.period = s.period&0x0F00 | uint16(value)
+	case 2:
+		s.period = s.period&0x00FF | uint16(value&0x0F)<<8
+		s.enabled = value&0x80 != 0
+
+		if !s.enabled {
+			s.accumulator = 0
+			s.step = 0
+		}
+	}
+}
+
+func (s *vrc6Saw) tick(shift uint8) {
+	if !s.enabled {
+		return
+	}
+
+	if s.timer > 0 {
+		s.timer--
+		return
+	}
+
+	s.timer = s.period >> shift
+
+	// The accumulator is increased on every other step,
+	// and reset on the 14th one, producing a sawtooth wave.
+	s.step++
+	if s.step == 14 {
+		s.accumulator = 0
+		s.step = 0
+	} else if s.step%2 == 0 {
+		s.accumulator += s.rate
+	}
+}
+
+func (s *vrc6Saw) output() uint8 {
+	return s.accumulator >> 3
+}
+
+func (s *vrc6Saw) saveState(w *binario.Writer) error {
+	return errors.Join(
+		w.WriteBool(s.enabled),
+		w.WriteUint8(s.rate),
+		w.WriteUint8(s.accumulator),
+		w.WriteUint8(s.step),
+		w.WriteUint16(s.period),
+		w.WriteUint16(s.timer),
+	)
+}
+
+func (s *vrc6Saw) loadState(r *binario.Reader) error {
+	return errors.Join(
+		r.ReadBoolTo(&s.enabled),
+		r.ReadUint8To(&s.rate),
+		r.ReadUint8To(&s.accumulator),
+		r.ReadUint8To(&s.step),
+		r.ReadUint16To(&s.period),
+		r.ReadUint16To(&s.timer),
+	)
+}
+
+// VRC6Audio is the sound part of the Konami VRC6 mapper, which has two pulse
+// channels with 8 duty cycles and a sawtooth channel.
+// https://www.nesdev.org/wiki/VRC6_audio
+type VRC6Audio struct {
+	pulse1 vrc6Pulse
+	pulse2 vrc6Pulse
+	saw    vrc6Saw
+	halt   bool
+	shift  uint8
+}
+
+func (v *VRC6Audio) Reset() {
+	v.pulse1 = vrc6Pulse{}
+	v.pulse2 = vrc6Pulse{}
+	v.saw = vrc6Saw{}
+	v.halt = false
+	v.shift = 0
+}
+
+// Write handles writes to the sound registers. The address is expected to be
+// already normalized, i.e. $9000-$9003, $A000-$A002 or $B000-$B002.
+func (v *VRC6Audio) Write(addr uint16, value byte) {
+	switch {
+	case addr == 0x9003:
+		v.halt = value&0x01 != 0
+
+		switch {
+		case value&0x04 != 0:
+			v.shift = 8
+		case value&0x02 != 0:
+			v.shift = 4
+		default:
+			v.shift = 0
+		}
+	case addr >= 0x9000 && addr <= 0x9002:
+		v.pulse1.write(addr&0x03, value)
+	case addr >= 0xA000 && addr <= 0xA002:
+		v.pulse2.write(addr&0x03, value)
+	case addr >= 0xB000 && addr <= 0xB002:
+		v.saw.write(addr&0x03, value)
+	}
+}
+
+// Tick advances the sound chip by one CPU cycle.
+func (v *VRC6Audio) Tick() {
+	if v.halt {
+		return
+	}
+
+	v.pulse1.tick(v.shift)
+	v.pulse2.tick(v.shift)
+	v.saw.tick(v.shift)
+}
+
+// Output returns the current output level, on the same scale as the APU.
+func (v *VRC6Audio) Output() float32 {
+	out := v.pulse1.output() + v.pulse2.output() + v.saw.output()
+	return 0.00752 * float32(out)
+}
+
+func (v *VRC6Audio) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		v.pulse1.saveState(w),
+		v.pulse2.saveState(w),
+		v.saw.saveState(w),
+		w.WriteBool(v.halt),
+		w.WriteUint8(v.shift),
+	)
+}
+
+func (v *VRC6Audio) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		v.pulse1.loadState(r),
+		v.pulse2.loadState(r),
+		v.saw.loadState(r),
+		r.ReadBoolTo(&v.halt),
+		r.ReadUint8To(&v.shift),
+	)
+}
@@ -0,0 +1,423 @@
+package apu
+
+import (
+	"errors"
+	"math"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// The VRC7 sound chip is a cut-down Yamaha YM2413 (OPLL) with six FM channels,
+// two operators each, and a different set of built-in instruments. This is not
+// a bit-exact emulation of the chip (it works in floating point and decibels),
+// but it follows its structure closely enough to sound right.
+// https://www.nesdev.org/wiki/VRC7_audio
+
+const (
+	opllDivider    = 36 // the chip produces a sample every 36 CPU cycles
+	opllSampleRate = 1789773.0 / opllDivider
+	opllMaxAtten   = 48.0 // dB, the envelope is considered silent beyond that
+)
+
+// vrc7Patches are the built-in instruments, as dumped from the chip by Nuke.YKT.
+// The first one is the custom instrument, defined by registers $00-$07.
+var vrc7Patches = [16][8]byte{
+	{},
+	{0x03, 0x21, 0x05, 0x06, 0xE8, 0x81, 0x42, 0x27}, // Buzzy bell
+	{0x13, 0x41, 0x14, 0x0D, 0xD8, 0xF6, 0x23, 0x12}, // Guitar
+	{0x11, 0x11, 0x08, 0x08, 0xFA, 0xB2, 0x20, 0x12}, // Wurly
+	{0x31, 0x61, 0x0C, 0x07, 0xA8, 0x64, 0x61, 0x27}, // Flute
+	{0x32, 0x21, 0x1E, 0x06, 0xE1, 0x76, 0x01, 0x28}, // Clarinet
+	{0x02, 0x01, 0x06, 0x00, 0xA3, 0xE2, 0xF4, 0xF4}, // Synth
+	{0x21, 0x61, 0x1D, 0x07, 0x82, 0x81, 0x11, 0x07}, // Trumpet
+	{0x23, 0x21, 0x22, 0x17, 0xA2, 0x72, 0x01, 0x17}, // Organ
+	{0x35, 0x11, 0x25, 0x00, 0x40, 0x73, 0x72, 0x01}, // Bells
+	{0xB5, 0x01, 0x0F, 0x0F, 0xA8, 0xA5, 0x51, 0x02}, // Vibes
+	{0x17, 0xC1, 0x24, 0x07, 0xF8, 0xF8, 0x22, 0x12}, // Vibraphone
+	{0x71, 0x23, 0x11, 0x06, 0x65, 0x74, 0x18, 0x16}, // Tutti
+	{0x01, 0x02, 0xD3, 0x05, 0xC9, 0x95, 0x03, 0x02}, // Fretless
+	{0x61, 0x63, 0x0C, 0x00, 0x94, 0xC0, 0x33, 0xF6}, // Synth bass
+	{0x21, 0x72, 0x0D, 0x00, 0xC1, 0xD5, 0x56, 0x06}, // Sweep
+}
+
+var opllMultiplier = [16]float64{0.5, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 10, 12, 12, 15, 15}
+
+// opllKSLTable is the key scale level attenuation for the highest octave,
+// indexed by the top 4 bits of F-Number, in 0.75 dB units.
+var opllKSLTable = [16]float64{0, 24, 32, 37, 40, 43, 45, 47, 48, 50, 51, 52, 53, 54, 55, 56}
+
+// opllFeedback is the modulator self-feedback depth, in sine periods.
+var opllFeedback = [8]float64{0, 1.0 / 32, 1.0 / 16, 1.0 / 8, 1.0 / 4, 1.0 / 2, 1, 2}
+
+type envState uint8
+
+const (
+	envAttack envState = iota
+	envDecay
+	envSustain
+	envRelease
+)
+
+// opllOperator is either a modulator or a carrier of the FM channel.
+type opllOperator struct {
+	phase float64 // in sine periods
+	env   float64 // attenuation in dB
+	state envState
+}
+
+// opllPatch is the decoded instrument parameters for one operator.
+type opllPatch struct {
+	am, vib, sustained, ksr bool
+	mult                    float64
+	ksl                     uint8
+	ar, dr, sl, rr          uint8
+	rectify                 bool
+}
+
+func decodePatch(p *[8]byte, op int) (patch opllPatch) {
+	patch.am = p[op]&0x80 != 0
+	patch.vib = p[op]&0x40 != 0
+	patch.sustained = p[op]&0x20 != 0
+	patch.ksr = p[op]&0x10 != 0
+	patch.mult = opllMultiplier[p[op]&0x0F]
+	patch.ksl = p[2+op] >> 6
+	patch.ar = p[4+op] >> 4
+	patch.dr = p[4+op] & 0x0F
+	patch.sl = p[6+op] >> 4
+	patch.rr = p[6+op] & 0x0F
+	patch.rectify = p[3]&(0x08<<op) != 0
+
+	return patch
+}
+
+type opllChannel struct {
+	fnum    uint16
+	block   uint8
+	key     bool
+	sustain bool
+	inst    uint8
+	volume  uint8
+	ops     [2]opllOperator
+	fb      [2]float64 // last two modulator outputs
+}
+
+// decayStep returns the envelope change per sample for the given effective
+// rate (0-63). Rate 4 takes about 10 seconds to go through the whole range,
+// and every 4 steps make it twice as fast.
+func decayStep(rate int) float64 {
+	if rate < 4 {
+		return 0
+	}
+
+	return opllMaxAtten / (opllSampleRate * 10) * math.Exp2(float64(rate-4)/4)
+}
+
+// VRC7Audio is the sound part of the Konami VRC7 mapper.
+type VRC7Audio struct {
+	regs     [0x40]byte
+	addr     uint8
+	channels [6]opllChannel
+	cycle    uint64
+	amPhase  float64
+	vibPhase float64
+	output   float32
+	silenced bool
+}
+
+func (v *VRC7Audio) Reset() {
+	v.regs = [0x40]byte{}
+	v.addr = 0
+	v.channels = [6]opllChannel{}
+	v.cycle = 0
+	v.amPhase = 0
+	v.vibPhase = 0
+	v.output = 0
+	v.silenced = false
+
+	for i := range v.channels {
+		for j := range v.channels[i].ops {
+			v.channels[i].ops[j].env = opllMaxAtten
+			v.channels[i].ops[j].state = envRelease
+		}
+	}
+}
+
+// Silence holds the chip in reset while set (bit 6 of $E000).
+func (v *VRC7Audio) Silence(silenced bool) {
+	if silenced && !v.silenced {
+		v.Reset()
+	}
+
+	v.silenced = silenced
+}
+
+// Write handles writes to the register select ($9010) and data ($9030) ports.
+func (v *VRC7Audio) Write(addr uint16, value byte) {
+	if v.silenced {
+		return
+	}
+
+	switch addr {
+	case 0x9010:
+		v.addr = value & 0x3F
+	case 0x9030:
+		v.writeRegister(v.addr, value)
+	}
+}
+
+func (v *VRC7Audio) writeRegister(reg uint8, value byte) {
+	v.regs[reg] = value
+
+	idx := int(reg & 0x0F)
+	if reg < 0x10 || idx >= len(v.channels) {
+		return
+	}
+
+	ch := &v.channels[idx]
+
+	switch reg & 0xF0 {
+	case 0x10:
+		ch.fnum = ch.fnum&0x100 | uint16(value)
+	case 0x20:
+		ch.fnum = ch.fnum&0xFF | uint16(value&0x01)<<8
+		ch.block = (value >> 1) & 0x07
+		ch.sustain = value&0x20 != 0
+
+		key := value&0x10 != 0
+		if key && !ch.key {
+			for i := range ch.ops {
+				ch.ops[i].phase = 0
+				ch.ops[i].state = envAttack
+			}
+		} else if !key && ch.key {
+			for i := range ch.ops {
+				ch.ops[i].state = envRelease
+			}
+		}
+
+		ch.key = key
+	case 0x30:
+		ch.inst = value >> 4
+		ch.volume = value & 0x0F
+	}
+}
+
+func (v *VRC7Audio) patch(ch *opllChannel) *[8]byte {
+	if ch.inst == 0 {
+		return (*[8]byte)(v.regs[0:8])
+	}
+
+	return &vrc7Patches[ch.inst]
+}
+
+func (v *VRC7Audio) tickEnvelope(ch *opllChannel, op *opllOperator, p *opllPatch) {
+	keyCode := int(ch.block)<<1 | int(ch.fnum>>8)
+	rks := keyCode >> 2
+	if p.ksr {
+		rks = keyCode
+	}
+
+	rate := func(r uint8) int {
+		if r == 0 {
+			return 0
+		}
+		return min(int(r)*4+rks, 63)
+	}
+
+	switch op.state {
+	case envAttack:
+		if p.ar == 15 {
+			op.env = 0
+		} else {
+			// The attack is exponential, so it slows down closer to the peak.
+			op.env -= (op.env + 1) * decayStep(rate(p.ar)) / 6
+		}
+
+		if op.env <= 0 {
+			op.env = 0
+			op.state = envDecay
+		}
+	case envDecay:
+		op.env += decayStep(rate(p.dr))
+
+		if sl := float64(p.sl) * 3; op.env >= sl {
+			op.env = sl
+			op.state = envSustain
+		}
+	case envSustain:
+		// Percussive instruments keep fading out even while the key is held.
+		if !p.sustained {
+			op.env += decayStep(rate(p.rr))
+		}
+	case envRelease:
+		switch {
+		case ch.sustain:
+			op.env += decayStep(rate(5))
+		case p.sustained:
+			op.env += decayStep(rate(p.rr))
+		default:
+			op.env += decayStep(rate(7))
+		}
+	}
+
+	op.env = min(op.env, opllMaxAtten)
+}
+
+// operatorOutput calculates the output of the operator in range [-1, 1] for the
+// given phase modulation and total attenuation, and advances its phase.
+func (v *VRC7Audio) operatorOutput(ch *opllChannel, op *opllOperator, p *opllPatch, mod, atten float64) float64 {
+	step := float64(ch.fnum) * math.Exp2(float64(ch.block)) * p.mult / (1 << 19)
+	if p.vib {
+		step *= 1 + 0.004*math.Sin(2*math.Pi*v.vibPhase)
+	}
+
+	out := math.Sin(2 * math.Pi * (op.phase + mod))
+	op.phase = math.Mod(op.phase+step, 1)
+
+	if p.rectify && out < 0 {
+		out = 0
+	}
+
+	if p.ksl > 0 {
+		ksl := max(opllKSLTable[ch.fnum>>5]-8*float64(7-ch.block), 0) * 0.75
+		atten += ksl / float64(uint(1)<<(3-p.ksl))
+	}
+
+	if p.am {
+		atten += 4.8 * (1 + math.Sin(2*math.Pi*v.amPhase)) / 2
+	}
+
+	atten += op.env
+	if atten >= opllMaxAtten {
+		return 0
+	}
+
+	return out * math.Exp2(-atten/6.0206)
+}
+
+func (v *VRC7Audio) sample() float64 {
+	var out float64
+
+	for i := range v.channels {
+		ch := &v.channels[i]
+		raw := v.patch(ch)
+		mp, cp := decodePatch(raw, 0), decodePatch(raw, 1)
+		mod, car := &ch.ops[0], &ch.ops[1]
+
+		v.tickEnvelope(ch, mod, &mp)
+		v.tickEnvelope(ch, car, &cp)
+
+		fb := (ch.fb[0] + ch.fb[1]) / 2 * opllFeedback[raw[3]&0x07]
+		modOut := v.operatorOutput(ch, mod, &mp, fb, float64(raw[2]&0x3F)*0.75)
+		ch.fb[1], ch.fb[0] = ch.fb[0], modOut
+
+		out += v.operatorOutput(ch, car, &cp, modOut*2, float64(ch.volume)*3)
+	}
+
+	v.amPhase = math.Mod(v.amPhase+3.7/opllSampleRate, 1)
+	v.vibPhase = math.Mod(v.vibPhase+6.4/opllSampleRate, 1)
+
+	return out
+}
+
+// Tick advances the sound chip by one CPU cycle.
+func (v *VRC7Audio) Tick() {
+	if v.silenced {
+		return
+	}
+
+	if v.cycle%opllDivider == 0 {
+		v.output = float32(v.sample())
+	}
+
+	v.cycle++
+}
+
+// Output returns the current output level, on the same scale as the APU.
+func (v *VRC7Audio) Output() float32 {
+	if v.silenced {
+		return 0
+	}
+
+	return v.output * 0.05
+}
+
+func (v *VRC7Audio) SaveState(w *binario.Writer) error {
+	err := errors.Join(
+		w.WriteByteSlice(v.regs[:]),
+		w.WriteUint8(v.addr),
+		w.WriteUint64(v.cycle),
+		w.WriteUint64(math.Float64bits(v.amPhase)),
+		w.WriteUint64(math.Float64bits(v.vibPhase)),
+		w.WriteBool(v.silenced),
+	)
+
+	for i := range v.channels {
+		ch := &v.channels[i]
+
+		err = errors.Join(err,
+			w.WriteUint64(math.Float64bits(ch.fb[0])),
+			w.WriteUint64(math.Float64bits(ch.fb[1])),
+		)
+
+		for j := range ch.ops {
+			err = errors.Join(err,
+				w.WriteUint64(math.Float64bits(ch.ops[j].phase)),
+				w.WriteUint64(math.Float64bits(ch.ops[j].env)),
+				w.WriteUint8(uint8(ch.ops[j].state)),
+			)
+		}
+	}
+
+	return err
+}
+
+func (v *VRC7Audio) LoadState(r *binario.Reader) error {
+	var amPhase, vibPhase uint64
+
+	err := errors.Join(
+		r.ReadByteSliceTo(v.regs[:]),
+		r.ReadUint8To(&v.addr),
+		r.ReadUint64To(&v.cycle),
+		r.ReadUint64To(&amPhase),
+		r.ReadUint64To(&vibPhase),
+		r.ReadBoolTo(&v.silenced),
+	)
+
+	v.amPhase = math.Float64frombits(amPhase)
+	v.vibPhase = math.Float64frombits(vibPhase)
+
+	for i := range v.channels {
+		ch := &v.channels[i]
+
+		// Channel parameters are decoded from the registers directly, since
+		// replaying the writes would also retrigger the envelopes.
+		ch.fnum = uint16(v.regs[0x20+i]&0x01)<<8 | uint16(v.regs[0x10+i])
+		ch.block = (v.regs[0x20+i] >> 1) & 0x07
+		ch.key = v.regs[0x20+i]&0x10 != 0
+		ch.sustain = v.regs[0x20+i]&0x20 != 0
+		ch.inst = v.regs[0x30+i] >> 4
+		ch.volume = v.regs[0x30+i] & 0x0F
+
+		var fb0, fb1 uint64
+		err = errors.Join(err, r.ReadUint64To(&fb0), r.ReadUint64To(&fb1))
+		ch.fb[0], ch.fb[1] = math.Float64frombits(fb0), math.Float64frombits(fb1)
+
+		for j := range ch.ops {
+			var phase, env uint64
+			var state uint8
+
+			err = errors.Join(err,
+				r.ReadUint64To(&phase),
+				r.ReadUint64To(&env),
+				r.ReadUint8To(&state),
+			)
+
+			ch.ops[j].phase = math.Float64frombits(phase)
+			ch.ops[j].env = math.Float64frombits(env)
+			ch.ops[j].state = envState(state)
+		}
+	}
+
+	return err
+}
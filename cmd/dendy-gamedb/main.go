@@ -0,0 +1,312 @@
+// Command dendy-gamedb converts a NesCartDB XML export into the compact game
+// database embedded into the ines package (ines/gamedb.txt).
+//
+//	go run ./cmd/dendy-gamedb -in NesCarts.xml -out ines/gamedb.txt
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type opts struct {
+	in  string
+	out string
+}
+
+func parseOpts() opts {
+	opts := opts{}
+
+	flag.StringVar(&opts.in, "in", "", "NesCartDB XML export")
+	flag.StringVar(&opts.out, "out", "ines/gamedb.txt", "output file")
+
+	flag.Parse()
+
+	return opts
+}
+
+type database struct {
+	Games []game `xml:"game"`
+}
+
+type game struct {
+	Name        string      `xml:"name,attr"`
+	Cartridges  []cartridge `xml:"cartridge"`
+	Peripherals []struct {
+		Type string `xml:"type,attr"`
+	} `xml:"peripherals>device"`
+}
+
+type cartridge struct {
+	System string `xml:"system,attr"`
+	CRC    string `xml:"crc,attr"`
+	Board  struct {
+		Type   string `xml:"type,attr"`
+		Mapper string `xml:"mapper,attr"`
+		WRAM   []struct {
+			Size    string `xml:"size,attr"`
+			Battery string `xml:"battery,attr"`
+		} `xml:"wram"`
+		Pad struct {
+			H string `xml:"h,attr"`
+			V string `xml:"v,attr"`
+		} `xml:"pad"`
+		Chips []chip `xml:"chip"`
+	} `xml:"board"`
+}
+
+type chip struct {
+	Type string `xml:"type,attr"`
+	Pins []struct {
+		Number   string `xml:"number,attr"`
+		Function string `xml:"function,attr"`
+	} `xml:"pin"`
+}
+
+// pinAddressLine returns the CPU address line connected to the given pin of the
+// chip (e.g. 3 for "PRG A3"), or -1 if the pin is not listed.
+func (c chip) pinAddressLine(number string) int {
+	for _, pin := range c.Pins {
+		if pin.Number != number {
+			continue
+		}
+
+		if line, ok := strings.CutPrefix(pin.Function, "PRG A"); ok {
+			if n, err := strconv.Atoi(line); err == nil {
+				return n
+			}
+		}
+	}
+
+	return -1
+}
+
+// vrc4Wiring maps the CPU address lines connected to the A0 and A1 pins of the
+// VRC4 chip (pins 3 and 4) to the mapper and submapper of the board.
+var vrc4Wiring = map[[2]int]string{
+	{1, 2}: "21.1", // VRC4a
+	{6, 7}: "21.2", // VRC4c
+	{0, 1}: "23.1", // VRC4f
+	{2, 3}: "23.2", // VRC4e
+	{1, 0}: "25.1", // VRC4b
+	{3, 2}: "25.2", // VRC4d
+}
+
+// vrcMapper returns the mapper and submapper of a VRC2 or VRC4 board from the
+// wiring of the chip, since the same mapper number is used for the boards with
+// different wiring. VRC2a and VRC2c are wired the same way, so the mapper
+// number is kept for VRC2.
+func vrcMapper(c cartridge) (string, bool) {
+	for _, chip := range c.Board.Chips {
+		var (
+			vrc2 = strings.Contains(chip.Type, "VRC II") || strings.Contains(chip.Type, "VRC2")
+			vrc4 = strings.Contains(chip.Type, "VRC IV") || strings.Contains(chip.Type, "VRC4")
+			a0   = chip.pinAddressLine("3")
+			a1   = chip.pinAddressLine("4")
+		)
+
+		switch {
+		case vrc4:
+			mapper, ok := vrc4Wiring[[2]int{a0, a1}]
+			return mapper, ok
+		case vrc2 && c.Board.Mapper == "23" && a0 == 0 && a1 == 1:
+			return "23.3", true // VRC2b
+		case vrc2 && c.Board.Mapper == "25" && a0 == 1 && a1 == 0:
+			return "25.3", true // VRC2c
+		}
+	}
+
+	return "", false
+}
+
+// mapper returns the mapper number of the cartridge, with the NES 2.0 submapper
+// where it makes a difference.
+func mapper(c cartridge) string {
+	if mapper, ok := vrcMapper(c); ok {
+		return mapper
+	}
+
+	return c.Board.Mapper + submapper(c)
+}
+
+// submapper guesses the NES 2.0 submapper from the board and chips, for the
+// mappers where it makes a difference.
+func submapper(c cartridge) string {
+	board := c.Board.Type
+
+	switch {
+	case strings.HasSuffix(board, "HKROM"):
+		return ".1"
+	case strings.HasSuffix(board, "BNROM"):
+		return ".2"
+	case strings.HasSuffix(board, "NINA-001"):
+		return ".1"
+	}
+
+	for _, chip := range c.Board.Chips {
+		switch {
+		case strings.Contains(chip.Type, "LZ93D50"):
+			return ".5"
+		case strings.Contains(chip.Type, "FCG"):
+			return ".4"
+		}
+	}
+
+	return ""
+}
+
+// fourScreenBoards have extra 2 KB of VRAM for four-screen mirroring.
+var fourScreenBoards = []string{"DRROM", "TR1ROM", "TVROM", "800004"}
+
+func mirroring(c cartridge) string {
+	for _, board := range fourScreenBoards {
+		if strings.HasSuffix(c.Board.Type, board) {
+			return "4"
+		}
+	}
+
+	// The solder pads connect CIRAM A10 to PPU A11 (horizontal) or A10 (vertical).
+	switch {
+	case c.Board.Pad.H == "1":
+		return "V"
+	case c.Board.Pad.V == "1":
+		return "H"
+	default:
+		return "-"
+	}
+}
+
+func wram(c cartridge) (kb int, battery bool) {
+	for _, ram := range c.Board.WRAM {
+		size, _ := strconv.Atoi(strings.TrimSuffix(strings.ToLower(ram.Size), "k"))
+		kb += size
+		battery = battery || ram.Battery == "1"
+	}
+
+	return kb, battery
+}
+
+func region(c cartridge) string {
+	switch {
+	case strings.Contains(c.System, "Dendy"):
+		return "dendy"
+	case strings.Contains(c.System, "PAL"):
+		return "pal"
+	default:
+		return "ntsc"
+	}
+}
+
+func inputDevice(g game) string {
+	for _, p := range g.Peripherals {
+		switch t := strings.ToLower(p.Type); {
+		case strings.Contains(t, "zapper"):
+			return "zapper"
+		case strings.Contains(t, "four"):
+			return "fourscore"
+		}
+	}
+
+	return "std"
+}
+
+// convert returns the database lines for all cartridges in the export, sorted
+// by the checksum.
+func convert(db database) []string {
+	var lines []string
+
+	for _, g := range db.Games {
+		for _, c := range g.Cartridges {
+			if c.CRC == "" || c.Board.Mapper == "" {
+				continue
+			}
+
+			kb, battery := wram(c)
+
+			batteryFlag := "0"
+			if battery {
+				batteryFlag = "1"
+			}
+
+			lines = append(lines, strings.Join([]string{
+				strings.ToUpper(c.CRC),
+				mapper(c),
+				c.Board.Type,
+				mirroring(c),
+				strconv.Itoa(kb),
+				batteryFlag,
+				region(c),
+				inputDevice(g),
+				strings.Map(func(r rune) rune {
+					if r == '\t' || r == '\n' {
+						return ' '
+					}
+					return r
+				}, g.Name),
+			}, "\t"))
+		}
+	}
+
+	sort.Strings(lines)
+
+	return lines
+}
+
+func main() {
+	args := parseOpts()
+
+	log.Default().SetFlags(0)
+
+	if args.in == "" {
+		log.Fatalf("[ERROR] -in is required")
+	}
+
+	data, err := os.ReadFile(args.in)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	var db database
+	if err := xml.Unmarshal(data, &db); err != nil {
+		log.Fatalf("[ERROR] failed to parse %s: %v", args.in, err)
+	}
+
+	lines := convert(db)
+
+	f, err := os.Create(args.out)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	w := bufio.NewWriter(f)
+	_, _ = fmt.Fprint(w, header)
+
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(w, line)
+	}
+
+	if err := w.Flush(); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	log.Printf("[INFO] %d cartridges written to %s", len(lines), args.out)
+}
+
+const header = `# Game database, generated from a NesCartDB export with cmd/dendy-gamedb.
+#
+# One cartridge per line, the fields are separated by tabs:
+# crc32 (PRG+CHR), mapper[.submapper], board, mirroring (H, V, 4 for four-screen
+# or - for mapper controlled), PRG-RAM in KB, battery (0 or 1), region (ntsc, pal
+# or dendy), input device (-, std, fourscore, 4p or zapper) and title.
+`
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+const testXML = `<?xml version="1.0" encoding="UTF-8"?>
+<database version="1.0">
+<game name="VRC Game">
+ <cartridge system="Famicom" crc="0000000a">
+  <board type="KONAMI-VRC-4" mapper="21">
+   <chip type="Konami VRC IV"><pin number="3" function="PRG A1"/><pin number="4" function="PRG A2"/></chip>
+  </board>
+ </cartridge>
+ <cartridge system="Famicom" crc="0000000c">
+  <board type="KONAMI-VRC-4" mapper="21">
+   <chip type="Konami VRC IV"><pin number="3" function="PRG A6"/><pin number="4" function="PRG A7"/></chip>
+  </board>
+ </cartridge>
+ <cartridge system="Famicom" crc="0000000e">
+  <board type="KONAMI-VRC-4" mapper="23">
+   <chip type="Konami VRC IV"><pin number="3" function="PRG A2"/><pin number="4" function="PRG A3"/></chip>
+  </board>
+ </cartridge>
+ <cartridge system="Famicom" crc="0000000d">
+  <board type="KONAMI-VRC-4" mapper="25">
+   <chip type="Konami VRC IV"><pin number="3" function="PRG A3"/><pin number="4" function="PRG A2"/></chip>
+  </board>
+ </cartridge>
+ <cartridge system="Famicom" crc="0000002a">
+  <board type="KONAMI-VRC-2" mapper="22">
+   <chip type="Konami VRC II"><pin number="3" function="PRG A1"/><pin number="4" function="PRG A0"/></chip>
+  </board>
+ </cartridge>
+ <cartridge system="Famicom" crc="0000002b">
+  <board type="KONAMI-VRC-2" mapper="23">
+   <chip type="Konami VRC II"><pin number="3" function="PRG A0"/><pin number="4" function="PRG A1"/></chip>
+  </board>
+ </cartridge>
+ <cartridge system="Famicom" crc="0000002c">
+  <board type="KONAMI-VRC-2" mapper="25">
+   <chip type="Konami VRC II"><pin number="3" function="PRG A1"/><pin number="4" function="PRG A0"/></chip>
+  </board>
+ </cartridge>
+ <cartridge system="Famicom" crc="000000ff">
+  <board type="KONAMI-VRC-4" mapper="25">
+   <chip type="Konami VRC IV"/>
+  </board>
+ </cartridge>
+</game>
+<game name="Other Game">
+ <peripherals><device type="zapper" name="Zapper"/></peripherals>
+ <cartridge system="NES-PAL-B" crc="12345678">
+  <board type="NES-NROM-256" mapper="0"><pad h="1" v="0"/></board>
+ </cartridge>
+ <cartridge system="NES-NTSC" crc="abcdef01">
+  <board type="NES-HKROM" mapper="4">
+   <wram size="1k" battery="1"/><chip type="MMC6B"/>
+  </board>
+ </cartridge>
+</game>
+</database>`
+
+func TestConvert(t *testing.T) {
+	var db database
+	if err := xml.Unmarshal([]byte(testXML), &db); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"0000000A\t21.1\tKONAMI-VRC-4\t-\t0\t0\tntsc\tstd\tVRC Game",
+		"0000000C\t21.2\tKONAMI-VRC-4\t-\t0\t0\tntsc\tstd\tVRC Game",
+		"0000000D\t25.2\tKONAMI-VRC-4\t-\t0\t0\tntsc\tstd\tVRC Game",
+		"0000000E\t23.2\tKONAMI-VRC-4\t-\t0\t0\tntsc\tstd\tVRC Game",
+		"0000002A\t22\tKONAMI-VRC-2\t-\t0\t0\tntsc\tstd\tVRC Game",
+		"0000002B\t23.3\tKONAMI-VRC-2\t-\t0\t0\tntsc\tstd\tVRC Game",
+		"0000002C\t25.3\tKONAMI-VRC-2\t-\t0\t0\tntsc\tstd\tVRC Game",
+		"000000FF\t25\tKONAMI-VRC-4\t-\t0\t0\tntsc\tstd\tVRC Game", // unknown wiring
+		"12345678\t0\tNES-NROM-256\tV\t0\t0\tpal\tzapper\tOther Game",
+		"ABCDEF01\t4.1\tNES-HKROM\t-\t1\t1\tntsc\tzapper\tOther Game",
+	}
+
+	testutil.Equal(t, strings.Join(convert(db), "\n"), strings.Join(want, "\n"))
+}
+
+// The embedded database must be what the generator produces from the excerpt
+// in testdata, so that it can be regenerated from the full export at any time.
+func TestConvert_GameDB(t *testing.T) {
+	data, err := os.ReadFile("testdata/NesCarts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var db database
+	if err := xml.Unmarshal(data, &db); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile("../../ines/gamedb.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, header+strings.Join(convert(db), "\n")+"\n", string(want))
+}
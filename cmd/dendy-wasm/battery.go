@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"syscall/js"
+
+	"github.com/maxpoletaev/dendy/ines"
+	"github.com/maxpoletaev/dendy/internal/battery"
+	"github.com/maxpoletaev/dendy/system"
+)
+
+// localStorage keeps the battery-backed cartridge RAM in the browser's
+// localStorage. The value is the base64-encoded raw RAM contents, same as the
+// .sav files used by the desktop version.
+type localStorage string
+
+func (key localStorage) Load() ([]byte, error) {
+	value := js.Global().Get("localStorage").Call("getItem", string(key))
+	if value.IsNull() {
+		return nil, nil
+	}
+
+	return base64.StdEncoding.DecodeString(value.String())
+}
+
+func (key localStorage) Store(data []byte) error {
+	value := base64.StdEncoding.EncodeToString(data)
+	js.Global().Get("localStorage").Call("setItem", string(key), value)
+
+	return nil
+}
+
+// batteryStorage persists the battery-backed cartridge RAM, keyed by the ROM
+// checksum. It is nil if the cartridge has no battery.
+type batteryStorage struct {
+	*battery.RAM
+	key localStorage
+}
+
+func newBatteryStorage(nes *system.System, rom *ines.ROM) *batteryStorage {
+	key := localStorage(fmt.Sprintf("dendy.sav.%08X", rom.CRC32))
+
+	ram := battery.New(nes.BatteryRAM(), key)
+	if ram == nil {
+		return nil
+	}
+
+	return &batteryStorage{RAM: ram, key: key}
+}
+
+func (b *batteryStorage) load() {
+	if b == nil {
+		return
+	}
+
+	if ok, err := b.Load(); err != nil {
+		log.Printf("[ERROR] invalid battery save %s: %s", b.key, err)
+	} else if ok {
+		log.Printf("[INFO] battery save loaded: %s", b.key)
+	}
+}
+
+func (b *batteryStorage) flush() {
+	if b == nil {
+		return
+	}
+
+	if _, err := b.Flush(); err != nil {
+		log.Printf("[ERROR] failed to write battery save %s: %s", b.key, err)
+	}
+}
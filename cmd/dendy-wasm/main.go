@@ -3,6 +3,7 @@ package main
 import (
 	_ "embed"
 	"fmt"
+	"image/color"
 	"log"
 	"syscall/js"
 	"unsafe"
@@ -10,6 +11,8 @@ import (
 	"github.com/maxpoletaev/dendy/consts"
 	"github.com/maxpoletaev/dendy/ines"
 	"github.com/maxpoletaev/dendy/input"
+	"github.com/maxpoletaev/dendy/ntsc"
+	"github.com/maxpoletaev/dendy/ppu"
 	"github.com/maxpoletaev/dendy/system"
 )
 
@@ -20,20 +23,25 @@ const (
 //go:embed nestest.nes
 var nestestROM []byte
 
-func create(joy *input.Joystick, romData []byte) (*system.System, error) {
+func create(joy *input.Joystick, romData []byte) (*system.System, *batteryStorage, error) {
 	rom, err := ines.NewFromBuffer(romData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load ROM: %v", err)
+		return nil, nil, fmt.Errorf("failed to load ROM: %v", err)
 	}
 
 	cart, err := ines.NewCartridge(rom)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cartridge: %v", err)
+		return nil, nil, fmt.Errorf("failed to create cartridge: %v", err)
 	}
 
 	zapper := input.NewZapper()
 	nes := system.New(cart, joy, zapper)
-	return nes, nil
+	nes.SetRegion(rom.Region)
+
+	battery := newBatteryStorage(nes, rom)
+	battery.load()
+
+	return nes, battery, nil
 }
 
 func main() {
@@ -41,7 +49,7 @@ func main() {
 	joystick := input.NewJoystick()
 	audioBuf := make([]float32, audioBufferSize)
 
-	nes, err := create(joystick, nestestROM)
+	nes, battery, err := create(joystick, nestestROM)
 	if err != nil {
 		log.Fatalf("[ERROR] failed to initialize: %v", err)
 	}
@@ -52,16 +60,35 @@ func main() {
 	jsapi.Set("AudioSampleRate", consts.AudioSamplesPerSecond)
 
 	var (
-		ticksCount  int
-		sampleCount int
+		ticksCount     int
+		sampleCount    int
+		ticksPerSample = nes.Region().Timing().TicksPerAudioSample()
+		palette        = ppu.DefaultPalette()
+		ntscEnabled    bool
+		dotRendering   bool
+		frameFilter    ppu.FrameFilter
+		frame          []color.RGBA // the last frame, converted with the filter
 	)
 
+	updateFrameFilter := func() {
+		if ntscEnabled {
+			frameFilter = ntsc.New(ppu.DefaultNTSCParams)
+		} else {
+			frameFilter = ppu.NewPaletteFilter(palette)
+		}
+
+		nes.SetFrameFilter(frameFilter)
+		frame = nes.Frame()
+	}
+
+	updateFrameFilter()
+
 	jsapi.Set("RunFrame", js.FuncOf(func(this js.Value, args []js.Value) any {
 		buttons := args[0].Int()
 		frameReady := false
 
 		for sampleCount < len(audioBuf) {
-			for ticksCount < consts.TicksPerAudioSample {
+			for ticksCount < ticksPerSample {
 				nes.Tick()
 				ticksCount++
 
@@ -76,6 +103,7 @@ func main() {
 			ticksCount = 0
 
 			if frameReady {
+				frame = nes.Frame()
 				return true
 			}
 		}
@@ -85,25 +113,104 @@ func main() {
 	}))
 
 	jsapi.Set("GetFrameBufferPtr", js.FuncOf(func(this js.Value, args []js.Value) any {
-		return uintptr(unsafe.Pointer(&nes.Frame()[0]))
+		return uintptr(unsafe.Pointer(&frame[0]))
+	}))
+
+	// The NTSC filter changes the frame size, the filtered frame is meant to
+	// be displayed at half the width.
+	jsapi.Set("GetFrameWidth", js.FuncOf(func(this js.Value, args []js.Value) any {
+		return len(frame) / ppu.FrameHeight
+	}))
+
+	jsapi.Set("GetFrameHeight", js.FuncOf(func(this js.Value, args []js.Value) any {
+		return ppu.FrameHeight
+	}))
+
+	jsapi.Set("SetNTSCFilter", js.FuncOf(func(this js.Value, args []js.Value) any {
+		ntscEnabled = args[0].Bool()
+		updateFrameFilter()
+
+		return nil
+	}))
+
+	jsapi.Set("SetDotRendering", js.FuncOf(func(this js.Value, args []js.Value) any {
+		dotRendering = args[0].Bool()
+		nes.SetDotRendering(dotRendering)
+
+		return nil
+	}))
+
+	jsapi.Set("GetFrameRate", js.FuncOf(func(this js.Value, args []js.Value) any {
+		return nes.Region().Timing().FramesPerSecond
 	}))
 
 	jsapi.Set("GetAudioBufferPtr", js.FuncOf(func(this js.Value, args []js.Value) any {
 		return uintptr(unsafe.Pointer(&audioBuf[0]))
 	}))
 
+	jsapi.Set("FlushBatteryRAM", js.FuncOf(func(this js.Value, args []js.Value) any {
+		battery.flush()
+		return nil
+	}))
+
+	jsapi.Set("GetPalettes", js.FuncOf(func(this js.Value, args []js.Value) any {
+		names := ppu.PaletteNames()
+
+		list := make([]any, len(names))
+		for i, name := range names {
+			list[i] = name
+		}
+
+		return list
+	}))
+
+	jsapi.Set("SetPalette", js.FuncOf(func(this js.Value, args []js.Value) any {
+		pal, ok := ppu.LookupPalette(args[0].String())
+		if !ok {
+			log.Printf("[ERROR] unknown palette: %s", args[0].String())
+			return false
+		}
+
+		palette = pal
+		updateFrameFilter()
+
+		return true
+	}))
+
+	jsapi.Set("LoadPaletteFile", js.FuncOf(func(this js.Value, args []js.Value) any {
+		data := js.Global().Get("Uint8Array").New(args[0])
+		palData := make([]byte, data.Length())
+		js.CopyBytesToGo(palData, data)
+
+		pal, err := ppu.ParsePalette(palData)
+		if err != nil {
+			log.Printf("[ERROR] failed to load palette: %v", err)
+			return false
+		}
+
+		palette = pal
+		updateFrameFilter()
+
+		return true
+	}))
+
 	jsapi.Set("LoadROM", js.FuncOf(func(this js.Value, args []js.Value) any {
 		data := js.Global().Get("Uint8Array").New(args[0])
 		romData := make([]byte, data.Length())
 		js.CopyBytesToGo(romData, data)
 
-		nes2, err := create(joystick, romData)
+		nes2, battery2, err := create(joystick, romData)
 		if err != nil {
 			log.Printf("[ERROR] failed to initialize: %v", err)
 			return false
 		}
 
-		nes = nes2
+		battery.flush()
+		nes, battery = nes2, battery2
+		ticksPerSample = nes.Region().Timing().TicksPerAudioSample()
+		nes.SetFrameFilter(frameFilter)
+		nes.SetDotRendering(dotRendering)
+
 		return true
 	}))
 
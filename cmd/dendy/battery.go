@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/maxpoletaev/dendy/internal/battery"
+	"github.com/maxpoletaev/dendy/system"
+)
+
+const (
+	batteryFlushInterval = 5 * time.Second
+)
+
+// batteryFile persists the battery-backed cartridge RAM in a .sav file.
+type batteryFile struct {
+	*battery.RAM
+	filename string
+	lastSave time.Time
+}
+
+// newBatteryFile returns nil if the cartridge has no battery.
+func newBatteryFile(nes *system.System, filename string) *batteryFile {
+	ram := battery.New(nes.BatteryRAM(), battery.File(filename))
+	if ram == nil {
+		return nil
+	}
+
+	return &batteryFile{
+		RAM:      ram,
+		filename: filename,
+	}
+}
+
+// autoFlush flushes the RAM to the file once in a while. It is safe to call on
+// every frame, and it does nothing if the cartridge has no battery.
+func (b *batteryFile) autoFlush() {
+	if b == nil || time.Since(b.lastSave) < batteryFlushInterval {
+		return
+	}
+
+	b.lastSave = time.Now()
+	flushBattery(b)
+}
+
+func loadBattery(b *batteryFile) {
+	if b == nil {
+		return
+	}
+
+	if ok, err := b.Load(); err != nil {
+		log.Printf("[ERROR] failed to load battery save: %s", err)
+	} else if ok {
+		log.Printf("[INFO] battery save loaded: %s", b.filename)
+	}
+}
+
+func flushBattery(b *batteryFile) {
+	if b == nil {
+		return
+	}
+
+	if ok, err := b.Flush(); err != nil {
+		log.Printf("[ERROR] failed to write battery save: %s", err)
+	} else if ok {
+		log.Printf("[DEBUG] battery save written: %s", b.filename)
+	}
+}
@@ -64,14 +64,17 @@ func joinSession(relayAddr string, sessionID string, romCRC32 uint32) (string, s
 	return lAddr.String(), rAddr.String(), nil
 }
 
-func runAsClient(cart ines.Cartridge, opts *options, rom *ines.ROM) {
+func runAsClient(cart ines.Cartridge, opts *options, rom *ines.ROM, region consts.Region) {
 	joy1 := input.NewJoystick()
 	joy2 := input.NewJoystick()
+	timing := region.Timing()
 
 	nes := system.New(cart, joy1, joy2)
+	nes.SetRegion(region)
 	nes.SetNoSpriteLimit(opts.noSpriteLimit)
+	nes.SetDotRendering(opts.dotRender)
 
-	audio := ui.CreateAudio(consts.AudioSamplesPerSecond, consts.AudioSampleSize, 1, consts.AudioBufferSize)
+	audio := ui.CreateAudio(consts.AudioSamplesPerSecond, consts.AudioSampleSize, 1, timing.AudioBufferSize())
 	defer audio.Close()
 	audio.Mute(opts.mute)
 
@@ -134,8 +137,8 @@ func runAsClient(cart ines.Cartridge, opts *options, rom *ines.ROM) {
 	win := ui.CreateWindow(opts.scale, opts.verbose)
 	defer win.Close()
 
-	win.SetTitle(fmt.Sprintf("%s (P2)", windowTitle))
-	win.SetFrameRate(consts.FramesPerSecond)
+	win.SetTitle(fmt.Sprintf("%s (P2)", gameWindowTitle(rom)))
+	win.SetFrameRate(timing.FramesPerSecond)
 	win.InputDelegate = sess.SendButtons
 	win.MuteDelegate = audio.ToggleMute
 	win.ShowFPS = opts.showFPS
@@ -146,6 +149,8 @@ func runAsClient(cart ines.Cartridge, opts *options, rom *ines.ROM) {
 		win.EnableCRT()
 	}
 
+	opts.setupVideo(win, nes)
+
 	for {
 		startTime := time.Now()
 
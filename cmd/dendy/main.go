@@ -13,6 +13,10 @@ import (
 	"github.com/maxpoletaev/dendy/genie"
 	"github.com/maxpoletaev/dendy/ines"
 	"github.com/maxpoletaev/dendy/internal/loglevel"
+	"github.com/maxpoletaev/dendy/ntsc"
+	"github.com/maxpoletaev/dendy/ppu"
+	"github.com/maxpoletaev/dendy/system"
+	"github.com/maxpoletaev/dendy/ui"
 )
 
 const (
@@ -22,6 +26,7 @@ const (
 type options struct {
 	scale         int
 	noSpriteLimit bool
+	dotRender     bool
 	saveFile      string
 	noSave        bool
 	showFPS       bool
@@ -34,6 +39,13 @@ type options struct {
 	mute          bool
 	noLogo        bool
 	noCRT         bool
+	ntsc          bool
+	region        string
+	fdsBIOS       string
+	romEntry      string
+	patchFile     string
+	palette       string
+	colors        *ppu.Palette // loaded from the palette flag
 
 	connectAddr string
 	listenAddr  string
@@ -46,12 +58,19 @@ func (o *options) parse() *options {
 	flag.IntVar(&o.scale, "scale", 2, "scale factor (default: 2)")
 	flag.StringVar(&o.saveFile, "savefile", "", "save file (default: romname.save)")
 	flag.BoolVar(&o.noSpriteLimit, "nospritelimit", false, "disable sprite limit (eliminates flickering)")
+	flag.BoolVar(&o.dotRender, "dotrender", false, "render dot by dot (slower, but accurate mid-scanline effects)")
 	flag.BoolVar(&o.noSave, "nosave", false, "disable save states")
 	flag.BoolVar(&o.showFPS, "showfps", false, "show fps counter")
 	flag.BoolVar(&o.mute, "mute", false, "disable apu emulation")
 	flag.BoolVar(&o.noLogo, "nologo", false, "do not print logo")
 	flag.BoolVar(&o.noCRT, "nocrt", false, "disable CRT effect")
+	flag.BoolVar(&o.ntsc, "ntsc", false, "emulate NTSC composite video (color artifacts, dot crawl, 8:7 aspect ratio)")
 	flag.StringVar(&o.gg, "gg", "", "game genie codes (comma separated)")
+	flag.StringVar(&o.region, "region", "auto", "console region (auto, ntsc, pal, dendy)")
+	flag.StringVar(&o.fdsBIOS, "fdsbios", "", "famicom disk system bios (default: disksys.rom next to the disk image)")
+	flag.StringVar(&o.romEntry, "romentry", "", "file to load from a zip archive (default: first rom file)")
+	flag.StringVar(&o.patchFile, "patch", "", "ips, ups or bps patch (default: romname.ips/ups/bps if exists)")
+	flag.StringVar(&o.palette, "palette", "default", "color palette ("+strings.Join(ppu.PaletteNames(), ", ")+") or .pal file")
 
 	flag.StringVar(&o.protocol, "protocol", "tcp", "netplay protocol (tcp, udp)")
 	flag.StringVar(&o.listenAddr, "listen", "", "netplay listen address")
@@ -76,6 +95,124 @@ func (o *options) sanitize() {
 	}
 }
 
+// consoleRegion returns the region selected with the -region flag, or the one
+// from the ROM header when it is set to auto.
+func (o *options) consoleRegion(rom *ines.ROM) (consts.Region, error) {
+	if o.region == "" || o.region == "auto" {
+		return rom.Region, nil
+	}
+
+	return consts.ParseRegion(o.region)
+}
+
+// loadROM loads either a cartridge or a disk image, depending on the extension.
+// The file may also be a zip or gzip archive with the image inside. The patch
+// is applied to the image when given.
+func (o *options) loadROM(romFile, patchFile string) (*ines.ROM, error) {
+	data, name, err := ines.ReadROMFile(romFile, o.romEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	if patchFile != "" {
+		log.Printf("[INFO] applying patch: %s", patchFile)
+
+		patch, err := os.ReadFile(patchFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read patch: %w", err)
+		}
+
+		if data, err = ines.ApplyPatch(data, patch); err != nil {
+			return nil, fmt.Errorf("failed to apply patch: %w", err)
+		}
+	}
+
+	if !strings.EqualFold(filepath.Ext(name), ".fds") {
+		return ines.NewFromBuffer(data)
+	}
+
+	biosFile := o.fdsBIOS
+	if biosFile == "" {
+		biosFile = filepath.Join(filepath.Dir(romFile), "disksys.rom")
+	}
+
+	bios, err := os.ReadFile(biosFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FDS BIOS: %w", err)
+	}
+
+	return ines.NewFromFDSBuffer(data, bios)
+}
+
+// loadPalette returns the palette selected with the -palette flag, which is
+// either the name of a built-in palette or a .pal file.
+func (o *options) loadPalette() (*ppu.Palette, error) {
+	if pal, ok := ppu.LookupPalette(o.palette); ok {
+		return pal, nil
+	}
+
+	data, err := os.ReadFile(o.palette)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read palette: %w", err)
+	}
+
+	return ppu.ParsePalette(data)
+}
+
+// findPatch returns the patch selected with the -patch flag, or the one with
+// the same name as the ROM, if there is any.
+func (o *options) findPatch(romFile string) string {
+	if o.patchFile != "" {
+		return o.patchFile
+	}
+
+	prefix := romBaseName(romFile)
+
+	for _, ext := range ines.PatchExtensions {
+		if _, err := os.Stat(prefix + ext); err == nil {
+			return prefix + ext
+		}
+	}
+
+	return ""
+}
+
+// romBaseName returns the ROM file path without the extension, which is used to
+// name the save files. For gzip files, the inner extension is removed as well,
+// so that "game.nes.gz" shares the save files with "game.nes".
+func romBaseName(romFile string) string {
+	prefix := strings.TrimSuffix(romFile, filepath.Ext(romFile))
+
+	if strings.EqualFold(filepath.Ext(romFile), ".gz") {
+		prefix = strings.TrimSuffix(prefix, filepath.Ext(prefix))
+	}
+
+	return prefix
+}
+
+// gameWindowTitle returns the window title, with the game name if it is known
+// from the game database.
+func gameWindowTitle(rom *ines.ROM) string {
+	if rom.Title == "" {
+		return windowTitle
+	}
+
+	return fmt.Sprintf("%s - %s", rom.Title, windowTitle)
+}
+
+// setupVideo sets the frame filter selected with the flags: either the NTSC
+// filter, or the colors from the palette.
+func (o *options) setupVideo(w *ui.Window, nes *system.System) {
+	if o.ntsc {
+		nes.SetFrameFilter(ntsc.New(ppu.DefaultNTSCParams))
+		w.SetFrameSize(ntsc.Width, ntsc.Height, ntsc.Width/2)
+
+		return
+	}
+
+	nes.SetFrameFilter(ppu.NewPaletteFilter(o.colors))
+}
+
 func (o *options) logLevel() loglevel.Level {
 	if o.verbose {
 		return loglevel.LevelDebug
@@ -149,7 +286,9 @@ func main() {
 	romFile := flag.Arg(0)
 	log.Printf("[INFO] loading rom file: %s", romFile)
 
-	rom, err := ines.NewFromFile(romFile)
+	patchFile := opts.findPatch(romFile)
+
+	rom, err := opts.loadROM(romFile, patchFile)
 	if err != nil {
 		log.Printf("[ERROR] failed to open rom file: %s", err)
 		os.Exit(1)
@@ -161,6 +300,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	region, err := opts.consoleRegion(rom)
+	if err != nil {
+		log.Printf("[ERROR] invalid region: %s", err)
+		os.Exit(1)
+	}
+
+	log.Printf("[INFO] using %s timing", strings.ToUpper(region.String()))
+
+	if opts.colors, err = opts.loadPalette(); err != nil {
+		log.Printf("[ERROR] invalid palette: %s", err)
+		os.Exit(1)
+	}
+
 	// Game Genie was a cartridge pass-through device, and we emulate
 	// it as a cartridge pass-through device. How cool is that?
 	if opts.gg != "" {
@@ -178,12 +330,20 @@ func main() {
 	}
 
 	saveFile := opts.saveFile
-	romPrefix := strings.TrimSuffix(romFile, filepath.Ext(romFile))
+	romPrefix := romBaseName(romFile)
+
+	// The patched ROM has a different CRC32, so its save files should not
+	// clash with the ones of the original ROM.
+	if patchFile != "" {
+		romPrefix = strings.TrimSuffix(patchFile, filepath.Ext(patchFile))
+	}
+
+	batteryFile := romPrefix + ".sav"
 
 	switch {
 	case opts.connectAddr != "" || opts.joinRoom != "":
 		log.Printf("[INFO] starting client mode")
-		runAsClient(cart, opts, rom)
+		runAsClient(cart, opts, rom, region)
 
 	case opts.listenAddr != "" || opts.createRoom:
 		if saveFile == "" {
@@ -191,7 +351,7 @@ func main() {
 		}
 
 		log.Printf("[INFO] starting host mode")
-		runAsServer(cart, opts, saveFile, rom)
+		runAsServer(cart, opts, saveFile, batteryFile, rom, region)
 
 	default:
 		if saveFile == "" {
@@ -199,6 +359,6 @@ func main() {
 		}
 
 		log.Printf("[INFO] starting offline mode")
-		runOffline(cart, opts, saveFile)
+		runOffline(cart, opts, saveFile, batteryFile, rom, region)
 	}
 }
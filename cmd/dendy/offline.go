@@ -67,14 +67,23 @@ func saveState(nes *system.System, saveFile string) error {
 	return nil
 }
 
-func runOffline(cart ines.Cartridge, opts *options, saveFile string) {
+func runOffline(cart ines.Cartridge, opts *options, saveFile, batteryFile string, rom *ines.ROM, region consts.Region) {
 	joy1 := input.NewJoystick()
 	zapper := input.NewZapper()
+	timing := region.Timing()
 
 	nes := system.New(cart, joy1, zapper)
+	nes.SetRegion(region)
 	nes.SetNoSpriteLimit(opts.noSpriteLimit)
+	nes.SetDotRendering(opts.dotRender)
 	nes.SetRewindEnabled(true)
 
+	// The save state loaded below overrides the RAM from the .sav file, since
+	// it has its own copy, which is then written to the .sav file on flush.
+	battery := newBatteryFile(nes, batteryFile)
+	loadBattery(battery)
+	defer flushBattery(battery)
+
 	if opts.disasm != "" {
 		var file io.Writer
 
@@ -119,26 +128,39 @@ func runOffline(cart ines.Cartridge, opts *options, saveFile string) {
 	w := ui.CreateWindow(opts.scale, opts.verbose)
 	defer w.Close()
 
-	audio := ui.CreateAudio(consts.AudioSamplesPerSecond, consts.AudioSampleSize, 1, consts.AudioBufferSize)
-	audioBuffer := make([]float32, consts.AudioBufferSize)
+	audio := ui.CreateAudio(consts.AudioSamplesPerSecond, consts.AudioSampleSize, 1, timing.AudioBufferSize())
+	audioBuffer := make([]float32, timing.AudioBufferSize())
 	audio.Mute(opts.mute)
 	defer audio.Close()
 
-	w.SetFrameRate(consts.FramesPerSecond)
-	w.SetTitle(windowTitle)
+	w.SetFrameRate(timing.FramesPerSecond)
+	w.SetTitle(gameWindowTitle(rom))
 
 	w.InputDelegate = joy1.SetButtons
 	w.ZapperDelegate = zapper.Update
 	w.MuteDelegate = audio.ToggleMute
 	w.RewindDelegate = nes.Rewind
 	w.ResetDelegate = nes.Reset
+
+	// Not available in netplay, where both sides must use the same renderer.
+	w.RenderDelegate = func() {
+		opts.dotRender = !opts.dotRender
+		nes.SetDotRendering(opts.dotRender)
+		log.Printf("[INFO] dot rendering: %t", opts.dotRender)
+	}
 	w.ShowFPS = opts.showFPS
 
+	if drive, ok := ines.As[ines.DiskDrive](cart); ok {
+		w.DiskDelegate = drive.SwitchSide
+	}
+
 	if !opts.noCRT {
 		log.Printf("[INFO] using experimental CRT effect, disable with -nocrt flag")
 		w.EnableCRT()
 	}
 
+	opts.setupVideo(w, nes)
+
 	defer func() {
 		if err := recover(); err != nil {
 			// Save state on crash to quickly reconstruct the faulty state,
@@ -152,14 +174,16 @@ func runOffline(cart ines.Cartridge, opts *options, saveFile string) {
 		}
 	}()
 
+	ticksPerSample := timing.TicksPerAudioSample()
+
 gameloop:
 	for {
-		for i := 0; i < consts.AudioBufferSize; i++ {
-			for j := 0; j < consts.TicksPerAudioSample; j++ {
+		for i := 0; i < len(audioBuffer); i++ {
+			for j := 0; j < ticksPerSample; j++ {
 				nes.Tick()
 
 				if nes.ScanlineReady() {
-					w.UpdateZapper(nes.Frame())
+					w.UpdateZapper(nes.FrameIndices())
 				}
 
 				if nes.FrameReady() {
@@ -168,11 +192,14 @@ gameloop:
 					}
 
 					zapper.VBlank()
+					battery.autoFlush()
 
 					w.UpdateJoystick()
 					w.HandleHotKeys()
 					w.SetGrayscale(false)
-					w.Refresh(nes.Frame())
+
+					frame := nes.Frame()
+					w.Refresh(frame)
 
 					// Pause when not in focus.
 					for !w.InFocus() {
@@ -181,7 +208,7 @@ gameloop:
 						}
 
 						w.SetGrayscale(true)
-						w.Refresh(nes.Frame())
+						w.Refresh(frame)
 					}
 				}
 			}
@@ -34,7 +34,7 @@ func printSessionID(sessionID string) {
 	fmt.Println(strings.Repeat("-", width+4))
 }
 
-func createSession(relayAddr string, romCRC32 uint32, public bool) (string, error) {
+func createSession(relayAddr string, romCRC32 uint32, gameTitle string, public bool) (string, error) {
 	log.Printf("[INFO] connecting to relay server: %s", relayAddr)
 
 	relayClient, err := relay.Connect(relayAddr)
@@ -50,7 +50,7 @@ func createSession(relayAddr string, romCRC32 uint32, public bool) (string, erro
 
 	log.Printf("[INFO] creating session...")
 
-	sessionID, err := relayClient.CreateSession(romCRC32, public)
+	sessionID, err := relayClient.CreateSession(romCRC32, gameTitle, public)
 	if err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
 	}
@@ -82,12 +82,21 @@ func createSession(relayAddr string, romCRC32 uint32, public bool) (string, erro
 	return lAddr.String(), nil
 }
 
-func runAsServer(cart ines.Cartridge, opts *options, saveFile string, rom *ines.ROM) {
+func runAsServer(cart ines.Cartridge, opts *options, saveFile, batteryFile string, rom *ines.ROM, region consts.Region) {
 	joy1 := input.NewJoystick()
 	joy2 := input.NewJoystick()
+	timing := region.Timing()
 
 	nes := system.New(cart, joy1, joy2)
+	nes.SetRegion(region)
 	nes.SetNoSpriteLimit(opts.noSpriteLimit)
+	nes.SetDotRendering(opts.dotRender)
+
+	// The save state loaded below overrides the RAM from the .sav file, since
+	// it has its own copy, which is then written to the .sav file on flush.
+	battery := newBatteryFile(nes, batteryFile)
+	loadBattery(battery)
+	defer flushBattery(battery)
 
 	if !opts.noSave {
 		if ok, err := loadState(nes, saveFile); err != nil {
@@ -98,7 +107,7 @@ func runAsServer(cart ines.Cartridge, opts *options, saveFile string, rom *ines.
 		}
 	}
 
-	audio := ui.CreateAudio(consts.AudioSamplesPerSecond, consts.AudioSampleSize, 1, consts.AudioBufferSize)
+	audio := ui.CreateAudio(consts.AudioSamplesPerSecond, consts.AudioSampleSize, 1, timing.AudioBufferSize())
 	defer audio.Close()
 	audio.Mute(opts.mute)
 
@@ -132,7 +141,7 @@ func runAsServer(cart ines.Cartridge, opts *options, saveFile string, rom *ines.
 	)
 
 	if opts.createRoom {
-		listenAddr, err = createSession(opts.relayAddr, rom.CRC32, false)
+		listenAddr, err = createSession(opts.relayAddr, rom.CRC32, rom.Title, false)
 		if err != nil {
 			log.Printf("[ERROR] failed to create relay session: %s", err)
 			os.Exit(1)
@@ -157,8 +166,8 @@ func runAsServer(cart ines.Cartridge, opts *options, saveFile string, rom *ines.
 	w := ui.CreateWindow(opts.scale, opts.verbose)
 	defer w.Close()
 
-	w.SetTitle(fmt.Sprintf("%s (P1)", windowTitle))
-	w.SetFrameRate(consts.FramesPerSecond)
+	w.SetTitle(fmt.Sprintf("%s (P1)", gameWindowTitle(rom)))
+	w.SetFrameRate(timing.FramesPerSecond)
 	w.ResyncDelegate = sess.SendResync
 	w.InputDelegate = sess.SendButtons
 	w.ResetDelegate = sess.SendReset
@@ -171,6 +180,8 @@ func runAsServer(cart ines.Cartridge, opts *options, saveFile string, rom *ines.
 		w.EnableCRT()
 	}
 
+	opts.setupVideo(w, nes)
+
 	for {
 		startTime := time.Now()
 
@@ -193,6 +204,7 @@ func runAsServer(cart ines.Cartridge, opts *options, saveFile string, rom *ines.
 		sess.RunFrame(startTime)
 
 		w.Refresh(nes.Frame())
+		battery.autoFlush()
 	}
 
 	if !opts.noSave {
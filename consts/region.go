@@ -0,0 +1,134 @@
+package consts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Region is the TV system the console was designed for. It determines the
+// clock rates, the number of scanlines per frame and the frame rate.
+type Region uint8
+
+const (
+	RegionNTSC  Region = iota // US/Japan: 60 Hz, 262 scanlines
+	RegionPAL                 // Europe/Australia: 50 Hz, 312 scanlines
+	RegionDendy               // Famicom clones (Dendy): 50 Hz, 312 scanlines with NTSC-like CPU/PPU ratio
+)
+
+var regionNames = map[Region]string{
+	RegionNTSC:  "ntsc",
+	RegionPAL:   "pal",
+	RegionDendy: "dendy",
+}
+
+func (r Region) String() string {
+	if name, ok := regionNames[r]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("region(%d)", r)
+}
+
+// ParseRegion returns the region with the given name (ntsc, pal or dendy).
+func ParseRegion(name string) (Region, error) {
+	for r, n := range regionNames {
+		if strings.EqualFold(name, n) {
+			return r, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown region: %s", name)
+}
+
+// Timing describes the clock rates and video timing of a region.
+type Timing struct {
+	FramesPerSecond   int
+	CPUTicksPerSecond int
+
+	// The PPU makes PPUDots ticks every CPUCycles CPU cycles. On NTSC and Dendy
+	// it is exactly 3 dots per cycle, on PAL it is 3.2 (16 dots per 5 cycles).
+	PPUDots   int
+	CPUCycles int
+
+	// Scanlines is the total number of scanlines per frame, including the
+	// pre-render one. VBlankScanline is the line where the vblank flag is set
+	// and the NMI is triggered.
+	Scanlines      int
+	VBlankScanline int
+
+	// SkipOddFrameDot is true if the PPU skips the first dot of the first
+	// scanline on odd frames (only NTSC does this).
+	SkipOddFrameDot bool
+
+	// SwapEmphasis is true if the red and green color emphasis bits of the
+	// PPU mask register are swapped (PAL and Dendy).
+	SwapEmphasis bool
+}
+
+var timings = [...]Timing{
+	RegionNTSC: {
+		FramesPerSecond:   60 * Speed,
+		CPUTicksPerSecond: 1789773 * Speed,
+		PPUDots:           3,
+		CPUCycles:         1,
+		Scanlines:         262,
+		VBlankScanline:    241,
+		SkipOddFrameDot:   true,
+	},
+	RegionPAL: {
+		FramesPerSecond:   50 * Speed,
+		CPUTicksPerSecond: 1662607 * Speed,
+		PPUDots:           16,
+		CPUCycles:         5,
+		Scanlines:         312,
+		VBlankScanline:    241,
+		SwapEmphasis:      true,
+	},
+	RegionDendy: {
+		// Dendy is a PAL machine, but its vblank starts 50 lines later, leaving the
+		// NMI handler the same amount of time as on NTSC, and the CPU is clocked
+		// at 1/15 of the master clock to keep the NTSC 3:1 ratio.
+		FramesPerSecond:   50 * Speed,
+		CPUTicksPerSecond: 1773448 * Speed,
+		PPUDots:           3,
+		CPUCycles:         1,
+		Scanlines:         312,
+		VBlankScanline:    291,
+		SwapEmphasis:      true,
+	},
+}
+
+// Timing returns the timing parameters of the region.
+func (r Region) Timing() Timing {
+	if int(r) >= len(timings) {
+		return timings[RegionNTSC]
+	}
+
+	return timings[r]
+}
+
+// TicksPerSecond returns the number of PPU ticks (system clock ticks) per second.
+func (t Timing) TicksPerSecond() int {
+	return t.CPUTicksPerSecond * t.PPUDots / t.CPUCycles
+}
+
+// FrameDuration returns the duration of a single frame.
+func (t Timing) FrameDuration() time.Duration {
+	return time.Second / time.Duration(t.FramesPerSecond)
+}
+
+// TicksPerAudioSample returns the number of system ticks between two audio samples.
+func (t Timing) TicksPerAudioSample() int {
+	return t.TicksPerSecond() / AudioSamplesPerSecond
+}
+
+// AudioSamplesPerFrame returns the number of audio samples generated per frame.
+func (t Timing) AudioSamplesPerFrame() int {
+	return AudioSamplesPerSecond / t.FramesPerSecond
+}
+
+// AudioBufferSize returns the size of the audio buffer (3 frames worth of samples).
+func (t Timing) AudioBufferSize() int {
+	return t.AudioSamplesPerFrame() * 3
+}
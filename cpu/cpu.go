@@ -155,9 +155,9 @@ func (cpu *CPU) irq(mem Memory) {
 }
 
 // TriggerIRQ triggers an interrupt on the next CPU cycle.
-// If the interrupt flag is set, the interrupt is ignored.
+// If the interrupt flag is set, or there is a pending NMI, the interrupt is ignored.
 func (cpu *CPU) TriggerIRQ() {
-	if cpu.getFlag(flagInterrupt) {
+	if cpu.getFlag(flagInterrupt) || cpu.interrupt == interruptNMI {
 		return
 	}
 
@@ -0,0 +1,74 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+type testMemory [0x10000]uint8
+
+func (m *testMemory) Read(addr uint16) uint8 {
+	return m[addr]
+}
+
+func (m *testMemory) Write(addr uint16, data uint8) {
+	m[addr] = data
+}
+
+func newTestMemory() *testMemory {
+	mem := &testMemory{}
+
+	mem[0x8000] = 0xEA // NOP
+	mem[0x9000] = 0xEA // NOP
+	mem[0xA000] = 0xEA // NOP
+
+	mem[vecNMI] = 0x00
+	mem[vecNMI+1] = 0x90
+	mem[vecIRQ] = 0x00
+	mem[vecIRQ+1] = 0xA0
+
+	return mem
+}
+
+func TestCPU_TriggerIRQ(t *testing.T) {
+	mem := newTestMemory()
+	cpu := New()
+	cpu.PC = 0x8000
+	cpu.SP = 0xFD
+
+	cpu.TriggerIRQ()
+	cpu.Tick(mem)
+
+	testutil.Equal(t, cpu.PC, 0xA001)
+	testutil.Equal(t, cpu.getFlag(flagInterrupt), true)
+}
+
+func TestCPU_TriggerIRQ_Disabled(t *testing.T) {
+	mem := newTestMemory()
+	cpu := New()
+	cpu.PC = 0x8000
+	cpu.SP = 0xFD
+	cpu.P = flagInterrupt
+
+	cpu.TriggerIRQ()
+	cpu.Tick(mem)
+
+	testutil.Equal(t, cpu.PC, 0x8001)
+}
+
+func TestCPU_TriggerIRQ_PendingNMI(t *testing.T) {
+	mem := newTestMemory()
+	cpu := New()
+	cpu.PC = 0x8000
+	cpu.SP = 0xFD
+
+	// A level-triggered IRQ line is polled on every cycle, so the IRQ may be
+	// raised again while the NMI is still waiting for the current instruction
+	// to finish. It must not replace the NMI.
+	cpu.TriggerNMI()
+	cpu.TriggerIRQ()
+	cpu.Tick(mem)
+
+	testutil.Equal(t, cpu.PC, 0x9001)
+}
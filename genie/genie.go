@@ -11,6 +11,7 @@ import (
 
 var (
 	_ ines.Cartridge = (*GameGenie)(nil)
+	_ ines.Wrapper   = (*GameGenie)(nil)
 )
 
 type override struct {
@@ -50,6 +51,11 @@ func (gg *GameGenie) ApplyCode(code string) error {
 	return nil
 }
 
+// Unwrap returns the cartridge plugged into the Game Genie.
+func (gg *GameGenie) Unwrap() ines.Cartridge {
+	return gg.cart
+}
+
 func (gg *GameGenie) Reset() {
 	gg.cart.Reset()
 }
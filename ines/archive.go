@@ -0,0 +1,144 @@
+package ines
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNoROMInArchive = errors.New("no ROM file found in the archive")
+)
+
+// romExtensions are the file extensions looked for in zip archives.
+var romExtensions = []string{".nes", ".fds", ".unf", ".unif"}
+
+// maxROMSize is a sanity limit for the extracted size, so that a broken or
+// malicious archive cannot make us allocate gigabytes of memory.
+const maxROMSize = 32 << 20
+
+// ReadROMFile reads a ROM file, transparently extracting it from a zip or gzip
+// archive. For zip archives, entry selects the file to extract, otherwise the
+// first one with a known ROM extension is used. The returned name is the name
+// of the extracted file (or the original one), so that the caller can tell
+// what kind of image it is.
+func ReadROMFile(filename, entry string) (data []byte, name string, err error) {
+	data, err = os.ReadFile(filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return readZipEntry(data, entry)
+	case bytes.HasPrefix(data, []byte{0x1F, 0x8B}):
+		return readGzip(data, filename)
+	default:
+		return data, filename, nil
+	}
+}
+
+func readZipEntry(data []byte, entry string) ([]byte, string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open zip archive: %w", err)
+	}
+
+	var file *zip.File
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		if entry != "" {
+			// Allow to omit the directory inside the archive.
+			if f.Name == entry || path.Base(f.Name) == entry {
+				file = f
+				break
+			}
+
+			continue
+		}
+
+		ext := strings.ToLower(path.Ext(f.Name))
+		for _, romExt := range romExtensions {
+			if ext == romExt {
+				file = f
+				break
+			}
+		}
+
+		if file != nil {
+			break
+		}
+	}
+
+	if file == nil {
+		if entry != "" {
+			return nil, "", fmt.Errorf("%w: %s", ErrNoROMInArchive, entry)
+		}
+
+		return nil, "", ErrNoROMInArchive
+	}
+
+	r, err := file.Open()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to extract %s: %w", file.Name, err)
+	}
+
+	defer func() {
+		_ = r.Close()
+	}()
+
+	rom, err := readAllLimited(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to extract %s: %w", file.Name, err)
+	}
+
+	return rom, file.Name, nil
+}
+
+func readGzip(data []byte, filename string) ([]byte, string, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open gzip file: %w", err)
+	}
+
+	defer func() {
+		_ = r.Close()
+	}()
+
+	rom, err := readAllLimited(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decompress gzip file: %w", err)
+	}
+
+	// The original name is optional in the gzip header.
+	name := r.Name
+	if name == "" {
+		name = strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+
+	return rom, name, nil
+}
+
+func readAllLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxROMSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxROMSize {
+		return nil, errors.New("file is too big")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,97 @@
+package ines
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func writeZip(t *testing.T, filename string, files map[string][]byte, order []string) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	for _, name := range order {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadROMFile_Zip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "games.zip")
+
+	files := map[string][]byte{
+		"readme.txt":     []byte("hello"),
+		"roms/game1.nes": []byte("game1"),
+		"roms/game2.NES": []byte("game2"),
+	}
+
+	writeZip(t, filename, files, []string{"readme.txt", "roms/game1.nes", "roms/game2.NES"})
+
+	// The first ROM file is picked by default.
+	data, name, err := ReadROMFile(filename, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, name, "roms/game1.nes")
+	testutil.Equal(t, string(data), "game1")
+
+	// The entry can be given without the directory.
+	data, name, err = ReadROMFile(filename, "game2.NES")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, name, "roms/game2.NES")
+	testutil.Equal(t, string(data), "game2")
+
+	_, _, err = ReadROMFile(filename, "game3.nes")
+	testutil.Equal(t, errors.Is(err, ErrNoROMInArchive), true)
+}
+
+func TestReadROMFile_Gzip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "game.nes.gz")
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+
+	if _, err := w.Write([]byte("game")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filename, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, name, err := ReadROMFile(filename, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, name, filepath.Join(filepath.Dir(filename), "game.nes"))
+	testutil.Equal(t, string(data), "game")
+}
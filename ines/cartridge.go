@@ -2,6 +2,7 @@ package ines
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/maxpoletaev/dendy/internal/binario"
 )
@@ -30,6 +31,20 @@ type Cartridge interface {
 }
 
 func NewCartridge(rom *ROM) (Cartridge, error) {
+	cart, err := newMapper(rom)
+	if err != nil {
+		return nil, err
+	}
+
+	// The trainer is loaded into the PRG-RAM, which not every board has.
+	if rom.Trainer != nil && !rom.trainerLoaded {
+		log.Printf("[WARN] mapper %d has no PRG-RAM at $7000, the trainer is not loaded", rom.MapperID)
+	}
+
+	return cart, nil
+}
+
+func newMapper(rom *ROM) (Cartridge, error) {
 	switch rom.MapperID {
 	case 0:
 		return NewMapper0(rom), nil
@@ -41,9 +56,179 @@ func NewCartridge(rom *ROM) (Cartridge, error) {
 		return NewMapper3(rom), nil
 	case 4:
 		return NewMapper4(rom), nil
+	case 5:
+		return NewMapper5(rom), nil
 	case 7:
 		return NewMapper7(rom), nil
+	case 9:
+		return NewMapper9(rom), nil
+	case 10:
+		return NewMapper10(rom), nil
+	case 11:
+		return NewMapper11(rom), nil
+	case 15:
+		return NewMapper15(rom), nil
+	case 16, 153, 159:
+		return NewMapper16(rom), nil
+	case 19:
+		return NewMapper19(rom), nil
+	case 20:
+		return NewMapper20(rom), nil
+	case 21, 22, 23, 25:
+		return NewMapper21(rom), nil
+	case 24:
+		return NewMapper24(rom, false), nil
+	case 26:
+		return NewMapper24(rom, true), nil
+	case 34:
+		return NewMapper34(rom), nil
+	case 66:
+		return NewMapper66(rom), nil
+	case 69:
+		return NewMapper69(rom), nil
+	case 71:
+		return NewMapper71(rom), nil
+	case 79:
+		return NewMapper79(rom), nil
+	case 85:
+		return NewMapper85(rom), nil
+	case 90:
+		return NewMapper90(rom, jyNametablesNone), nil
+	case 91:
+		return NewMapper91(rom), nil
+	case 209:
+		return NewMapper90(rom, jyNametablesOptional), nil
+	case 211:
+		return NewMapper90(rom, jyNametablesAlways), nil
+	case 118:
+		return NewMapper118(rom), nil
+	case 119:
+		return NewMapper119(rom), nil
+	case 225, 255:
+		return NewMapper225(rom), nil
+	case 226:
+		return NewMapper226(rom), nil
+	case 227:
+		return NewMapper227(rom), nil
+	case 232:
+		return NewMapper232(rom), nil
 	default:
 		return nil, fmt.Errorf("unsupported mapper: %d", rom.MapperID)
 	}
 }
+
+// BatteryBacked is implemented by cartridges that may have battery-backed
+// PRG-RAM, which is persisted between sessions in .sav files.
+type BatteryBacked interface {
+	// BatteryRAM returns the battery-backed memory, or nil if the cartridge has
+	// no battery. The slice is owned by the cartridge and can be modified in place.
+	BatteryRAM() []byte
+}
+
+// Wrapper is implemented by pass-through devices (like Game Genie) that sit
+// between the console and the actual cartridge.
+type Wrapper interface {
+	// Unwrap returns the cartridge plugged into the device.
+	Unwrap() Cartridge
+}
+
+// As returns the first cartridge in the pass-through chain that implements T.
+// It is used to look up optional cartridge capabilities, such as BatteryBacked.
+func As[T any](cart Cartridge) (T, bool) {
+	for {
+		if v, ok := cart.(T); ok {
+			return v, true
+		}
+
+		w, ok := cart.(Wrapper)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+
+		cart = w.Unwrap()
+	}
+}
+
+// DiskDrive is implemented by cartridges with swappable media, which is only
+// the Famicom Disk System with its double-sided disks.
+type DiskDrive interface {
+	// SwitchSide ejects the current disk side and inserts the next one.
+	SwitchSide()
+}
+
+// CPUTicker is implemented by cartridges that need to be clocked on every CPU
+// cycle, such as the ones with cycle-based IRQ counters (VRC, FME-7, Namco 163).
+// Cartridges that don't implement it are not called at all, so it costs nothing
+// to those that only need ScanlineTick.
+//
+// For such cartridges, PendingIRQ is polled after every tick and is expected to
+// reflect the state of the IRQ line, rather than being a one-time event. The line
+// stays asserted until the game acknowledges the interrupt.
+type CPUTicker interface {
+	// TickCPU advances the cartridge by one CPU cycle.
+	TickCPU()
+}
+
+// FetchKind describes what the PPU is currently fetching from the cartridge.
+type FetchKind uint8
+
+const (
+	FetchIdle       FetchKind = iota // not rendering, only CPU accesses via $2007
+	FetchBackground                  // background tiles of the current scanline
+	FetchSprite                      // sprites of the next scanline
+	FetchPeek                        // not a real fetch, the cartridge state must not change
+)
+
+// PPUEvent is a kind of PPU bus activity that can be reported to the cartridge.
+type PPUEvent uint8
+
+const (
+	// EventFetchStart is reported before the PPU starts a series of fetches of
+	// the given kind.
+	EventFetchStart PPUEvent = 1 << iota
+	// EventA12 is reported on PPU accesses with A12 set, which are the fetches
+	// from the pattern table at $1000-$1FFF during rendering, and the $2006/$2007
+	// accesses otherwise. Only one is reported per 8-dot fetch cycle.
+	EventA12
+	// EventPattern is reported after the PPU has fetched both bitplanes of a tile
+	// row during rendering. The address is the one of the high bitplane, which is
+	// the last byte the PPU reads for the tile.
+	EventPattern
+	// EventNametableRead and EventNametableWrite are the accesses to the
+	// nametables ($2000-$2FFF), which are handled by the cartridge instead of
+	// the console's internal VRAM.
+	EventNametableRead
+	EventNametableWrite
+)
+
+// BusAccess describes a PPU bus access reported to the cartridge.
+type BusAccess struct {
+	Event       PPUEvent
+	Kind        FetchKind
+	Addr        uint16
+	Data        byte // the written byte, for EventNametableWrite
+	Scanline    int  // -1 for the pre-render scanline
+	Dot         int
+	TallSprites bool // 8x16 sprites are enabled
+}
+
+// PPUBus is implemented by cartridges that need to see more of the PPU bus
+// than the CHR reads and writes, such as the mappers that count the fetched
+// tiles or the A12 rises (MMC2, MMC3, MMC5), or that map their own memory into
+// the nametables. Since the emulated PPU does not go through the bus for every
+// access, the cartridge asks for the events it is interested in, and the others
+// are not reported at all.
+//
+// For such cartridges, PendingIRQ is polled on every CPU cycle, like for the
+// ones implementing CPUTicker, and is expected to reflect the IRQ line.
+type PPUBus interface {
+	// ConnectPPU is called once by the PPU with the console's internal 2 KB of
+	// VRAM, for the cartridges that remap it. It returns the events the
+	// cartridge wants to receive.
+	ConnectPPU(ciram *[2][1024]byte) PPUEvent
+	// PPUAccess is called on every requested event. For EventNametableRead it
+	// returns the byte read, otherwise the result is ignored. With FetchPeek,
+	// the cartridge must not change its state.
+	PPUAccess(a BusAccess) byte
+}
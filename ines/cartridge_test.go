@@ -0,0 +1,160 @@
+package ines
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+// newTestROM returns a ROM with every byte of PRG and CHR being unique enough
+// to tell which bank is mapped where.
+func newTestROM(mapperID uint16, prgSize, chrSize int) *ROM {
+	rom := &ROM{
+		MapperID:   mapperID,
+		PRG:        make([]byte, prgSize),
+		CHR:        make([]byte, chrSize),
+		PRGBanks:   prgSize / 0x4000,
+		CHRBanks:   chrSize / 0x2000,
+		PRGRAMSize: 0x2000,
+		CRC32:      0xDEADBEEF,
+	}
+
+	for i := range rom.PRG {
+		rom.PRG[i] = byte(i>>13) ^ byte(i)
+	}
+
+	for i := range rom.CHR {
+		rom.CHR[i] = byte(i>>10) ^ byte(i)
+	}
+
+	return rom
+}
+
+func saveState(t *testing.T, cart Cartridge) []byte {
+	var buf bytes.Buffer
+
+	if err := cart.SaveState(binario.NewWriter(&buf, binary.LittleEndian)); err != nil {
+		t.Fatalf("failed to save state: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func loadState(t *testing.T, cart Cartridge, state []byte) {
+	if err := cart.LoadState(binario.NewReader(bytes.NewReader(state), binary.LittleEndian)); err != nil {
+		t.Fatalf("failed to load state: %v", err)
+	}
+}
+
+func TestCartridge_SaveLoadState(t *testing.T) {
+	tests := []struct {
+		mapperID uint16
+		prgSize  int
+		chrSize  int
+	}{
+		{0, 32 * 1024, 8 * 1024},
+		{1, 512 * 1024, 128 * 1024},
+		{2, 256 * 1024, 8 * 1024},
+		{3, 32 * 1024, 32 * 1024},
+		{4, 512 * 1024, 256 * 1024},
+		{5, 1024 * 1024, 1024 * 1024},
+		{7, 256 * 1024, 8 * 1024},
+		{9, 128 * 1024, 128 * 1024},
+		{10, 256 * 1024, 128 * 1024},
+		{11, 128 * 1024, 128 * 1024},
+		{15, 1024 * 1024, 8 * 1024},
+		{16, 256 * 1024, 256 * 1024},
+		{19, 256 * 1024, 256 * 1024},
+		{21, 256 * 1024, 256 * 1024},
+		{22, 128 * 1024, 128 * 1024},
+		{23, 256 * 1024, 256 * 1024},
+		{24, 256 * 1024, 256 * 1024},
+		{25, 256 * 1024, 256 * 1024},
+		{26, 256 * 1024, 256 * 1024},
+		{34, 128 * 1024, 8 * 1024},
+		{34, 64 * 1024, 64 * 1024},
+		{66, 128 * 1024, 32 * 1024},
+		{69, 256 * 1024, 256 * 1024},
+		{71, 256 * 1024, 8 * 1024},
+		{79, 64 * 1024, 64 * 1024},
+		{85, 512 * 1024, 256 * 1024},
+		{90, 2048 * 1024, 2048 * 1024},
+		{91, 256 * 1024, 512 * 1024},
+		{118, 512 * 1024, 128 * 1024},
+		{119, 128 * 1024, 64 * 1024},
+		{153, 512 * 1024, 8 * 1024},
+		{159, 256 * 1024, 128 * 1024},
+		{209, 512 * 1024, 512 * 1024},
+		{211, 512 * 1024, 512 * 1024},
+		{225, 2048 * 1024, 1024 * 1024},
+		{226, 2048 * 1024, 8 * 1024},
+		{227, 2048 * 1024, 8 * 1024},
+		{232, 256 * 1024, 8 * 1024},
+		{255, 1024 * 1024, 512 * 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("mapper%d", tt.mapperID), func(t *testing.T) {
+			rom := newTestROM(tt.mapperID, tt.prgSize, tt.chrSize)
+			rng := rand.New(rand.NewPCG(uint64(tt.mapperID), 0))
+
+			cart, err := NewCartridge(rom)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cart.Reset()
+
+			// Put the mapper in some random state.
+			for i := 0; i < 256; i++ {
+				addr := uint16(0x6000 + rng.IntN(0xA000))
+				cart.WritePRG(addr, byte(rng.Uint32()))
+			}
+
+			state := saveState(t, cart)
+
+			restored, err := NewCartridge(rom)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			restored.Reset()
+
+			if err := restored.LoadState(binario.NewReader(bytes.NewReader(state), binary.LittleEndian)); err != nil {
+				t.Fatalf("failed to load state: %v", err)
+			}
+
+			testutil.Equal(t, bytes.Equal(saveState(t, restored), state), true)
+			testutil.Equal(t, restored.MirrorMode(), cart.MirrorMode())
+
+			for addr := 0x6000; addr <= 0xFFFF; addr += 0x80 {
+				testutil.Equal(t, restored.ReadPRG(uint16(addr)), cart.ReadPRG(uint16(addr)))
+			}
+
+			for addr := 0x0000; addr <= 0x1FFF; addr += 0x40 {
+				testutil.Equal(t, restored.ReadCHR(uint16(addr)), cart.ReadCHR(uint16(addr)))
+			}
+		})
+	}
+}
+
+func TestCartridge_LoadStateMismatch(t *testing.T) {
+	cart, err := NewCartridge(newTestROM(0, 32*1024, 8*1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestROM(0, 32*1024, 8*1024)
+	other.CRC32 = 0xCAFEBABE
+
+	state := saveState(t, NewMapper0(other))
+	err = cart.LoadState(binario.NewReader(bytes.NewReader(state), binary.LittleEndian))
+
+	testutil.Equal(t, errors.Is(err, ErrSavedStateMismatch), true)
+}
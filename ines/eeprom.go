@@ -0,0 +1,216 @@
+package ines
+
+import (
+	"errors"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+type eepromMode uint8
+
+const (
+	eepromIdle    eepromMode = iota // waiting for the start condition
+	eepromDevice                    // receiving the device address (24C02 only)
+	eepromAddress                   // receiving the word address
+	eepromWrite                     // receiving the data to write
+	eepromRead                      // sending the data
+	eepromAck                       // sending the acknowledge bit
+	eepromNack                      // waiting for the stop condition
+)
+
+// eeprom24C0x emulates the 24C01 and 24C02 serial EEPROMs found on some Bandai
+// boards. The game bit-bangs the I2C bus through a mapper register, so the chip
+// only sees the levels of the clock (SCL) and data (SDA) lines. The 24C01 used
+// by Bandai is the older X24C01, which has no device address: the first byte
+// after the start condition is the 7-bit word address and the R/W bit.
+// https://www.nesdev.org/wiki/Bandai_FCG_board#Serial_EEPROM
+type eeprom24C0x struct {
+	data     []byte
+	x24C01   bool
+	mode     eepromMode
+	next     eepromMode // mode after the acknowledge bit
+	bits     uint8      // number of bits transferred in the current byte
+	shift    byte
+	address  uint8
+	scl, sda bool
+	out      bool // SDA level driven by the chip, high when released
+}
+
+func newEEPROM24C01() *eeprom24C0x {
+	return &eeprom24C0x{
+		data:   make([]byte, 128),
+		x24C01: true,
+		out:    true,
+	}
+}
+
+func newEEPROM24C02() *eeprom24C0x {
+	return &eeprom24C0x{
+		data: make([]byte, 256),
+		out:  true,
+	}
+}
+
+func (e *eeprom24C0x) reset() {
+	e.mode = eepromIdle
+	e.next = eepromIdle
+	e.bits = 0
+	e.shift = 0
+	e.scl = false
+	e.sda = false
+	e.out = true
+}
+
+// read returns the level of the SDA line as seen by the game.
+func (e *eeprom24C0x) read() bool {
+	return e.out
+}
+
+// write updates the levels of the SCL and SDA lines driven by the game.
+func (e *eeprom24C0x) write(scl, sda bool) {
+	switch {
+	case e.scl && scl && e.sda && !sda:
+		e.start()
+	case e.scl && scl && !e.sda && sda:
+		e.mode = eepromIdle
+		e.out = true
+	case !e.scl && scl:
+		e.rise(sda)
+	case e.scl && !scl:
+		e.fall()
+	}
+
+	e.scl, e.sda = scl, sda
+}
+
+func (e *eeprom24C0x) start() {
+	e.mode = eepromDevice
+	if e.x24C01 {
+		e.mode = eepromAddress
+	}
+
+	e.bits = 0
+	e.shift = 0
+	e.out = true
+}
+
+// rise handles the rising edge of SCL, which is when the bits are sampled.
+func (e *eeprom24C0x) rise(sda bool) {
+	switch e.mode {
+	case eepromDevice, eepromAddress, eepromWrite:
+		e.shift <<= 1
+		if sda {
+			e.shift |= 0x01
+		}
+
+		if e.bits++; e.bits == 8 {
+			e.receive(e.shift)
+		}
+	case eepromRead:
+		if e.bits < 8 {
+			e.bits++
+			return
+		}
+
+		// The game acknowledges the byte to continue reading.
+		if sda {
+			e.mode = eepromNack
+			return
+		}
+
+		e.address++
+		e.bits = 0
+	case eepromAck:
+		e.mode = e.next
+		e.bits = 0
+		e.shift = 0
+	}
+}
+
+// fall handles the falling edge of SCL, which is when the chip changes its
+// output, so that it is stable by the time the game raises SCL again.
+func (e *eeprom24C0x) fall() {
+	switch {
+	case e.mode == eepromAck:
+		e.out = false
+	case e.mode == eepromRead && e.bits < 8:
+		e.out = e.data[int(e.address)%len(e.data)]&(0x80>>e.bits) != 0
+	default:
+		e.out = true
+	}
+}
+
+// receive handles a complete byte sent by the game.
+func (e *eeprom24C0x) receive(b byte) {
+	switch e.mode {
+	case eepromDevice:
+		switch {
+		case b&0xF0 != 0xA0:
+			e.mode = eepromNack // addressed to another device
+			return
+		case b&0x01 != 0:
+			e.next = eepromRead
+		default:
+			e.next = eepromAddress
+		}
+	case eepromAddress:
+		e.address = b
+		e.next = eepromWrite
+
+		if e.x24C01 {
+			e.address = b >> 1
+			if b&0x01 != 0 {
+				e.next = eepromRead
+			}
+		}
+	case eepromWrite:
+		e.data[int(e.address)%len(e.data)] = b
+
+		// The address wraps around within the page: 4 bytes on X24C01
+		// and 8 bytes on 24C02.
+		page := uint8(0x07)
+		if e.x24C01 {
+			page = 0x03
+		}
+
+		e.address = e.address&^page | (e.address+1)&page
+		e.next = eepromWrite
+	}
+
+	e.mode = eepromAck
+}
+
+func (e *eeprom24C0x) saveState(w *binario.Writer) error {
+	return errors.Join(
+		w.WriteByteSlice(e.data),
+		w.WriteUint8(uint8(e.mode)),
+		w.WriteUint8(uint8(e.next)),
+		w.WriteUint8(e.bits),
+		w.WriteUint8(e.shift),
+		w.WriteUint8(e.address),
+		w.WriteBool(e.scl),
+		w.WriteBool(e.sda),
+		w.WriteBool(e.out),
+	)
+}
+
+func (e *eeprom24C0x) loadState(r *binario.Reader) error {
+	var mode, next uint8
+
+	err := errors.Join(
+		r.ReadByteSliceTo(e.data),
+		r.ReadUint8To(&mode),
+		r.ReadUint8To(&next),
+		r.ReadUint8To(&e.bits),
+		r.ReadUint8To(&e.shift),
+		r.ReadUint8To(&e.address),
+		r.ReadBoolTo(&e.scl),
+		r.ReadBoolTo(&e.sda),
+		r.ReadBoolTo(&e.out),
+	)
+
+	e.mode = eepromMode(mode)
+	e.next = eepromMode(next)
+
+	return err
+}
@@ -0,0 +1,106 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+// i2c drives the bus lines of the EEPROM the same way the games do.
+type i2c struct {
+	t      *testing.T
+	eeprom *eeprom24C0x
+}
+
+func (b i2c) start() {
+	b.eeprom.write(false, true)
+	b.eeprom.write(true, true)
+	b.eeprom.write(true, false)
+	b.eeprom.write(false, false)
+}
+
+func (b i2c) stop() {
+	b.eeprom.write(false, false)
+	b.eeprom.write(true, false)
+	b.eeprom.write(true, true)
+}
+
+func (b i2c) send(data byte) {
+	for i := 7; i >= 0; i-- {
+		bit := data>>i&0x01 != 0
+		b.eeprom.write(false, bit)
+		b.eeprom.write(true, bit)
+		b.eeprom.write(false, bit)
+	}
+
+	// The chip should acknowledge the byte.
+	b.eeprom.write(false, true)
+	b.eeprom.write(true, true)
+	testutil.Equal(b.t, b.eeprom.read(), false)
+	b.eeprom.write(false, true)
+}
+
+func (b i2c) receive(ack bool) (data byte) {
+	for i := 0; i < 8; i++ {
+		b.eeprom.write(false, true)
+		b.eeprom.write(true, true)
+
+		data <<= 1
+		if b.eeprom.read() {
+			data |= 0x01
+		}
+
+		b.eeprom.write(false, true)
+	}
+
+	b.eeprom.write(false, !ack)
+	b.eeprom.write(true, !ack)
+	b.eeprom.write(false, !ack)
+
+	return data
+}
+
+func TestEEPROM24C02(t *testing.T) {
+	bus := i2c{t, newEEPROM24C02()}
+	bus.eeprom.reset()
+
+	bus.start()
+	bus.send(0xA0) // device address, write
+	bus.send(0x10) // word address
+	bus.send(0x12)
+	bus.send(0x34)
+	bus.stop()
+
+	testutil.Equal(t, bus.eeprom.data[0x10], 0x12)
+	testutil.Equal(t, bus.eeprom.data[0x11], 0x34)
+
+	// Random read: set the address with a dummy write, then read.
+	bus.start()
+	bus.send(0xA0)
+	bus.send(0x10)
+	bus.start()
+	bus.send(0xA1)
+	testutil.Equal(t, bus.receive(true), 0x12)
+	testutil.Equal(t, bus.receive(false), 0x34)
+	bus.stop()
+}
+
+func TestEEPROM24C01(t *testing.T) {
+	bus := i2c{t, newEEPROM24C01()}
+	bus.eeprom.reset()
+
+	bus.start()
+	bus.send(0x05<<1 | 0) // word address, write
+	bus.send(0xAB)
+	bus.send(0xCD)
+	bus.stop()
+
+	testutil.Equal(t, bus.eeprom.data[0x05], 0xAB)
+	testutil.Equal(t, bus.eeprom.data[0x06], 0xCD)
+
+	bus.start()
+	bus.send(0x05<<1 | 1) // word address, read
+	testutil.Equal(t, bus.receive(true), 0xAB)
+	testutil.Equal(t, bus.receive(false), 0xCD)
+	bus.stop()
+}
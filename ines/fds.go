@@ -0,0 +1,177 @@
+package ines
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+
+	"github.com/maxpoletaev/dendy/consts"
+)
+
+const (
+	fdsSideSize = 65500 // size of a disk side in the .fds format
+	fdsBIOSSize = 0x2000
+)
+
+var (
+	ErrInvalidDiskImage = errors.New("invalid FDS disk image")
+	ErrInvalidBIOS      = errors.New("invalid FDS BIOS (expected 8 KB)")
+)
+
+// fdsMapperID is the mapper number reserved for the Famicom Disk System.
+const fdsMapperID = 20
+
+// NewFromFDSFile loads a Famicom Disk System image (.fds), which also needs the
+// 8 KB BIOS of the RAM adapter to run.
+func NewFromFDSFile(filename, biosFile string) (*ROM, error) {
+	image, _, err := ReadROMFile(filename, "")
+	if err != nil {
+		return nil, err
+	}
+
+	bios, err := os.ReadFile(biosFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FDS BIOS: %w", err)
+	}
+
+	return NewFromFDSBuffer(image, bios)
+}
+
+// NewFromFDSBuffer creates a ROM from a disk image and the BIOS. The BIOS takes
+// the place of PRG-ROM, and the disk sides are stored in ROM.Disk.
+func NewFromFDSBuffer(image, bios []byte) (*ROM, error) {
+	// The fwNES header is optional, and only holds the number of sides.
+	if len(image) >= 16 && bytes.Equal(image[:4], []byte{'F', 'D', 'S', 0x1A}) {
+		image = image[16:]
+	}
+
+	if len(image) < fdsSideSize {
+		return nil, ErrInvalidDiskImage
+	}
+
+	if len(bios) != fdsBIOSSize {
+		return nil, ErrInvalidBIOS
+	}
+
+	numSides := len(image) / fdsSideSize
+	sides := make([][]byte, numSides)
+
+	for i := range sides {
+		side := image[i*fdsSideSize : (i+1)*fdsSideSize]
+
+		// Every side starts with the disk info block.
+		if side[0] != 0x01 || !bytes.Equal(side[1:15], []byte("*NINTENDO-HVC*")) {
+			return nil, fmt.Errorf("%w: bad header on side %d", ErrInvalidDiskImage, i)
+		}
+
+		sides[i] = bytes.Clone(side)
+	}
+
+	rom := &ROM{
+		MapperID:   fdsMapperID,
+		MirrorMode: MirrorHorizontal,
+		PRG:        bytes.Clone(bios),
+		CHR:        make([]byte, 0x2000),
+		PRGRAMSize: 0x8000,
+		CHRRAMSize: 0x2000,
+		CRC32:      crc32.ChecksumIEEE(image[:numSides*fdsSideSize]),
+		Region:     consts.RegionNTSC,
+		Disk:       sides,
+		chrRAM:     true,
+	}
+
+	log.Printf("[INFO] ROM info:")
+	log.Printf("[INFO]   > format:     FDS")
+	log.Printf("[INFO]   > disk sides: %d", numSides)
+	log.Printf("[INFO]   > CRC32:      %08X", rom.CRC32)
+
+	return rom, nil
+}
+
+// fdsBlockSize returns the size of the block at the start of data, including
+// the block type byte, or 0 if there is no valid block.
+func fdsBlockSize(data []byte, fileSize int) int {
+	if len(data) == 0 {
+		return 0
+	}
+
+	switch data[0] {
+	case 1: // disk info
+		return 56
+	case 2: // file amount
+		return 2
+	case 3: // file header
+		return 16
+	case 4: // file data
+		return 1 + fileSize
+	default:
+		return 0
+	}
+}
+
+// fdsCRC updates the block checksum with a byte, the same way the RAM adapter
+// does it while reading or writing the disk.
+func fdsCRC(crc uint16, value byte) uint16 {
+	for bit := 0; bit < 8; bit++ {
+		carry := crc & 0x01
+		crc >>= 1
+
+		if carry != 0 {
+			crc ^= 0x8408
+		}
+
+		if value&(1<<bit) != 0 {
+			crc ^= 0x8000
+		}
+	}
+
+	return crc
+}
+
+const (
+	fdsLeadInGap  = 28300 / 8 // bytes of silence before the first block
+	fdsBlockGap   = 976 / 8   // bytes of silence between the blocks
+	fdsRawMinSize = 0x12000   // raw side size, leaving room for the gaps
+)
+
+// fdsRawSide converts a disk side from the .fds format into the stream of bytes
+// that the drive actually reads, with the gaps, block marks and checksums.
+func fdsRawSide(side []byte) []byte {
+	raw := make([]byte, fdsLeadInGap, fdsRawMinSize)
+	fileSize := 0
+
+	for pos := 0; pos < len(side); {
+		size := fdsBlockSize(side[pos:], fileSize)
+		if size == 0 || pos+size > len(side) {
+			break
+		}
+
+		block := side[pos : pos+size]
+		if block[0] == 3 {
+			fileSize = int(block[13]) | int(block[14])<<8
+		}
+
+		crc := fdsCRC(0, 0x80)
+		for _, b := range block {
+			crc = fdsCRC(crc, b)
+		}
+
+		crc = fdsCRC(fdsCRC(crc, 0), 0)
+
+		raw = append(raw, 0x80)
+		raw = append(raw, block...)
+		raw = append(raw, byte(crc), byte(crc>>8))
+		raw = append(raw, make([]byte, fdsBlockGap)...)
+
+		pos += size
+	}
+
+	if len(raw) < fdsRawMinSize {
+		raw = append(raw, make([]byte, fdsRawMinSize-len(raw))...)
+	}
+
+	return raw
+}
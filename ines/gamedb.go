@@ -0,0 +1,185 @@
+package ines
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/maxpoletaev/dendy/consts"
+)
+
+// gameDBData is the game database, one cartridge per line. The file is generated
+// from a NesCartDB export with cmd/dendy-gamedb, see the comment at its top for
+// the format.
+//
+//go:embed gamedb.txt
+var gameDBData string
+
+// GameInfo is what the game database knows about a cartridge.
+type GameInfo struct {
+	Title          string
+	Board          string
+	MapperID       uint16
+	Submapper      uint8
+	FixedMirroring bool       // false when the mirroring is controlled by the mapper
+	MirrorMode     MirrorMode // only valid with FixedMirroring
+	PRGRAMSize     int
+	Battery        bool
+	Region         consts.Region
+	Input          ExpansionDevice
+}
+
+var (
+	gameDB     map[uint32]GameInfo
+	gameDBOnce sync.Once
+)
+
+var gameDBInputs = map[string]ExpansionDevice{
+	"-":         ExpansionUnspecified,
+	"std":       ExpansionStandard,
+	"fourscore": ExpansionFourScore,
+	"4p":        ExpansionFourPlayers,
+	"zapper":    ExpansionZapper,
+}
+
+// LookupGame returns the database entry for the given PRG+CHR CRC32.
+func LookupGame(crc uint32) (GameInfo, bool) {
+	gameDBOnce.Do(func() {
+		var err error
+		if gameDB, err = parseGameDB(gameDBData); err != nil {
+			log.Printf("[ERROR] failed to parse game database: %s", err)
+		}
+	})
+
+	game, ok := gameDB[crc]
+
+	return game, ok
+}
+
+func parseGameDB(data string) (map[uint32]GameInfo, error) {
+	db := make(map[uint32]GameInfo)
+
+	for n, line := range strings.Split(data, "\n") {
+		if line = strings.TrimSpace(line); line == "" || line[0] == '#' {
+			continue
+		}
+
+		crc, game, err := parseGameDBLine(line)
+		if err != nil {
+			return db, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		db[crc] = game
+	}
+
+	return db, nil
+}
+
+// parseGameDBLine parses a tab-separated database line:
+// crc32, mapper[.submapper], board, mirroring, PRG-RAM KB, battery, region, input, title.
+func parseGameDBLine(line string) (uint32, GameInfo, error) {
+	var game GameInfo
+
+	fields := strings.SplitN(line, "\t", 9)
+	if len(fields) != 9 {
+		return 0, game, fmt.Errorf("expected 9 fields, got %d", len(fields))
+	}
+
+	crc, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return 0, game, fmt.Errorf("invalid crc32: %w", err)
+	}
+
+	mapper, submapper, _ := strings.Cut(fields[1], ".")
+
+	id, err := strconv.ParseUint(mapper, 10, 16)
+	if err != nil {
+		return 0, game, fmt.Errorf("invalid mapper: %w", err)
+	}
+
+	game.MapperID = uint16(id)
+
+	if submapper != "" {
+		sub, err := strconv.ParseUint(submapper, 10, 4)
+		if err != nil {
+			return 0, game, fmt.Errorf("invalid submapper: %w", err)
+		}
+
+		game.Submapper = uint8(sub)
+	}
+
+	game.Board = fields[2]
+
+	switch fields[3] {
+	case "H":
+		game.MirrorMode, game.FixedMirroring = MirrorHorizontal, true
+	case "V":
+		game.MirrorMode, game.FixedMirroring = MirrorVertical, true
+	case "4":
+		game.MirrorMode, game.FixedMirroring = MirrorFourScreen, true
+	case "-":
+	default:
+		return 0, game, fmt.Errorf("invalid mirroring: %s", fields[3])
+	}
+
+	ramKB, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return 0, game, fmt.Errorf("invalid PRG-RAM size: %w", err)
+	}
+
+	game.PRGRAMSize = ramKB * 1024
+	game.Battery = fields[5] == "1"
+
+	if game.Region, err = consts.ParseRegion(fields[6]); err != nil {
+		return 0, game, err
+	}
+
+	input, ok := gameDBInputs[fields[7]]
+	if !ok {
+		return 0, game, fmt.Errorf("invalid input device: %s", fields[7])
+	}
+
+	game.Input = input
+	game.Title = fields[8]
+
+	return uint32(crc), game, nil
+}
+
+// applyGameDB looks up the ROM in the game database and updates it with what
+// the database knows about the cartridge.
+func (r *ROM) applyGameDB() {
+	if game, ok := LookupGame(r.CRC32); ok {
+		r.applyGameInfo(game)
+	}
+}
+
+// applyGameInfo updates the ROM with the database entry. iNES 1.0 and UNIF
+// headers are often incomplete or plain wrong, so the database takes precedence
+// over them. NES 2.0 headers are trusted, and only the title is taken.
+func (r *ROM) applyGameInfo(game GameInfo) {
+	log.Printf("[INFO] found in game database: %s (%s)", game.Title, game.Board)
+	r.Title = game.Title
+
+	if r.NES2 {
+		return
+	}
+
+	r.MapperID = game.MapperID
+	r.Submapper = game.Submapper
+	r.Battery = game.Battery
+	r.Region = game.Region
+	r.ExpansionDevice = game.Input
+
+	if game.FixedMirroring {
+		r.MirrorMode = game.MirrorMode
+	}
+
+	if r.Battery {
+		r.PRGRAMSize, r.PRGNVRAMSize = 0, game.PRGRAMSize
+	} else {
+		r.PRGRAMSize, r.PRGNVRAMSize = game.PRGRAMSize, 0
+	}
+}
@@ -0,0 +1,117 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/consts"
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestParseGameDB(t *testing.T) {
+	db, err := parseGameDB(gameDBData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, len(db) > 0, true)
+}
+
+func TestLookupGame(t *testing.T) {
+	game, ok := LookupGame(0x3337EC46)
+	testutil.Equal(t, ok, true)
+	testutil.Equal(t, game.Title, "Super Mario Bros.")
+	testutil.Equal(t, game.MapperID, 0)
+	testutil.Equal(t, game.FixedMirroring, true)
+	testutil.Equal(t, game.MirrorMode, MirrorVertical)
+
+	_, ok = LookupGame(0x00000000)
+	testutil.Equal(t, ok, false)
+}
+
+func TestParseGameDBLine(t *testing.T) {
+	crc, game, err := parseGameDBLine("ABCDEF01\t4.1\tNES-HKROM\t-\t1\t1\tpal\tzapper\tSome Game")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, crc, 0xABCDEF01)
+	testutil.Equal(t, game.Title, "Some Game")
+	testutil.Equal(t, game.Board, "NES-HKROM")
+	testutil.Equal(t, game.MapperID, 4)
+	testutil.Equal(t, game.Submapper, 1)
+	testutil.Equal(t, game.FixedMirroring, false)
+	testutil.Equal(t, game.PRGRAMSize, 1024)
+	testutil.Equal(t, game.Battery, true)
+	testutil.Equal(t, game.Region, consts.RegionPAL)
+	testutil.Equal(t, game.Input, ExpansionZapper)
+
+	_, _, err = parseGameDBLine("ABCDEF01\t4\tNES-TLROM\tX\t0\t0\tntsc\tstd\tSome Game")
+	testutil.Equal(t, err != nil, true)
+}
+
+func TestROM_applyGameInfo(t *testing.T) {
+	game := GameInfo{
+		Title:          "Some Game",
+		MapperID:       1,
+		FixedMirroring: true,
+		MirrorMode:     MirrorVertical,
+		PRGRAMSize:     0x2000,
+		Battery:        true,
+		Region:         consts.RegionNTSC,
+		Input:          ExpansionStandard,
+	}
+
+	// A broken iNES 1.0 header is corrected.
+	rom := &ROM{MapperID: 2, MirrorMode: MirrorHorizontal, PRGRAMSize: 0x2000}
+	rom.applyGameInfo(game)
+
+	testutil.Equal(t, rom.Title, "Some Game")
+	testutil.Equal(t, rom.MapperID, 1)
+	testutil.Equal(t, rom.MirrorMode, MirrorVertical)
+	testutil.Equal(t, rom.Battery, true)
+	testutil.Equal(t, rom.PRGRAMSize, 0)
+	testutil.Equal(t, rom.PRGNVRAMSize, 0x2000)
+
+	// NES 2.0 headers are trusted, only the title is taken.
+	rom = &ROM{NES2: true, MapperID: 2}
+	rom.applyGameInfo(game)
+
+	testutil.Equal(t, rom.Title, "Some Game")
+	testutil.Equal(t, rom.MapperID, 2)
+	testutil.Equal(t, rom.Battery, false)
+}
+
+func TestROM_applyGameDB(t *testing.T) {
+	// Super Mario Bros. with a header claiming MMC3, horizontal mirroring,
+	// battery and PAL.
+	rom := &ROM{
+		CRC32:      0x3337EC46,
+		MapperID:   4,
+		MirrorMode: MirrorHorizontal,
+		Battery:    true,
+		Region:     consts.RegionPAL,
+		PRGRAMSize: 0x2000,
+	}
+	rom.applyGameDB()
+
+	testutil.Equal(t, rom.Title, "Super Mario Bros.")
+	testutil.Equal(t, rom.MapperID, 0)
+	testutil.Equal(t, rom.MirrorMode, MirrorVertical)
+	testutil.Equal(t, rom.Battery, false)
+	testutil.Equal(t, rom.Region, consts.RegionNTSC)
+	testutil.Equal(t, rom.PRGRAMSize, 0)
+
+	// The Legend of Zelda with a mapper 0 header, which lost the battery.
+	rom = &ROM{
+		CRC32:      0x3FE272FB,
+		MirrorMode: MirrorHorizontal,
+		Region:     consts.RegionDendy,
+	}
+	rom.applyGameDB()
+
+	testutil.Equal(t, rom.MapperID, 1)
+	testutil.Equal(t, rom.MirrorMode, MirrorHorizontal) // controlled by the mapper
+	testutil.Equal(t, rom.Battery, true)
+	testutil.Equal(t, rom.Region, consts.RegionNTSC)
+	testutil.Equal(t, rom.PRGNVRAMSize, 0x2000)
+}
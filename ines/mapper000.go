@@ -1,6 +1,7 @@
 package ines
 
 import (
+	"errors"
 	"log"
 
 	"github.com/maxpoletaev/dendy/internal/binario"
@@ -12,13 +13,22 @@ import (
 //	PRG-ROM is mapped to 0x8000-0xFFFF.
 //	CHR-ROM is mapped to 0x0000-0x1FFF.
 type Mapper0 struct {
-	rom *ROM
+	rom  *ROM
+	sram []byte
 }
 
 func NewMapper0(cart *ROM) *Mapper0 {
-	return &Mapper0{
+	m := &Mapper0{
 		rom: cart,
 	}
+
+	// Only NES 2.0 headers can tell whether the board has PRG-RAM (e.g. Family
+	// BASIC), but the trainer needs some RAM to be loaded into.
+	if cart.NES2 || cart.Trainer != nil {
+		m.sram = cart.newPRGRAM()
+	}
+
+	return m
 }
 
 func (m *Mapper0) Reset() {
@@ -37,6 +47,8 @@ func (m *Mapper0) MirrorMode() MirrorMode {
 
 func (m *Mapper0) ReadPRG(addr uint16) byte {
 	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF && len(m.sram) > 0:
+		return m.sram[int(addr-0x6000)%len(m.sram)]
 	case addr >= 0x8000 && addr <= 0xFFFF:
 		idx := addr % uint16(len(m.rom.PRG))
 		return m.rom.PRG[idx]
@@ -47,6 +59,11 @@ func (m *Mapper0) ReadPRG(addr uint16) byte {
 }
 
 func (m *Mapper0) WritePRG(addr uint16, data byte) {
+	if addr >= 0x6000 && addr <= 0x7FFF && len(m.sram) > 0 {
+		m.sram[int(addr-0x6000)%len(m.sram)] = data
+		return
+	}
+
 	log.Printf("[WARN] mapper0: write to read-only prg at %04X", addr)
 }
 
@@ -61,13 +78,32 @@ func (m *Mapper0) ReadCHR(addr uint16) byte {
 }
 
 func (m *Mapper0) WriteCHR(addr uint16, data byte) {
-	log.Printf("[WARN] mapper0: write to read-only chr at %04X", addr)
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper0: write to read-only chr at %04X", addr)
+		return
+	}
+
+	m.rom.CHR[addr] = data
+}
+
+func (m *Mapper0) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
 }
 
 func (m *Mapper0) SaveState(w *binario.Writer) error {
-	return m.rom.SaveState(w)
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.sram),
+	)
 }
 
 func (m *Mapper0) LoadState(r *binario.Reader) error {
-	return m.rom.LoadState(r)
+	return errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+	)
 }
@@ -12,7 +12,7 @@ import (
 // https://www.nesdev.org/wiki/MMC1
 type Mapper1 struct {
 	rom  *ROM
-	sram [0x2000]byte
+	sram []byte
 
 	control  byte
 	prgBank  byte
@@ -25,7 +25,8 @@ type Mapper1 struct {
 
 func NewMapper1(rom *ROM) *Mapper1 {
 	return &Mapper1{
-		rom: rom,
+		rom:  rom,
+		sram: rom.newPRGRAM(),
 	}
 }
 
@@ -109,28 +110,55 @@ func (m *Mapper1) loadRegister(addr uint16, data byte) {
 }
 
 func (m *Mapper1) prgBankIndex() (uint, uint) {
+	// SUROM and SXROM boards have 512 KB of PRG-ROM, where bit 4 of the CHR
+	// bank register selects which 256 KB half is visible.
+	var outer uint
+	if len(m.rom.PRG) > 0x40000 {
+		outer = uint(m.chrBank0 & 0x10)
+	}
+
 	switch m.prgMode() {
 	case 0, 1: // Switch 32 KB at $8000, ignoring low bit of bank number.
-		return uint(m.prgBank & 0xFE), uint(m.prgBank | 0x01)
+		return outer | uint(m.prgBank&0xFE), outer | uint(m.prgBank|0x01)
 	case 2: // Fix first bank at $8000 and switch 16 KB bank at $C000.
-		return 0, uint(m.prgBank)
+		return outer, outer | uint(m.prgBank)
 	case 3: // Fix last bank at $C000 and switch 16 KB bank at $8000.
-		return uint(m.prgBank), uint(m.rom.PRGBanks - 1)
+		return outer | uint(m.prgBank), outer | 0x0F
 	default:
 		panic(fmt.Sprintf("mapper1: invalid prg mode: %d", m.prgMode()))
 	}
 }
 
 func (m *Mapper1) prgOffset(idx uint) uint {
+	idx %= uint(m.rom.PRGBanks)
 	return idx * 0x4000
 }
 
+func (m *Mapper1) sramOffset(addr uint16) int {
+	var bank int
+
+	// SOROM and SXROM boards use the CHR bank register to select
+	// one of the 8 KB PRG-RAM banks.
+	switch len(m.sram) {
+	case 0x4000: // SOROM
+		bank = int(m.chrBank0>>3) & 0x01
+	case 0x8000: // SXROM
+		bank = int(m.chrBank0>>2) & 0x03
+	}
+
+	offset := bank*0x2000 + int(addr-0x6000)
+	return offset % len(m.sram)
+}
+
 func (m *Mapper1) ReadPRG(addr uint16) byte {
 	bank0, bank1 := m.prgBankIndex()
 
 	switch {
 	case addr >= 0x6000 && addr <= 0x7FFF: // PRG-RAM
-		return m.sram[addr-0x6000]
+		if len(m.sram) == 0 {
+			return 0
+		}
+		return m.sram[m.sramOffset(addr)]
 	case addr >= 0x8000 && addr <= 0xBFFF: // PRG-ROM, bank 0
 		relAddr := uint((addr - 0x8000) % 0x4000)
 		return m.rom.PRG[m.prgOffset(bank0)+relAddr]
@@ -146,7 +174,9 @@ func (m *Mapper1) ReadPRG(addr uint16) byte {
 func (m *Mapper1) WritePRG(addr uint16, data byte) {
 	switch {
 	case addr >= 0x6000 && addr <= 0x7FFF: // PRG-RAM
-		m.sram[addr-0x6000] = data
+		if len(m.sram) > 0 {
+			m.sram[m.sramOffset(addr)] = data
+		}
 	case addr >= 0x8000 && addr <= 0xFFFF: // PRG-ROM (registers)
 		m.loadRegister(addr, data)
 	default:
@@ -166,6 +196,7 @@ func (m *Mapper1) chrBankIndex() (uint, uint) {
 }
 
 func (m *Mapper1) chrOffset(idx uint) uint {
+	idx %= uint(len(m.rom.CHR) / 0x1000)
 	return idx * 0x1000
 }
 
@@ -203,10 +234,18 @@ func (m *Mapper1) WriteCHR(addr uint16, data byte) {
 	}
 }
 
+func (m *Mapper1) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
 func (m *Mapper1) SaveState(w *binario.Writer) error {
 	return errors.Join(
 		m.rom.SaveState(w),
-		w.WriteByteSlice(m.sram[:]),
+		w.WriteByteSlice(m.sram),
 		w.WriteUint8(m.control),
 		w.WriteUint8(m.chrBank0),
 		w.WriteUint8(m.chrBank1),
@@ -219,7 +258,7 @@ func (m *Mapper1) SaveState(w *binario.Writer) error {
 func (m *Mapper1) LoadState(r *binario.Reader) error {
 	return errors.Join(
 		m.rom.LoadState(r),
-		r.ReadByteSliceTo(m.sram[:]),
+		r.ReadByteSliceTo(m.sram),
 		r.ReadUint8To(&m.control),
 		r.ReadUint8To(&m.chrBank0),
 		r.ReadUint8To(&m.chrBank1),
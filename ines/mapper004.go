@@ -8,11 +8,24 @@ import (
 	"github.com/maxpoletaev/dendy/internal/binario"
 )
 
-// Mapper4 implements the MMC3 mapper.
+// mmc3A12Filter is the minimum distance in PPU dots between two A12 rises for
+// the second one to clock the IRQ counter. The real chip ignores the rises after
+// A12 has been low for less than about three CPU cycles, which filters out the
+// toggling between the background and sprite fetches within a scanline.
+const mmc3A12Filter = 16
+
+// Mapper4 implements the MMC3 mapper. It also covers MMC6 (submapper 1), which
+// has 1 KB of PRG-RAM inside the chip instead of 8 KB on the board, with separate
+// read and write protection for each of its halves. The boards that use the CHR
+// bank bits for something else are built on top of it (see Mapper118, Mapper119).
 // https://wiki.nesdev.com/w/index.php/MMC3
+// https://www.nesdev.org/wiki/MMC6
 type Mapper4 struct {
 	rom        *ROM
-	sram       [0x2000]byte
+	sram       []byte
+	mmc6       bool
+	ramEnable  bool // $8000 bit 5 (MMC6 only)
+	ramProtect byte // $A001 (MMC6 only)
 	mirror     MirrorMode
 	chrBank    [8]int
 	prgBank    [4]int
@@ -24,11 +37,21 @@ type Mapper4 struct {
 	irqCounter byte
 	irqReload  byte
 	irqPending bool
+	a12Last    int // position of the last A12 rise, in dots since the pre-render scanline
 }
 
 func NewMapper4(rom *ROM) *Mapper4 {
+	if rom.MapperID == 4 && rom.Submapper == 1 {
+		return &Mapper4{
+			rom:  rom,
+			sram: make([]byte, 0x400),
+			mmc6: true,
+		}
+	}
+
 	return &Mapper4{
-		rom: rom,
+		rom:  rom,
+		sram: rom.newPRGRAM(),
 	}
 }
 
@@ -41,11 +64,14 @@ func (m *Mapper4) Reset() {
 	m.targetReg = 0
 	m.chrMode = 0
 	m.prgMode = 0
+	m.ramEnable = false
+	m.ramProtect = 0
 
 	m.irqPending = false
 	m.irqEnable = false
 	m.irqCounter = 0
 	m.irqReload = 0
+	m.a12Last = -mmc3A12Filter
 
 	m.updateBanks()
 }
@@ -79,27 +105,29 @@ func (m *Mapper4) updateBanks() {
 		panic(fmt.Sprintf("mapper4: invalid prg mode %d", m.prgMode))
 	}
 
-	switch m.chrMode {
+	for i := range m.chrBank {
+		m.chrBank[i] = m.chrOffset(m.chrRegister(i))
+	}
+}
+
+// chrRegister returns the bank number selected for the given 1 KB slot of the
+// pattern table, including the upper bits that some boards use for other things.
+func (m *Mapper4) chrRegister(slot int) int {
+	if m.chrMode == 1 {
+		slot ^= 4 // the 2 KB banks are at $1000
+	}
+
+	switch slot {
 	case 0:
-		m.chrBank[0] = m.chrOffset(m.registers[0] & 0xFE)
-		m.chrBank[1] = m.chrOffset(m.registers[0] | 0x01)
-		m.chrBank[2] = m.chrOffset(m.registers[1] & 0xFE)
-		m.chrBank[3] = m.chrOffset(m.registers[1] | 0x01)
-		m.chrBank[4] = m.chrOffset(m.registers[2])
-		m.chrBank[5] = m.chrOffset(m.registers[3])
-		m.chrBank[6] = m.chrOffset(m.registers[4])
-		m.chrBank[7] = m.chrOffset(m.registers[5])
+		return m.registers[0] & 0xFE
 	case 1:
-		m.chrBank[0] = m.chrOffset(m.registers[2])
-		m.chrBank[1] = m.chrOffset(m.registers[3])
-		m.chrBank[2] = m.chrOffset(m.registers[4])
-		m.chrBank[3] = m.chrOffset(m.registers[5])
-		m.chrBank[4] = m.chrOffset(m.registers[0] & 0xFE)
-		m.chrBank[5] = m.chrOffset(m.registers[0] | 0x01)
-		m.chrBank[6] = m.chrOffset(m.registers[1] & 0xFE)
-		m.chrBank[7] = m.chrOffset(m.registers[1] | 0x01)
+		return m.registers[0] | 0x01
+	case 2:
+		return m.registers[1] & 0xFE
+	case 3:
+		return m.registers[1] | 0x01
 	default:
-		panic(fmt.Sprintf("mapper4: invalid chr mode %d", m.chrMode))
+		return m.registers[slot-2]
 	}
 }
 
@@ -118,6 +146,7 @@ func (m *Mapper4) writeRegister(addr uint16, data byte) {
 		m.prgMode = (data >> 6) & 1
 		m.chrMode = (data >> 7) & 1
 		m.targetReg = data & 7
+		m.ramEnable = data&0x20 != 0
 		m.updateBanks()
 	case addr >= 0x8000 && addr <= 0x9FFF && addr%2 == 1: // bank data
 		m.registers[m.targetReg] = int(data)
@@ -125,13 +154,16 @@ func (m *Mapper4) writeRegister(addr uint16, data byte) {
 	case addr >= 0xA000 && addr <= 0xBFFF && addr%2 == 0: // mirroring
 		m.writeMirror(data)
 	case addr >= 0xA000 && addr <= 0xBFFF && addr%2 == 1: // prg ram protect
-		// noop
+		if m.mmc6 && m.ramEnable {
+			m.ramProtect = data
+		}
 	case addr >= 0xC000 && addr <= 0xDFFF && addr%2 == 0: // irq latch
 		m.irqReload = data
 	case addr >= 0xC000 && addr <= 0xDFFF && addr%2 == 1: // irq reload
 		m.irqCounter = 0
 	case addr >= 0xE000 && addr <= 0xFFFF && addr%2 == 0: // irq disable
 		m.irqEnable = false
+		m.irqPending = false
 	case addr >= 0xE000 && addr <= 0xFFFF && addr%2 == 1: // irq enable
 		m.irqEnable = true
 	default:
@@ -139,30 +171,97 @@ func (m *Mapper4) writeRegister(addr uint16, data byte) {
 	}
 }
 
-func (m *Mapper4) ScanlineTick() {
+// ScanlineTick is a no-op, the IRQ counter is clocked by the A12 rises instead.
+func (m *Mapper4) ScanlineTick() {}
+
+func (m *Mapper4) ConnectPPU(ciram *[2][1024]byte) PPUEvent {
+	return EventA12
+}
+
+func (m *Mapper4) PPUAccess(a BusAccess) byte {
+	if a.Event == EventA12 {
+		m.watchA12(a.Scanline, a.Dot)
+	}
+
+	return 0
+}
+
+func (m *Mapper4) watchA12(scanline, dot int) {
+	now := (scanline+1)*341 + dot
+	elapsed := now - m.a12Last
+	m.a12Last = now
+
+	// Going back in time means the PPU has started a new frame.
+	if elapsed < 0 || elapsed >= mmc3A12Filter {
+		m.clockIRQ()
+	}
+}
+
+func (m *Mapper4) clockIRQ() {
 	if m.irqCounter == 0 {
 		m.irqCounter = m.irqReload
 	} else {
 		m.irqCounter--
-		if m.irqCounter == 0 {
-			m.irqPending = m.irqEnable
-		}
+	}
+
+	if m.irqCounter == 0 && m.irqEnable {
+		m.irqPending = true
 	}
 }
 
-func (m *Mapper4) PendingIRQ() (v bool) {
-	v, m.irqPending = m.irqPending, false
-	return v
+func (m *Mapper4) PendingIRQ() bool {
+	return m.irqPending
 }
 
 func (m *Mapper4) MirrorMode() MirrorMode {
+	// TVROM and TR1ROM boards have four-screen VRAM, so the mirroring
+	// register is not connected.
+	if m.rom.MirrorMode == MirrorFourScreen {
+		return MirrorFourScreen
+	}
+
 	return m.mirror
 }
 
+// mmc6RAMAccess tells whether the given half of the MMC6 RAM can be read and
+// written, and whether the RAM responds to reads at all, which happens when at
+// least one of the halves is readable.
+func (m *Mapper4) mmc6RAMAccess(addr uint16) (read, write, mapped bool) {
+	if !m.ramEnable {
+		return false, false, false
+	}
+
+	var (
+		shift    = (addr >> 9 & 0x01) * 2 // $7000 or $7200 half
+		readBit  = m.ramProtect >> (5 + shift) & 0x01
+		writeBit = m.ramProtect >> (4 + shift) & 0x01
+	)
+
+	// Writing also requires the half to be readable.
+	read = readBit != 0
+	write = read && writeBit != 0
+	mapped = m.ramProtect&0xA0 != 0
+
+	return read, write, mapped
+}
+
 func (m *Mapper4) ReadPRG(addr uint16) byte {
 	switch {
+	case m.mmc6 && addr >= 0x6000 && addr <= 0x7FFF:
+		read, _, mapped := m.mmc6RAMAccess(addr)
+		switch {
+		case addr < 0x7000 || !mapped:
+			return 0 // open bus
+		case !read:
+			return 0 // the other half reads as zero
+		default:
+			return m.sram[addr&0x03FF]
+		}
 	case addr >= 0x6000 && addr <= 0x7FFF:
-		return m.sram[addr-0x6000]
+		if len(m.sram) == 0 {
+			return 0
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
 	case addr >= 0x8000 && addr <= 0xFFFF:
 		bank := (addr - 0x8000) / 0x2000
 		offset := int(addr-0x8000) % 0x2000
@@ -175,8 +274,14 @@ func (m *Mapper4) ReadPRG(addr uint16) byte {
 
 func (m *Mapper4) WritePRG(addr uint16, data byte) {
 	switch {
+	case m.mmc6 && addr >= 0x6000 && addr <= 0x7FFF:
+		if _, write, _ := m.mmc6RAMAccess(addr); write && addr >= 0x7000 {
+			m.sram[addr&0x03FF] = data
+		}
 	case addr >= 0x6000 && addr <= 0x7FFF:
-		m.sram[addr-0x6000] = data
+		if len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
 	case addr >= 0x8000 && addr <= 0xFFFF:
 		m.writeRegister(addr, data)
 	default:
@@ -213,10 +318,18 @@ func (m *Mapper4) WriteCHR(addr uint16, data byte) {
 	}
 }
 
+func (m *Mapper4) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
 func (m *Mapper4) SaveState(w *binario.Writer) error {
 	err := errors.Join(
 		m.rom.SaveState(w),
-		w.WriteByteSlice(m.sram[:]),
+		w.WriteByteSlice(m.sram),
 		w.WriteUint8(m.mirror),
 		w.WriteUint8(m.prgMode),
 		w.WriteUint8(m.chrMode),
@@ -224,6 +337,10 @@ func (m *Mapper4) SaveState(w *binario.Writer) error {
 		w.WriteBool(m.irqEnable),
 		w.WriteUint8(m.irqCounter),
 		w.WriteUint8(m.irqReload),
+		w.WriteBool(m.irqPending),
+		w.WriteUint32(uint32(m.a12Last)),
+		w.WriteBool(m.ramEnable),
+		w.WriteUint8(m.ramProtect),
 	)
 
 	if err != nil {
@@ -252,9 +369,11 @@ func (m *Mapper4) SaveState(w *binario.Writer) error {
 }
 
 func (m *Mapper4) LoadState(r *binario.Reader) error {
+	var a12Last uint32
+
 	err := errors.Join(
 		m.rom.LoadState(r),
-		r.ReadByteSliceTo(m.sram[:]),
+		r.ReadByteSliceTo(m.sram),
 		r.ReadUint8To(&m.mirror),
 		r.ReadUint8To(&m.prgMode),
 		r.ReadUint8To(&m.chrMode),
@@ -262,8 +381,14 @@ func (m *Mapper4) LoadState(r *binario.Reader) error {
 		r.ReadBoolTo(&m.irqEnable),
 		r.ReadUint8To(&m.irqCounter),
 		r.ReadUint8To(&m.irqReload),
+		r.ReadBoolTo(&m.irqPending),
+		r.ReadUint32To(&a12Last),
+		r.ReadBoolTo(&m.ramEnable),
+		r.ReadUint8To(&m.ramProtect),
 	)
 
+	m.a12Last = int(int32(a12Last))
+
 	for i := range m.registers {
 		val, err := r.ReadUint32()
 		if err != nil {
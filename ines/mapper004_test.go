@@ -0,0 +1,96 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper4_MMC6RAM(t *testing.T) {
+	rom := newTestROM(4, 256*1024, 128*1024)
+	rom.Submapper = 1
+
+	m := NewMapper4(rom)
+	m.Reset()
+
+	// The RAM is disabled until bit 5 of $8000 is set.
+	m.WritePRG(0xA001, 0xF0)
+	m.WritePRG(0x7000, 0x11)
+	testutil.Equal(t, m.ReadPRG(0x7000), 0x00)
+
+	m.WritePRG(0x8000, 0x20)
+	m.WritePRG(0xA001, 0xF0)
+	m.WritePRG(0x7000, 0x11)
+	m.WritePRG(0x7200, 0x22)
+	testutil.Equal(t, m.ReadPRG(0x7000), 0x11)
+	testutil.Equal(t, m.ReadPRG(0x7200), 0x22)
+
+	// 1 KB is mirrored across $7000-$7FFF.
+	testutil.Equal(t, m.ReadPRG(0x7400), 0x11)
+	testutil.Equal(t, m.ReadPRG(0x7E00), 0x22)
+
+	// Only the first half is readable and writable, the second one reads as
+	// zero and ignores writes.
+	m.WritePRG(0xA001, 0x30)
+	m.WritePRG(0x7200, 0x33)
+	testutil.Equal(t, m.ReadPRG(0x7200), 0x00)
+
+	// Both halves are read-only now.
+	m.WritePRG(0xA001, 0xA0)
+	m.WritePRG(0x7000, 0x44)
+	testutil.Equal(t, m.ReadPRG(0x7000), 0x11)
+	testutil.Equal(t, m.ReadPRG(0x7200), 0x22)
+
+	// The first half is write-enabled but not readable, so it is not
+	// accessible at all.
+	m.WritePRG(0xA001, 0xD0)
+	m.WritePRG(0x7000, 0x55)
+	testutil.Equal(t, m.ReadPRG(0x7000), 0x00)
+	testutil.Equal(t, m.ReadPRG(0x7200), 0x22)
+
+	// Nothing is readable, the whole range is open bus.
+	m.WritePRG(0xA001, 0x50)
+	testutil.Equal(t, m.ReadPRG(0x7000), 0x00)
+	testutil.Equal(t, m.ReadPRG(0x7200), 0x00)
+
+	m.WritePRG(0xA001, 0xF0)
+	testutil.Equal(t, m.ReadPRG(0x7000), 0x11)
+}
+
+func TestMapper4_A12IRQ(t *testing.T) {
+	m := NewMapper4(newTestROM(4, 256*1024, 128*1024))
+	m.Reset()
+
+	m.WritePRG(0xC000, 2) // latch
+	m.WritePRG(0xC001, 0) // reload on the next clock
+	m.WritePRG(0xE001, 0) // enable
+
+	// One rise per scanline, on the sprite fetches. The first one reloads
+	// the counter, so the IRQ fires on the third scanline.
+	m.PPUAccess(BusAccess{Event: EventA12, Scanline: 0, Dot: 261})
+	testutil.Equal(t, m.PendingIRQ(), false)
+	m.PPUAccess(BusAccess{Event: EventA12, Scanline: 1, Dot: 261})
+	testutil.Equal(t, m.PendingIRQ(), false)
+
+	// The rises closer than mmc3A12Filter dots are filtered out, like the
+	// ones of the 8x16 sprites fetched from both pattern tables.
+	m.PPUAccess(BusAccess{Event: EventA12, Scanline: 1, Dot: 269})
+	testutil.Equal(t, m.PendingIRQ(), false)
+
+	m.PPUAccess(BusAccess{Event: EventA12, Scanline: 2, Dot: 261})
+	testutil.Equal(t, m.PendingIRQ(), true)
+
+	// Disabling acknowledges the IRQ.
+	m.WritePRG(0xE000, 0)
+	testutil.Equal(t, m.PendingIRQ(), false)
+	m.WritePRG(0xE001, 0)
+
+	// The next frame starts over from the pre-render scanline, which is not
+	// mistaken for a rise too close to the last one. The counter is at zero,
+	// so it is reloaded and counts down again.
+	m.PPUAccess(BusAccess{Event: EventA12, Scanline: -1, Dot: 261})
+	m.PPUAccess(BusAccess{Event: EventA12, Scanline: 0, Dot: 261})
+	testutil.Equal(t, m.PendingIRQ(), false)
+	m.PPUAccess(BusAccess{Event: EventA12, Scanline: 1, Dot: 261})
+	testutil.Equal(t, m.PendingIRQ(), true)
+}
@@ -0,0 +1,639 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/apu"
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper5 implements the MMC5 mapper, the most advanced of the Nintendo ones.
+// Since the emulated PPU renders a scanline at once, the mapper relies on the
+// PPU fetch notifications to tell background and sprite fetches apart, and to
+// count the background tiles for the split screen.
+// https://www.nesdev.org/wiki/MMC5
+type Mapper5 struct {
+	rom   *ROM
+	sram  []byte
+	exram [1024]byte
+	ciram *[2][1024]byte
+	audio apu.MMC5Audio
+
+	prgMode     byte
+	chrMode     byte
+	ramProtect  [2]byte
+	exramMode   byte
+	ntMapping   byte
+	fillTile    byte
+	fillAttr    byte
+	prgRegs     [5]byte   // $5113-$5117
+	chrRegs     [12]int   // $5120-$512B
+	chrUpper    byte      // $5130
+	chrLastSet  byte      // 0: $5120-$5127, 1: $5128-$512B
+	prgBank     [5]int    // $6000, $8000, $A000, $C000, $E000
+	prgROM      [5]bool   // whether the bank is mapped to ROM or RAM
+	chrBankA    [8]int    // sprites in 8x16 mode
+	chrBankB    [8]int    // background in 8x16 mode
+	multiplier  [2]byte   // $5205-$5206
+	splitCtrl   byte      // $5200
+	splitScroll byte      // $5201
+	splitBank   byte      // $5202
+	irqTarget   byte      // $5203
+	irqEnable   bool      // $5204
+	irqStatus   bool      // $5204
+	irqPending  bool      // not yet delivered to the CPU
+	inFrame     bool      // rendering is in progress
+	scanline    int       // current scanline while in frame
+	fetched     bool      // the PPU has fetched something since the last scanline
+	fetchKind   FetchKind // what the PPU is currently fetching
+	tallSprite  bool      // 8x16 sprite mode
+
+	// The state of the background tile being fetched.
+	tileIndex  int
+	tileExAttr byte
+	tileSplit  bool
+	splitY     int
+}
+
+func NewMapper5(rom *ROM) *Mapper5 {
+	sram := rom.newPRGRAM()
+
+	// The boards come with up to 64 KB of PRG-RAM, and there is no way to tell
+	// how much is needed from an iNES 1.0 header. Just give it the maximum.
+	if !rom.NES2 {
+		sram = append(sram, make([]byte, 0x10000-len(sram))...)
+	}
+
+	return &Mapper5{
+		rom:  rom,
+		sram: sram,
+	}
+}
+
+func (m *Mapper5) Reset() {
+	m.prgMode = 3
+	m.chrMode = 0
+	m.ramProtect = [2]byte{}
+	m.exramMode = 0
+	m.ntMapping = 0
+	m.fillTile = 0
+	m.fillAttr = 0
+	m.prgRegs = [5]byte{0, 0, 0, 0, 0xFF}
+	m.chrRegs = [12]int{}
+	m.chrUpper = 0
+	m.chrLastSet = 0
+	m.multiplier = [2]byte{0xFF, 0xFF}
+	m.splitCtrl = 0
+	m.splitScroll = 0
+	m.splitBank = 0
+	m.irqTarget = 0
+	m.irqEnable = false
+	m.irqStatus = false
+	m.irqPending = false
+	m.inFrame = false
+	m.scanline = 0
+	m.fetched = false
+	m.fetchKind = FetchIdle
+	m.tallSprite = false
+	m.tileIndex = 0
+
+	m.audio.Reset()
+	m.updatePRG()
+	m.updateCHR()
+}
+
+func (m *Mapper5) setPRG(idx int, bank byte, rom bool) {
+	m.prgROM[idx] = rom
+
+	if rom {
+		m.prgBank[idx] = int(bank&0x7F) * 0x2000 % len(m.rom.PRG)
+	} else if len(m.sram) > 0 {
+		m.prgBank[idx] = int(bank&0x0F) * 0x2000 % len(m.sram)
+	}
+}
+
+func (m *Mapper5) updatePRG() {
+	regs := &m.prgRegs
+
+	// $6000-$7FFF is always mapped to RAM.
+	m.setPRG(0, regs[0], false)
+
+	switch m.prgMode & 0x03 {
+	case 0: // One 32 KB bank.
+		bank := regs[4] & 0x7C
+		m.setPRG(1, bank|0, true)
+		m.setPRG(2, bank|1, true)
+		m.setPRG(3, bank|2, true)
+		m.setPRG(4, bank|3, true)
+	case 1: // Two 16 KB banks.
+		bank := regs[2] & 0xFE
+		m.setPRG(1, bank|0, regs[2]&0x80 != 0)
+		m.setPRG(2, bank|1, regs[2]&0x80 != 0)
+		bank = regs[4] & 0x7E
+		m.setPRG(3, bank|0, true)
+		m.setPRG(4, bank|1, true)
+	case 2: // One 16 KB bank and two 8 KB banks.
+		bank := regs[2] & 0xFE
+		m.setPRG(1, bank|0, regs[2]&0x80 != 0)
+		m.setPRG(2, bank|1, regs[2]&0x80 != 0)
+		m.setPRG(3, regs[3], regs[3]&0x80 != 0)
+		m.setPRG(4, regs[4], true)
+	case 3: // Four 8 KB banks.
+		m.setPRG(1, regs[1], regs[1]&0x80 != 0)
+		m.setPRG(2, regs[2], regs[2]&0x80 != 0)
+		m.setPRG(3, regs[3], regs[3]&0x80 != 0)
+		m.setPRG(4, regs[4], true)
+	}
+}
+
+func (m *Mapper5) chrOffset(bank int) int {
+	bank %= len(m.rom.CHR) / 0x0400
+	return bank * 0x0400
+}
+
+func (m *Mapper5) updateCHR() {
+	regs := &m.chrRegs
+
+	switch m.chrMode & 0x03 {
+	case 0: // 8 KB banks.
+		for i := 0; i < 8; i++ {
+			m.chrBankA[i] = m.chrOffset(regs[7]*8 + i)
+			m.chrBankB[i] = m.chrOffset(regs[11]*8 + i)
+		}
+	case 1: // 4 KB banks.
+		for i := 0; i < 4; i++ {
+			m.chrBankA[i] = m.chrOffset(regs[3]*4 + i)
+			m.chrBankA[i+4] = m.chrOffset(regs[7]*4 + i)
+			m.chrBankB[i] = m.chrOffset(regs[11]*4 + i)
+			m.chrBankB[i+4] = m.chrBankB[i]
+		}
+	case 2: // 2 KB banks.
+		for i := 0; i < 2; i++ {
+			m.chrBankA[i] = m.chrOffset(regs[1]*2 + i)
+			m.chrBankA[i+2] = m.chrOffset(regs[3]*2 + i)
+			m.chrBankA[i+4] = m.chrOffset(regs[5]*2 + i)
+			m.chrBankA[i+6] = m.chrOffset(regs[7]*2 + i)
+			m.chrBankB[i] = m.chrOffset(regs[9]*2 + i)
+			m.chrBankB[i+2] = m.chrOffset(regs[11]*2 + i)
+			m.chrBankB[i+4] = m.chrBankB[i]
+			m.chrBankB[i+6] = m.chrBankB[i+2]
+		}
+	case 3: // 1 KB banks.
+		for i := 0; i < 8; i++ {
+			m.chrBankA[i] = m.chrOffset(regs[i])
+			m.chrBankB[i] = m.chrOffset(regs[8+i%4])
+		}
+	}
+}
+
+func (m *Mapper5) ScanlineTick() {
+	// When nothing was fetched during the scanline, the rendering is off.
+	if !m.fetched {
+		m.inFrame = false
+		return
+	}
+
+	m.fetched = false
+
+	if !m.inFrame {
+		m.inFrame = true
+		m.scanline = 0
+		return
+	}
+
+	m.scanline++
+
+	if m.scanline == int(m.irqTarget) {
+		m.irqStatus = true
+		m.irqPending = m.irqEnable
+	}
+}
+
+func (m *Mapper5) PendingIRQ() (v bool) {
+	v, m.irqPending = m.irqPending, false
+	return v
+}
+
+func (m *Mapper5) ConnectPPU(ciram *[2][1024]byte) PPUEvent {
+	m.ciram = ciram
+	return EventFetchStart | EventNametableRead | EventNametableWrite
+}
+
+func (m *Mapper5) PPUAccess(a BusAccess) byte {
+	switch a.Event {
+	case EventFetchStart:
+		m.startFetch(a.Kind, a.TallSprites)
+	case EventNametableRead:
+		return m.readNametable(a.Addr, a.Kind == FetchPeek)
+	case EventNametableWrite:
+		m.writeNametable(a.Addr, a.Data)
+	}
+
+	return 0
+}
+
+func (m *Mapper5) startFetch(kind FetchKind, tallSprites bool) {
+	m.fetchKind = kind
+	m.tallSprite = tallSprites
+
+	switch kind {
+	case FetchIdle:
+		m.inFrame = false
+	case FetchBackground:
+		m.tileIndex = 0
+		m.fetched = true
+	case FetchSprite:
+		m.fetched = true
+	}
+}
+
+func (m *Mapper5) MirrorMode() MirrorMode {
+	// Not used, since the nametables are handled by the mapper itself.
+	return MirrorVertical
+}
+
+func (m *Mapper5) readRegister(addr uint16) byte {
+	switch {
+	case addr == 0x5015:
+		return m.audio.Read(addr)
+	case addr == 0x5204:
+		var status byte
+		if m.irqStatus {
+			status |= 0x80
+		}
+		if m.inFrame {
+			status |= 0x40
+		}
+
+		m.irqStatus = false
+		return status
+	case addr == 0x5205:
+		return byte(uint16(m.multiplier[0]) * uint16(m.multiplier[1]))
+	case addr == 0x5206:
+		return byte(uint16(m.multiplier[0]) * uint16(m.multiplier[1]) >> 8)
+	case addr >= 0x5C00 && addr <= 0x5FFF:
+		if m.exramMode >= 2 {
+			return m.exram[addr-0x5C00]
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+func (m *Mapper5) writeRegister(addr uint16, data byte) {
+	switch {
+	case addr >= 0x5000 && addr <= 0x5015:
+		m.audio.Write(addr, data)
+	case addr == 0x5100:
+		m.prgMode = data & 0x03
+		m.updatePRG()
+	case addr == 0x5101:
+		m.chrMode = data & 0x03
+		m.updateCHR()
+	case addr == 0x5102:
+		m.ramProtect[0] = data & 0x03
+	case addr == 0x5103:
+		m.ramProtect[1] = data & 0x03
+	case addr == 0x5104:
+		m.exramMode = data & 0x03
+	case addr == 0x5105:
+		m.ntMapping = data
+	case addr == 0x5106:
+		m.fillTile = data
+	case addr == 0x5107:
+		m.fillAttr = data & 0x03
+	case addr >= 0x5113 && addr <= 0x5117:
+		m.prgRegs[addr-0x5113] = data
+		m.updatePRG()
+	case addr >= 0x5120 && addr <= 0x512B:
+		m.chrRegs[addr-0x5120] = int(data) | int(m.chrUpper)<<8
+		m.chrLastSet = 0
+		if addr >= 0x5128 {
+			m.chrLastSet = 1
+		}
+		m.updateCHR()
+	case addr == 0x5130:
+		m.chrUpper = data & 0x03
+	case addr == 0x5200:
+		m.splitCtrl = data
+	case addr == 0x5201:
+		m.splitScroll = data
+	case addr == 0x5202:
+		m.splitBank = data
+	case addr == 0x5203:
+		m.irqTarget = data
+	case addr == 0x5204:
+		m.irqEnable = data&0x80 != 0
+		if m.irqEnable && m.irqStatus {
+			m.irqPending = true
+		}
+	case addr == 0x5205:
+		m.multiplier[0] = data
+	case addr == 0x5206:
+		m.multiplier[1] = data
+	case addr >= 0x5C00 && addr <= 0x5FFF:
+		switch m.exramMode {
+		case 0, 1:
+			// Only writable during rendering, otherwise zero is written.
+			if !m.inFrame {
+				data = 0
+			}
+			m.exram[addr-0x5C00] = data
+		case 2:
+			m.exram[addr-0x5C00] = data
+		}
+	default:
+		log.Printf("[WARN] mapper5: unhandled register write at %04X: %02X", addr, data)
+	}
+}
+
+func (m *Mapper5) ramWritable() bool {
+	return m.ramProtect[0] == 0x02 && m.ramProtect[1] == 0x01
+}
+
+func (m *Mapper5) ReadPRG(addr uint16) byte {
+	if addr < 0x6000 {
+		return m.readRegister(addr)
+	}
+
+	// Reading the NMI vector means the end of the frame.
+	if addr == 0xFFFA || addr == 0xFFFB {
+		m.inFrame = false
+		m.scanline = 0
+	}
+
+	idx := int(addr-0x6000) / 0x2000
+	offset := int(addr) % 0x2000
+
+	if m.prgROM[idx] {
+		return m.rom.PRG[m.prgBank[idx]+offset]
+	}
+
+	if len(m.sram) == 0 {
+		return 0
+	}
+
+	return m.sram[(m.prgBank[idx]+offset)%len(m.sram)]
+}
+
+func (m *Mapper5) WritePRG(addr uint16, data byte) {
+	if addr < 0x6000 {
+		m.writeRegister(addr, data)
+		return
+	}
+
+	idx := int(addr-0x6000) / 0x2000
+	offset := int(addr) % 0x2000
+
+	if m.prgROM[idx] || !m.ramWritable() || len(m.sram) == 0 {
+		return
+	}
+
+	m.sram[(m.prgBank[idx]+offset)%len(m.sram)] = data
+}
+
+// chrBanks returns the CHR bank set for the current PPU fetch. In 8x16 sprite
+// mode, the sprites and the background use separate sets of registers.
+func (m *Mapper5) chrBanks() *[8]int {
+	if m.tallSprite {
+		switch m.fetchKind {
+		case FetchSprite:
+			return &m.chrBankA
+		case FetchBackground:
+			return &m.chrBankB
+		}
+	}
+
+	if m.chrLastSet == 1 {
+		return &m.chrBankB
+	}
+
+	return &m.chrBankA
+}
+
+func (m *Mapper5) ReadCHR(addr uint16) byte {
+	if m.fetchKind == FetchBackground {
+		switch {
+		case m.tileSplit:
+			// Split screen has its own 4 KB bank and vertical scroll.
+			offset := int(m.splitBank)*0x1000 + int(addr&0x0FF8) + m.splitY&0x07
+			return m.rom.CHR[offset%len(m.rom.CHR)]
+		case m.exramMode == 1:
+			// Extended attributes select a 4 KB bank for each tile.
+			bank := int(m.tileExAttr&0x3F) | int(m.chrUpper)<<6
+			offset := bank*0x1000 + int(addr&0x0FFF)
+			return m.rom.CHR[offset%len(m.rom.CHR)]
+		}
+	}
+
+	banks := m.chrBanks()
+	return m.rom.CHR[banks[addr/0x0400]+int(addr%0x0400)]
+}
+
+func (m *Mapper5) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper5: write to read-only chr at %04X", addr)
+		return
+	}
+
+	banks := m.chrBanks()
+	m.rom.CHR[banks[addr/0x0400]+int(addr%0x0400)] = data
+}
+
+// inSplit returns true if the given background tile is inside the split region.
+func (m *Mapper5) inSplit(tile int) bool {
+	if m.splitCtrl&0x80 == 0 || m.exramMode >= 2 {
+		return false
+	}
+
+	threshold := int(m.splitCtrl & 0x1F)
+	if m.splitCtrl&0x40 != 0 {
+		return tile >= threshold // right side
+	}
+
+	return tile < threshold // left side
+}
+
+// readNametable returns the nametable byte at the given address. The background
+// tile fetches are counted to know the horizontal position for the split screen
+// and the extended attributes, unless it is only a peek.
+func (m *Mapper5) readNametable(addr uint16, peek bool) byte {
+	offset := int(addr & 0x03FF)
+
+	if m.fetchKind == FetchBackground && !peek {
+		if offset < 0x03C0 {
+			// Tile fetch, the attribute and pattern fetches will follow.
+			tileX := m.tileIndex
+			m.tileIndex++
+			m.tileExAttr = m.exram[offset]
+			m.tileSplit = m.inSplit(tileX)
+
+			if m.tileSplit {
+				m.splitY = (int(m.splitScroll) + m.scanline) % 240
+				return m.exram[m.splitY/8*32+tileX%32]
+			}
+		} else {
+			switch {
+			case m.tileSplit:
+				tileX, tileY := (m.tileIndex-1)%32, m.splitY/8
+				attr := m.exram[0x03C0+tileY/4*8+tileX/4]
+				shift := (tileY & 0x02 << 1) | (tileX & 0x02)
+				return (attr >> shift & 0x03) * 0x55
+			case m.exramMode == 1:
+				return (m.tileExAttr >> 6) * 0x55
+			}
+		}
+	}
+
+	switch (m.ntMapping >> ((addr >> 10 & 0x03) * 2)) & 0x03 {
+	case 0:
+		return m.ciram[0][offset]
+	case 1:
+		return m.ciram[1][offset]
+	case 2:
+		if m.exramMode <= 1 {
+			return m.exram[offset]
+		}
+		return 0
+	default: // fill mode
+		if offset < 0x03C0 {
+			return m.fillTile
+		}
+		return m.fillAttr * 0x55
+	}
+}
+
+func (m *Mapper5) writeNametable(addr uint16, data byte) {
+	offset := int(addr & 0x03FF)
+
+	switch (m.ntMapping >> ((addr >> 10 & 0x03) * 2)) & 0x03 {
+	case 0:
+		m.ciram[0][offset] = data
+	case 1:
+		m.ciram[1][offset] = data
+	case 2:
+		if m.exramMode <= 1 {
+			m.exram[offset] = data
+		}
+	}
+}
+
+func (m *Mapper5) TickAudio() {
+	m.audio.Tick()
+}
+
+func (m *Mapper5) AudioOutput() float32 {
+	return m.audio.Output()
+}
+
+func (m *Mapper5) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper5) SaveState(w *binario.Writer) error {
+	err := errors.Join(
+		m.rom.SaveState(w),
+		m.audio.SaveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteByteSlice(m.exram[:]),
+		w.WriteUint8(m.prgMode),
+		w.WriteUint8(m.chrMode),
+		w.WriteByteSlice(m.ramProtect[:]),
+		w.WriteUint8(m.exramMode),
+		w.WriteUint8(m.ntMapping),
+		w.WriteUint8(m.fillTile),
+		w.WriteUint8(m.fillAttr),
+		w.WriteByteSlice(m.prgRegs[:]),
+		w.WriteUint8(m.chrUpper),
+		w.WriteUint8(m.chrLastSet),
+		w.WriteByteSlice(m.multiplier[:]),
+		w.WriteUint8(m.splitCtrl),
+		w.WriteUint8(m.splitScroll),
+		w.WriteUint8(m.splitBank),
+		w.WriteUint8(m.irqTarget),
+		w.WriteBool(m.irqEnable),
+		w.WriteBool(m.irqStatus),
+		w.WriteBool(m.irqPending),
+		w.WriteBool(m.inFrame),
+		w.WriteUint32(uint32(m.scanline)),
+		w.WriteBool(m.fetched),
+		w.WriteUint8(uint8(m.fetchKind)),
+		w.WriteBool(m.tallSprite),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range m.chrRegs {
+		if err := w.WriteUint16(uint16(m.chrRegs[i])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Mapper5) LoadState(r *binario.Reader) error {
+	var (
+		scanline  uint32
+		fetchKind uint8
+	)
+
+	err := errors.Join(
+		m.rom.LoadState(r),
+		m.audio.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadByteSliceTo(m.exram[:]),
+		r.ReadUint8To(&m.prgMode),
+		r.ReadUint8To(&m.chrMode),
+		r.ReadByteSliceTo(m.ramProtect[:]),
+		r.ReadUint8To(&m.exramMode),
+		r.ReadUint8To(&m.ntMapping),
+		r.ReadUint8To(&m.fillTile),
+		r.ReadUint8To(&m.fillAttr),
+		r.ReadByteSliceTo(m.prgRegs[:]),
+		r.ReadUint8To(&m.chrUpper),
+		r.ReadUint8To(&m.chrLastSet),
+		r.ReadByteSliceTo(m.multiplier[:]),
+		r.ReadUint8To(&m.splitCtrl),
+		r.ReadUint8To(&m.splitScroll),
+		r.ReadUint8To(&m.splitBank),
+		r.ReadUint8To(&m.irqTarget),
+		r.ReadBoolTo(&m.irqEnable),
+		r.ReadBoolTo(&m.irqStatus),
+		r.ReadBoolTo(&m.irqPending),
+		r.ReadBoolTo(&m.inFrame),
+		r.ReadUint32To(&scanline),
+		r.ReadBoolTo(&m.fetched),
+		r.ReadUint8To(&fetchKind),
+		r.ReadBoolTo(&m.tallSprite),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range m.chrRegs {
+		val, err := r.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		m.chrRegs[i] = int(val)
+	}
+
+	m.scanline = int(scanline)
+	m.fetchKind = FetchKind(fetchKind)
+
+	m.updatePRG()
+	m.updateCHR()
+
+	return nil
+}
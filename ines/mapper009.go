@@ -0,0 +1,186 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper9 implements the Nintendo MMC2 mapper used by Mike Tyson's Punch-Out!!.
+// It has one switchable 8 KB PRG bank and two 4 KB CHR banks, each having two
+// registers. Which of the registers is used is decided by a latch that flips
+// when the PPU fetches tile $FD or $FE from the corresponding pattern table,
+// so that the game can switch the graphics in the middle of a scanline.
+// https://www.nesdev.org/wiki/MMC2
+type Mapper9 struct {
+	rom     *ROM
+	sram    []byte
+	prgReg  byte    // $A000
+	chrRegs [4]byte // $B000-$E000
+	latch   [2]byte // $FD or $FE
+	mirror  byte    // $F000
+	prgBank int
+	chrBank [2]int
+}
+
+func NewMapper9(rom *ROM) *Mapper9 {
+	return &Mapper9{
+		rom:  rom,
+		sram: rom.newPRGRAM(),
+	}
+}
+
+func (m *Mapper9) Reset() {
+	m.prgReg = 0
+	m.chrRegs = [4]byte{}
+	m.latch = [2]byte{0xFE, 0xFE}
+	m.mirror = 0
+	m.updateBanks()
+}
+
+func (m *Mapper9) updateBanks() {
+	m.prgBank = int(m.prgReg&0x0F) * 0x2000 % len(m.rom.PRG)
+
+	for i := range m.chrBank {
+		reg := m.chrRegs[i*2]
+		if m.latch[i] == 0xFE {
+			reg = m.chrRegs[i*2+1]
+		}
+
+		m.chrBank[i] = int(reg&0x1F) * 0x1000 % len(m.rom.CHR)
+	}
+}
+
+func (m *Mapper9) ConnectPPU(ciram *[2][1024]byte) PPUEvent {
+	return EventPattern
+}
+
+func (m *Mapper9) PPUAccess(a BusAccess) byte {
+	if a.Event == EventPattern {
+		m.watchPattern(a.Addr)
+	}
+
+	return 0
+}
+
+func (m *Mapper9) watchPattern(addr uint16) {
+	// The latch of the first pattern table only reacts to the exact addresses,
+	// while the second one reacts to any row of the tile.
+	switch {
+	case addr == 0x0FD8:
+		m.latch[0] = 0xFD
+	case addr == 0x0FE8:
+		m.latch[0] = 0xFE
+	case addr >= 0x1FD8 && addr <= 0x1FDF:
+		m.latch[1] = 0xFD
+	case addr >= 0x1FE8 && addr <= 0x1FEF:
+		m.latch[1] = 0xFE
+	default:
+		return
+	}
+
+	m.updateBanks()
+}
+
+func (m *Mapper9) ScanlineTick() {}
+
+func (m *Mapper9) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper9) MirrorMode() MirrorMode {
+	if m.mirror&0x01 != 0 {
+		return MirrorHorizontal
+	}
+
+	return MirrorVertical
+}
+
+func (m *Mapper9) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) == 0 {
+			return 0
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case addr >= 0x8000 && addr <= 0x9FFF:
+		return m.rom.PRG[m.prgBank+int(addr-0x8000)]
+	case addr >= 0xA000:
+		// The last three 8 KB banks are fixed.
+		offset := len(m.rom.PRG) - 0x6000 + int(addr-0xA000)
+		return m.rom.PRG[offset]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper9) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
+	case addr >= 0xA000 && addr <= 0xAFFF:
+		m.prgReg = data
+		m.updateBanks()
+	case addr >= 0xB000 && addr <= 0xEFFF:
+		m.chrRegs[(addr-0xB000)/0x1000] = data
+		m.updateBanks()
+	case addr >= 0xF000:
+		m.mirror = data
+	default:
+		log.Printf("[WARN] mapper9: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper9) ReadCHR(addr uint16) byte {
+	bank := addr / 0x1000
+	offset := int(addr % 0x1000)
+	return m.rom.CHR[m.chrBank[bank]+offset]
+}
+
+func (m *Mapper9) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper9: write to read-only chr at %04X", addr)
+		return
+	}
+
+	bank := addr / 0x1000
+	offset := int(addr % 0x1000)
+	m.rom.CHR[m.chrBank[bank]+offset] = data
+}
+
+func (m *Mapper9) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper9) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteUint8(m.prgReg),
+		w.WriteByteSlice(m.chrRegs[:]),
+		w.WriteByteSlice(m.latch[:]),
+		w.WriteUint8(m.mirror),
+	)
+}
+
+func (m *Mapper9) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadUint8To(&m.prgReg),
+		r.ReadByteSliceTo(m.chrRegs[:]),
+		r.ReadByteSliceTo(m.latch[:]),
+		r.ReadUint8To(&m.mirror),
+	)
+
+	m.updateBanks()
+
+	return err
+}
@@ -0,0 +1,45 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper9_Banks(t *testing.T) {
+	m := NewMapper9(newTestROM(9, 128*1024, 128*1024))
+	m.Reset()
+
+	m.WritePRG(0xA000, 0x03)
+	testutil.Equal(t, m.ReadPRG(0x8000), 3)
+	testutil.Equal(t, m.ReadPRG(0xA000), 13) // the last three banks are fixed
+	testutil.Equal(t, m.ReadPRG(0xE000), 15)
+}
+
+func TestMapper9_CHRLatch(t *testing.T) {
+	m := NewMapper9(newTestROM(9, 128*1024, 128*1024))
+	m.Reset()
+
+	m.WritePRG(0xB000, 0x01) // $0000, latch $FD
+	m.WritePRG(0xC000, 0x02) // $0000, latch $FE
+	m.WritePRG(0xD000, 0x03) // $1000, latch $FD
+	m.WritePRG(0xE000, 0x04) // $1000, latch $FE
+
+	// Both latches start at $FE.
+	testutil.Equal(t, m.ReadCHR(0x0000), 8)
+	testutil.Equal(t, m.ReadCHR(0x1000), 16)
+
+	// The first latch only reacts to the first row of the tile.
+	m.PPUAccess(BusAccess{Event: EventPattern, Addr: 0x0FD9})
+	testutil.Equal(t, m.ReadCHR(0x0000), 8)
+	m.PPUAccess(BusAccess{Event: EventPattern, Addr: 0x0FD8})
+	testutil.Equal(t, m.ReadCHR(0x0000), 4)
+	testutil.Equal(t, m.ReadCHR(0x1000), 16)
+
+	// The second one reacts to any row.
+	m.PPUAccess(BusAccess{Event: EventPattern, Addr: 0x1FDF})
+	testutil.Equal(t, m.ReadCHR(0x1000), 12)
+	m.PPUAccess(BusAccess{Event: EventPattern, Addr: 0x1FEA})
+	testutil.Equal(t, m.ReadCHR(0x1000), 16)
+	testutil.Equal(t, m.ReadCHR(0x0000), 4)
+}
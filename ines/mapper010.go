@@ -0,0 +1,179 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper10 implements the Nintendo MMC4 mapper used by Fire Emblem and Famicom
+// Wars. It works the same way as MMC2 (see Mapper9), but switches 16 KB of PRG
+// instead of 8 KB, has 8 KB of PRG-RAM, and both CHR latches react to any row
+// of the $FD and $FE tiles.
+// https://www.nesdev.org/wiki/MMC4
+type Mapper10 struct {
+	rom     *ROM
+	sram    []byte
+	prgReg  byte    // $A000
+	chrRegs [4]byte // $B000-$E000
+	latch   [2]byte // $FD or $FE
+	mirror  byte    // $F000
+	prgBank int
+	chrBank [2]int
+}
+
+func NewMapper10(rom *ROM) *Mapper10 {
+	return &Mapper10{
+		rom:  rom,
+		sram: rom.newPRGRAM(),
+	}
+}
+
+func (m *Mapper10) Reset() {
+	m.prgReg = 0
+	m.chrRegs = [4]byte{}
+	m.latch = [2]byte{0xFE, 0xFE}
+	m.mirror = 0
+	m.updateBanks()
+}
+
+func (m *Mapper10) updateBanks() {
+	m.prgBank = int(m.prgReg&0x0F) * 0x4000 % len(m.rom.PRG)
+
+	for i := range m.chrBank {
+		reg := m.chrRegs[i*2]
+		if m.latch[i] == 0xFE {
+			reg = m.chrRegs[i*2+1]
+		}
+
+		m.chrBank[i] = int(reg&0x1F) * 0x1000 % len(m.rom.CHR)
+	}
+}
+
+func (m *Mapper10) ConnectPPU(ciram *[2][1024]byte) PPUEvent {
+	return EventPattern
+}
+
+func (m *Mapper10) PPUAccess(a BusAccess) byte {
+	if a.Event == EventPattern {
+		m.watchPattern(a.Addr)
+	}
+
+	return 0
+}
+
+func (m *Mapper10) watchPattern(addr uint16) {
+	switch addr & 0x0FF8 {
+	case 0x0FD8:
+		m.latch[addr>>12] = 0xFD
+	case 0x0FE8:
+		m.latch[addr>>12] = 0xFE
+	default:
+		return
+	}
+
+	m.updateBanks()
+}
+
+func (m *Mapper10) ScanlineTick() {}
+
+func (m *Mapper10) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper10) MirrorMode() MirrorMode {
+	if m.mirror&0x01 != 0 {
+		return MirrorHorizontal
+	}
+
+	return MirrorVertical
+}
+
+func (m *Mapper10) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) == 0 {
+			return 0
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case addr >= 0x8000 && addr <= 0xBFFF:
+		return m.rom.PRG[m.prgBank+int(addr-0x8000)]
+	case addr >= 0xC000:
+		// The last 16 KB bank is fixed.
+		offset := len(m.rom.PRG) - 0x4000 + int(addr-0xC000)
+		return m.rom.PRG[offset]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper10) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
+	case addr >= 0xA000 && addr <= 0xAFFF:
+		m.prgReg = data
+		m.updateBanks()
+	case addr >= 0xB000 && addr <= 0xEFFF:
+		m.chrRegs[(addr-0xB000)/0x1000] = data
+		m.updateBanks()
+	case addr >= 0xF000:
+		m.mirror = data
+	default:
+		log.Printf("[WARN] mapper10: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper10) ReadCHR(addr uint16) byte {
+	bank := addr / 0x1000
+	offset := int(addr % 0x1000)
+	return m.rom.CHR[m.chrBank[bank]+offset]
+}
+
+func (m *Mapper10) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper10: write to read-only chr at %04X", addr)
+		return
+	}
+
+	bank := addr / 0x1000
+	offset := int(addr % 0x1000)
+	m.rom.CHR[m.chrBank[bank]+offset] = data
+}
+
+func (m *Mapper10) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper10) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteUint8(m.prgReg),
+		w.WriteByteSlice(m.chrRegs[:]),
+		w.WriteByteSlice(m.latch[:]),
+		w.WriteUint8(m.mirror),
+	)
+}
+
+func (m *Mapper10) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadUint8To(&m.prgReg),
+		r.ReadByteSliceTo(m.chrRegs[:]),
+		r.ReadByteSliceTo(m.latch[:]),
+		r.ReadUint8To(&m.mirror),
+	)
+
+	m.updateBanks()
+
+	return err
+}
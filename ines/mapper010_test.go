@@ -0,0 +1,36 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper10_Banks(t *testing.T) {
+	m := NewMapper10(newTestROM(10, 128*1024, 128*1024))
+	m.Reset()
+
+	m.WritePRG(0xA000, 0x03)
+	testutil.Equal(t, m.ReadPRG(0x8000), 6)
+	testutil.Equal(t, m.ReadPRG(0xC000), 14) // the last bank is fixed
+}
+
+func TestMapper10_CHRLatch(t *testing.T) {
+	m := NewMapper10(newTestROM(10, 128*1024, 128*1024))
+	m.Reset()
+
+	m.WritePRG(0xB000, 0x01)
+	m.WritePRG(0xC000, 0x02)
+	m.WritePRG(0xD000, 0x03)
+	m.WritePRG(0xE000, 0x04)
+
+	// Unlike MMC2, both latches react to any row of the tile.
+	m.PPUAccess(BusAccess{Event: EventPattern, Addr: 0x0FDD})
+	testutil.Equal(t, m.ReadCHR(0x0000), 4)
+	m.PPUAccess(BusAccess{Event: EventPattern, Addr: 0x0FEF})
+	testutil.Equal(t, m.ReadCHR(0x0000), 8)
+
+	m.PPUAccess(BusAccess{Event: EventPattern, Addr: 0x1FD8})
+	testutil.Equal(t, m.ReadCHR(0x1000), 12)
+	testutil.Equal(t, m.ReadCHR(0x0000), 8)
+}
@@ -0,0 +1,96 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper11 implements the Color Dreams board, used by most of the unlicensed
+// Color Dreams and Wisdom Tree games. A single register selects a 32 KB PRG
+// bank and an 8 KB CHR bank.
+// https://www.nesdev.org/wiki/Color_Dreams
+type Mapper11 struct {
+	rom       *ROM
+	conflicts bool
+	prgBank   uint8
+	chrBank   uint8
+}
+
+func NewMapper11(rom *ROM) *Mapper11 {
+	return &Mapper11{
+		rom:       rom,
+		conflicts: rom.busConflicts(true),
+	}
+}
+
+func (m *Mapper11) Reset() {
+	m.prgBank = 0
+	m.chrBank = 0
+}
+
+func (m *Mapper11) ScanlineTick() {}
+
+func (m *Mapper11) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper11) MirrorMode() MirrorMode {
+	return m.rom.MirrorMode
+}
+
+func (m *Mapper11) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x8000:
+		idx := int(m.prgBank)*0x8000 + int(addr-0x8000)
+		return m.rom.PRG[idx%len(m.rom.PRG)]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper11) WritePRG(addr uint16, data byte) {
+	if addr < 0x8000 {
+		log.Printf("[WARN] mapper11: unhandled prg write at %04X", addr)
+		return
+	}
+
+	if m.conflicts {
+		data &= m.ReadPRG(addr)
+	}
+
+	m.prgBank = data & 0x03
+	m.chrBank = data >> 4
+}
+
+func (m *Mapper11) ReadCHR(addr uint16) byte {
+	idx := int(m.chrBank)*0x2000 + int(addr)
+	return m.rom.CHR[idx%len(m.rom.CHR)]
+}
+
+func (m *Mapper11) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper11: write to read-only chr at %04X", addr)
+		return
+	}
+
+	idx := int(m.chrBank)*0x2000 + int(addr)
+	m.rom.CHR[idx%len(m.rom.CHR)] = data
+}
+
+func (m *Mapper11) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteUint8(m.prgBank),
+		w.WriteUint8(m.chrBank),
+	)
+}
+
+func (m *Mapper11) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		m.rom.LoadState(r),
+		r.ReadUint8To(&m.prgBank),
+		r.ReadUint8To(&m.chrBank),
+	)
+}
@@ -0,0 +1,42 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper11_Banks(t *testing.T) {
+	m := NewMapper11(newTestROM(11, 128*1024, 128*1024))
+	m.Reset()
+
+	// $80FF has $FF in the ROM, so the bus conflict does not change the value.
+	m.WritePRG(0x80FF, 0x32)
+	testutil.Equal(t, m.ReadPRG(0x8000), 8)
+	testutil.Equal(t, m.ReadPRG(0xE000), 11)
+	testutil.Equal(t, m.ReadCHR(0x0000), 24)
+}
+
+func TestMapper11_BusConflicts(t *testing.T) {
+	tests := map[string]struct {
+		submapper uint8
+		bank      byte
+	}{
+		"conflicts":    {submapper: 0, bank: 0},
+		"no conflicts": {submapper: 1, bank: 8},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rom := newTestROM(11, 128*1024, 128*1024)
+			rom.Submapper = tt.submapper
+
+			m := NewMapper11(rom)
+			m.Reset()
+
+			// $8000 has $00 in the ROM.
+			m.WritePRG(0x8000, 0x02)
+			testutil.Equal(t, m.ReadPRG(0x8000), tt.bank)
+		})
+	}
+}
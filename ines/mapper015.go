@@ -0,0 +1,153 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper15 implements the K-1029/K-1030P boards used by the "100-in-1 Contra
+// Function 16" multicarts. A single register, written with the mode in the low
+// bits of the address, turns the board into NROM-256, UNROM, NROM-64 or NROM-128.
+// https://www.nesdev.org/wiki/INES_Mapper_015
+type Mapper15 struct {
+	rom     *ROM
+	sram    []byte
+	mode    uint8
+	data    uint8
+	prgBank [4]int // 8 KB banks
+}
+
+func NewMapper15(rom *ROM) *Mapper15 {
+	return &Mapper15{
+		rom:  rom,
+		sram: rom.newPRGRAM(),
+	}
+}
+
+func (m *Mapper15) Reset() {
+	m.mode = 0
+	m.data = 0
+	m.updateBanks()
+}
+
+func (m *Mapper15) updateBanks() {
+	var (
+		numPRG = len(m.rom.PRG) / 0x2000
+		bank   = int(m.data & 0x3F) // 16 KB
+		sub    = int(m.data >> 7)   // 8 KB half, only used in NROM-64 mode
+	)
+
+	var lo, hi int // 16 KB banks at $8000 and $C000
+
+	switch m.mode {
+	case 0: // NROM-256
+		lo, hi = bank&^1, bank|1
+	case 1: // UNROM
+		lo, hi = bank, bank|7
+	case 2: // NROM-64, the same 8 KB everywhere
+		b := bank*2 | sub
+		for i := range m.prgBank {
+			m.prgBank[i] = b % numPRG * 0x2000
+		}
+		return
+	case 3: // NROM-128
+		lo, hi = bank, bank
+	}
+
+	banks := [4]int{lo * 2, lo*2 + 1, hi * 2, hi*2 + 1}
+
+	for i, b := range banks {
+		m.prgBank[i] = b % numPRG * 0x2000
+	}
+}
+
+func (m *Mapper15) ScanlineTick() {}
+
+func (m *Mapper15) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper15) MirrorMode() MirrorMode {
+	if m.data&0x40 != 0 {
+		return MirrorHorizontal
+	}
+
+	return MirrorVertical
+}
+
+func (m *Mapper15) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) == 0 {
+			return 0
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x2000
+		offset := int(addr % 0x2000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		log.Printf("[WARN] mapper15: unhandled prg read at %04X", addr)
+		return 0
+	}
+}
+
+func (m *Mapper15) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
+	case addr >= 0x8000:
+		m.mode = uint8(addr & 0x03)
+		m.data = data
+		m.updateBanks()
+	default:
+		log.Printf("[WARN] mapper15: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper15) ReadCHR(addr uint16) byte {
+	return m.rom.CHR[int(addr)%len(m.rom.CHR)]
+}
+
+func (m *Mapper15) WriteCHR(addr uint16, data byte) {
+	// CHR-RAM is write-protected in the NROM modes.
+	if !m.rom.chrRAM || m.mode == 0 || m.mode == 3 {
+		return
+	}
+
+	m.rom.CHR[int(addr)%len(m.rom.CHR)] = data
+}
+
+func (m *Mapper15) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper15) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteUint8(m.mode),
+		w.WriteUint8(m.data),
+	)
+}
+
+func (m *Mapper15) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadUint8To(&m.mode),
+		r.ReadUint8To(&m.data),
+	)
+
+	m.updateBanks()
+
+	return err
+}
@@ -0,0 +1,58 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+// The test ROM has the number of the 8 KB PRG bank (1 KB CHR bank) in the
+// first byte of every bank, see newTestROM.
+
+func TestMapper15_Banks(t *testing.T) {
+	tests := map[string]struct {
+		addr  uint16
+		data  byte
+		banks [4]byte // 8 KB banks at $8000, $A000, $C000, $E000
+	}{
+		"NROM-256": {addr: 0x8000, data: 0x05, banks: [4]byte{8, 9, 10, 11}},
+		"UNROM":    {addr: 0x8001, data: 0x0A, banks: [4]byte{20, 21, 30, 31}},
+		"NROM-64":  {addr: 0x8002, data: 0x83, banks: [4]byte{7, 7, 7, 7}},
+		"NROM-128": {addr: 0x8003, data: 0x21, banks: [4]byte{66, 67, 66, 67}},
+		"last":     {addr: 0x8003, data: 0x3F, banks: [4]byte{126, 127, 126, 127}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := NewMapper15(newTestROM(15, 1024*1024, 0x2000))
+			m.Reset()
+			m.WritePRG(tt.addr, tt.data)
+
+			for i, bank := range tt.banks {
+				testutil.Equal(t, m.ReadPRG(0x8000+uint16(i)*0x2000), bank)
+			}
+		})
+	}
+}
+
+func TestMapper15_CHRWriteProtect(t *testing.T) {
+	rom := newTestROM(15, 1024*1024, 0x2000)
+	rom.chrRAM = true
+
+	m := NewMapper15(rom)
+	m.Reset()
+
+	// Writable in the UNROM and NROM-64 modes only.
+	m.WritePRG(0x8000, 0x00)
+	m.WriteCHR(0x0010, 0xAA)
+	testutil.Equal(t, m.ReadCHR(0x0010), 0x10)
+
+	m.WritePRG(0x8001, 0x00)
+	m.WriteCHR(0x0010, 0xAA)
+	testutil.Equal(t, m.ReadCHR(0x0010), 0xAA)
+
+	m.WritePRG(0x8003, 0x40)
+	m.WriteCHR(0x0010, 0xBB)
+	testutil.Equal(t, m.ReadCHR(0x0010), 0xAA)
+	testutil.Equal(t, m.MirrorMode(), MirrorHorizontal)
+}
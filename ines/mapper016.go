@@ -0,0 +1,291 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper16 implements the Bandai FCG family of mappers (iNES mappers #16, #153
+// and #159). The older FCG-1/2 chips have their registers at $6000-$7FFF, while
+// the later LZ93D50 has them at $8000-$FFFF and can talk to a serial EEPROM,
+// which is where most of these games keep their saves. The board for mapper
+// 153 has 8 KB of battery-backed SRAM and 512 KB of PRG-ROM instead, with the
+// outer PRG bank selected by the CHR registers. When the submapper is unknown,
+// the registers are mapped in both ranges.
+// https://www.nesdev.org/wiki/Bandai_FCG_board
+type Mapper16 struct {
+	rom     *ROM
+	sram    []byte       // mapper 153 only
+	eeprom  *eeprom24C0x // nil if there is none
+	lowReg  bool         // registers at $6000-$7FFF
+	highReg bool         // registers at $8000-$FFFF
+	fcg     bool         // IRQ counter is written directly, without the latch
+
+	chrRegs    [8]byte
+	prgReg     byte
+	mirror     byte
+	control    byte // $800D
+	prgBank    [2]int
+	chrBank    [8]int
+	irqEnable  bool
+	irqCounter uint16
+	irqLatch   uint16
+	irqPending bool
+}
+
+func NewMapper16(rom *ROM) *Mapper16 {
+	m := &Mapper16{rom: rom}
+
+	switch {
+	case rom.MapperID == 153:
+		m.sram = rom.newPRGRAM()
+		m.highReg = true
+	case rom.MapperID == 159:
+		m.eeprom = newEEPROM24C01()
+		m.highReg = true
+	case rom.Submapper == 4:
+		m.lowReg = true
+		m.fcg = true
+	case rom.Submapper == 5:
+		m.highReg = true
+		if rom.Battery {
+			m.eeprom = newEEPROM24C02()
+		}
+	default:
+		m.lowReg = true
+		m.highReg = true
+		if rom.Battery {
+			m.eeprom = newEEPROM24C02()
+		}
+	}
+
+	return m
+}
+
+func (m *Mapper16) Reset() {
+	m.chrRegs = [8]byte{}
+	m.prgReg = 0
+	m.mirror = 0
+	m.control = 0
+	m.irqEnable = false
+	m.irqCounter = 0
+	m.irqLatch = 0
+	m.irqPending = false
+
+	if m.eeprom != nil {
+		m.eeprom.reset()
+	}
+
+	m.updateBanks()
+}
+
+func (m *Mapper16) updateBanks() {
+	var (
+		numPRG = len(m.rom.PRG) / 0x4000
+		outer  = 0
+	)
+
+	// On mapper 153, bit 0 of any of the CHR registers selects the 256 KB
+	// half of the PRG-ROM. The CHR itself is 8 KB of unbanked RAM.
+	if m.rom.MapperID == 153 {
+		for _, reg := range m.chrRegs {
+			outer |= int(reg&0x01) << 4
+		}
+	}
+
+	m.prgBank[0] = (outer | int(m.prgReg&0x0F)) % numPRG * 0x4000
+	m.prgBank[1] = (outer | 0x0F) % numPRG * 0x4000
+
+	for i, reg := range m.chrRegs {
+		if m.rom.MapperID == 153 {
+			m.chrBank[i] = i * 0x0400 % len(m.rom.CHR)
+		} else {
+			m.chrBank[i] = int(reg) * 0x0400 % len(m.rom.CHR)
+		}
+	}
+}
+
+func (m *Mapper16) ScanlineTick() {}
+
+func (m *Mapper16) TickCPU() {
+	if !m.irqEnable {
+		return
+	}
+
+	// The counter is checked before decrementing, which some games rely on.
+	if m.irqCounter == 0 {
+		m.irqPending = true
+	}
+
+	m.irqCounter--
+}
+
+func (m *Mapper16) PendingIRQ() bool {
+	return m.irqPending
+}
+
+func (m *Mapper16) MirrorMode() MirrorMode {
+	switch m.mirror & 0x03 {
+	case 0:
+		return MirrorVertical
+	case 1:
+		return MirrorHorizontal
+	case 2:
+		return MirrorSingle0
+	default:
+		return MirrorSingle1
+	}
+}
+
+func (m *Mapper16) writeRegister(addr uint16, data byte) {
+	switch reg := addr & 0x0F; {
+	case reg <= 0x07:
+		m.chrRegs[reg] = data
+		m.updateBanks()
+	case reg == 0x08:
+		m.prgReg = data
+		m.updateBanks()
+	case reg == 0x09:
+		m.mirror = data
+	case reg == 0x0A:
+		m.irqEnable = data&0x01 != 0
+		m.irqPending = false
+		if !m.fcg {
+			m.irqCounter = m.irqLatch
+		}
+	case reg == 0x0B:
+		m.irqLatch = m.irqLatch&0xFF00 | uint16(data)
+		if m.fcg {
+			m.irqCounter = m.irqCounter&0xFF00 | uint16(data)
+		}
+	case reg == 0x0C:
+		m.irqLatch = m.irqLatch&0x00FF | uint16(data)<<8
+		if m.fcg {
+			m.irqCounter = m.irqCounter&0x00FF | uint16(data)<<8
+		}
+	case reg == 0x0D:
+		m.control = data
+		if m.eeprom != nil {
+			m.eeprom.write(data&0x20 != 0, data&0x40 != 0)
+		}
+	}
+}
+
+func (m *Mapper16) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		switch {
+		case m.sram != nil:
+			// On mapper 153, bit 5 of $800D enables the SRAM.
+			if m.control&0x20 == 0 || len(m.sram) == 0 {
+				return 0
+			}
+			return m.sram[int(addr-0x6000)%len(m.sram)]
+		case m.eeprom != nil:
+			if m.eeprom.read() {
+				return 0x10
+			}
+			return 0
+		default:
+			return 0 // open bus
+		}
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x4000
+		offset := int(addr % 0x4000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper16) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF && m.lowReg:
+		m.writeRegister(addr, data)
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if m.control&0x20 != 0 && len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
+	case addr >= 0x8000 && m.highReg:
+		m.writeRegister(addr, data)
+	default:
+		log.Printf("[WARN] mapper16: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper16) ReadCHR(addr uint16) byte {
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	return m.rom.CHR[m.chrBank[bank]+offset]
+}
+
+func (m *Mapper16) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper16: write to read-only chr at %04X", addr)
+		return
+	}
+
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	m.rom.CHR[m.chrBank[bank]+offset] = data
+}
+
+// BatteryRAM returns the contents of the EEPROM, or the SRAM on mapper 153,
+// so that they are persisted the same way. The EEPROM does not need a battery
+// to keep its data, so it is returned even if the header does not have one.
+func (m *Mapper16) BatteryRAM() []byte {
+	switch {
+	case m.eeprom != nil:
+		return m.eeprom.data
+	case !m.rom.Battery:
+		return nil
+	default:
+		return m.sram
+	}
+}
+
+func (m *Mapper16) SaveState(w *binario.Writer) error {
+	err := errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteByteSlice(m.chrRegs[:]),
+		w.WriteUint8(m.prgReg),
+		w.WriteUint8(m.mirror),
+		w.WriteUint8(m.control),
+		w.WriteBool(m.irqEnable),
+		w.WriteUint16(m.irqCounter),
+		w.WriteUint16(m.irqLatch),
+		w.WriteBool(m.irqPending),
+	)
+
+	if err == nil && m.eeprom != nil {
+		err = m.eeprom.saveState(w)
+	}
+
+	return err
+}
+
+func (m *Mapper16) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadByteSliceTo(m.chrRegs[:]),
+		r.ReadUint8To(&m.prgReg),
+		r.ReadUint8To(&m.mirror),
+		r.ReadUint8To(&m.control),
+		r.ReadBoolTo(&m.irqEnable),
+		r.ReadUint16To(&m.irqCounter),
+		r.ReadUint16To(&m.irqLatch),
+		r.ReadBoolTo(&m.irqPending),
+	)
+
+	if err == nil && m.eeprom != nil {
+		err = m.eeprom.loadState(r)
+	}
+
+	m.updateBanks()
+
+	return err
+}
@@ -0,0 +1,66 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper16_Registers(t *testing.T) {
+	tests := map[string]struct {
+		submapper uint8
+		base      uint16 // where the registers are
+		ignored   uint16 // where the writes are ignored
+	}{
+		"FCG":     {submapper: 4, base: 0x6000, ignored: 0x8000},
+		"LZ93D50": {submapper: 5, base: 0x8000, ignored: 0x6000},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rom := newTestROM(16, 256*1024, 256*1024)
+			rom.Submapper = tt.submapper
+
+			m := NewMapper16(rom)
+			m.Reset()
+
+			m.WritePRG(tt.base+0x08, 0x03)
+			m.WritePRG(tt.base+0x02, 0x25)
+			m.WritePRG(tt.base+0x09, 0x01)
+			testutil.Equal(t, m.ReadPRG(0x8000), 6)
+			testutil.Equal(t, m.ReadPRG(0xC000), 30) // fixed to the last bank
+			testutil.Equal(t, m.ReadCHR(0x0800), 0x25)
+			testutil.Equal(t, m.MirrorMode(), MirrorHorizontal)
+
+			m.WritePRG(tt.ignored+0x08, 0x05)
+			testutil.Equal(t, m.ReadPRG(0x8000), 6)
+		})
+	}
+}
+
+func TestMapper16_Mapper153(t *testing.T) {
+	rom := newTestROM(153, 512*1024, 0x2000)
+	rom.chrRAM = true
+
+	m := NewMapper16(rom)
+	m.Reset()
+
+	// Bit 0 of the CHR registers selects the 256 KB outer bank.
+	m.WritePRG(0x8008, 0x03)
+	m.WritePRG(0x8000, 0x01)
+	testutil.Equal(t, m.ReadPRG(0x8000), 38)
+	testutil.Equal(t, m.ReadPRG(0xC000), 62)
+
+	// The CHR-RAM is not banked.
+	testutil.Equal(t, m.ReadCHR(0x0000), 0)
+	testutil.Equal(t, m.ReadCHR(0x0400), 1)
+
+	// The SRAM is only accessible with bit 5 of $800D set.
+	m.WritePRG(0x6000, 0xAA)
+	testutil.Equal(t, m.ReadPRG(0x6000), 0x00)
+	m.WritePRG(0x800D, 0x20)
+	m.WritePRG(0x6000, 0xAA)
+	testutil.Equal(t, m.ReadPRG(0x6000), 0xAA)
+	m.WritePRG(0x800D, 0x00)
+	testutil.Equal(t, m.ReadPRG(0x6000), 0x00)
+}
@@ -0,0 +1,308 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/apu"
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// namco163RAMSize is the size of the chip's internal RAM, shared by the sound
+// channels and the game, which may also use it as battery-backed memory.
+const namco163RAMSize = 128
+
+// Mapper19 implements the Namco 163 mapper (and the compatible Namco 129).
+// Besides the usual PRG/CHR banking, it can map the console's nametable RAM
+// into the pattern tables and CHR-ROM into the nametables.
+// https://www.nesdev.org/wiki/Namco_163
+type Mapper19 struct {
+	rom     *ROM
+	battery []byte // PRG-RAM followed by the internal RAM
+	sram    []byte
+	intRAM  []byte
+	audio   *apu.Namco163Audio
+	ciram   *[2][1024]byte
+
+	prgRegs    [3]byte // $E000, $E800, $F000
+	chrRegs    [8]byte // $8000-$BFFF
+	ntRegs     [4]byte // $C000-$DFFF
+	ramAddr    byte    // $F800, also the PRG-RAM write protection
+	prgBank    [4]int
+	irqCounter uint16
+	irqEnable  bool
+	irqPending bool
+}
+
+func NewMapper19(rom *ROM) *Mapper19 {
+	sram := rom.newPRGRAM()
+	sramSize := len(sram)
+
+	// The internal RAM is battery-backed along with the PRG-RAM.
+	battery := make([]byte, sramSize+namco163RAMSize)
+	copy(battery, sram)
+
+	return &Mapper19{
+		rom:     rom,
+		battery: battery,
+		sram:    battery[:sramSize],
+		intRAM:  battery[sramSize:],
+		audio:   apu.NewNamco163Audio(battery[sramSize:]),
+	}
+}
+
+func (m *Mapper19) Reset() {
+	m.prgRegs = [3]byte{}
+	m.chrRegs = [8]byte{}
+	m.ntRegs = [4]byte{}
+	m.ramAddr = 0
+	m.irqCounter = 0
+	m.irqEnable = false
+	m.irqPending = false
+
+	m.audio.Reset()
+	m.updateBanks()
+}
+
+func (m *Mapper19) ConnectPPU(ciram *[2][1024]byte) PPUEvent {
+	m.ciram = ciram
+	return EventNametableRead | EventNametableWrite
+}
+
+func (m *Mapper19) PPUAccess(a BusAccess) byte {
+	switch a.Event {
+	case EventNametableRead:
+		return m.readNametable(a.Addr)
+	case EventNametableWrite:
+		m.writeNametable(a.Addr, a.Data)
+	}
+
+	return 0
+}
+
+func (m *Mapper19) updateBanks() {
+	numPRG := len(m.rom.PRG) / 0x2000
+
+	for i, reg := range m.prgRegs {
+		m.prgBank[i] = int(reg&0x3F) % numPRG * 0x2000
+	}
+
+	m.prgBank[3] = (numPRG - 1) * 0x2000
+}
+
+func (m *Mapper19) ScanlineTick() {}
+
+func (m *Mapper19) TickCPU() {
+	if !m.irqEnable || m.irqCounter == 0x7FFF {
+		return
+	}
+
+	if m.irqCounter++; m.irqCounter == 0x7FFF {
+		m.irqPending = true
+	}
+}
+
+func (m *Mapper19) PendingIRQ() bool {
+	return m.irqPending
+}
+
+func (m *Mapper19) MirrorMode() MirrorMode {
+	return MirrorVertical // not used, see readNametable
+}
+
+// accessInternal returns the internal RAM address for the data port access,
+// and increments it if the auto-increment is enabled.
+func (m *Mapper19) accessInternal() int {
+	addr := int(m.ramAddr & 0x7F)
+	if m.ramAddr&0x80 != 0 {
+		m.ramAddr = 0x80 | (m.ramAddr+1)&0x7F
+	}
+
+	return addr
+}
+
+func (m *Mapper19) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x4800 && addr <= 0x4FFF:
+		return m.intRAM[m.accessInternal()]
+	case addr >= 0x5000 && addr <= 0x57FF:
+		return byte(m.irqCounter)
+	case addr >= 0x5800 && addr <= 0x5FFF:
+		var enable byte
+		if m.irqEnable {
+			enable = 0x80
+		}
+		return byte(m.irqCounter>>8) | enable
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) == 0 {
+			return 0
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x2000
+		offset := int(addr % 0x2000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		log.Printf("[WARN] mapper19: unhandled prg read at %04X", addr)
+		return 0
+	}
+}
+
+// sramWritable tells whether the given $6000-$7FFF address is writable. The
+// RAM is split into four 2 KB windows, enabled by the $F800 register.
+func (m *Mapper19) sramWritable(addr uint16) bool {
+	window := (addr - 0x6000) / 0x0800
+	return m.ramAddr&0xF0 == 0x40 && m.ramAddr&(1<<window) == 0
+}
+
+func (m *Mapper19) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x4800 && addr <= 0x4FFF:
+		m.intRAM[m.accessInternal()] = data
+	case addr >= 0x5000 && addr <= 0x57FF:
+		m.irqCounter = m.irqCounter&0x7F00 | uint16(data)
+		m.irqPending = false
+	case addr >= 0x5800 && addr <= 0x5FFF:
+		m.irqCounter = m.irqCounter&0x00FF | uint16(data&0x7F)<<8
+		m.irqEnable = data&0x80 != 0
+		m.irqPending = false
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) > 0 && m.sramWritable(addr) {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
+	case addr >= 0x8000 && addr <= 0xBFFF:
+		m.chrRegs[(addr-0x8000)/0x0800] = data
+	case addr >= 0xC000 && addr <= 0xDFFF:
+		m.ntRegs[(addr-0xC000)/0x0800] = data
+	case addr >= 0xE000 && addr <= 0xE7FF:
+		m.prgRegs[0] = data
+		m.audio.SetDisabled(data&0x40 != 0)
+		m.updateBanks()
+	case addr >= 0xE800 && addr <= 0xEFFF:
+		m.prgRegs[1] = data
+		m.updateBanks()
+	case addr >= 0xF000 && addr <= 0xF7FF:
+		m.prgRegs[2] = data
+		m.updateBanks()
+	case addr >= 0xF800:
+		m.ramAddr = data
+	default:
+		log.Printf("[WARN] mapper19: unhandled prg write at %04X", addr)
+	}
+}
+
+// chrCIRAM returns the nametable page mapped into the given pattern table bank,
+// or -1 if it is mapped to CHR-ROM. Values $E0 and above select the nametable
+// RAM, unless disabled for the pattern table by the bits 6-7 of $E800.
+func (m *Mapper19) chrCIRAM(bank int) int {
+	reg := m.chrRegs[bank]
+	disable := m.prgRegs[1] & (0x40 << (bank / 4))
+
+	if reg < 0xE0 || disable != 0 || m.ciram == nil {
+		return -1
+	}
+
+	return int(reg & 0x01)
+}
+
+func (m *Mapper19) ReadCHR(addr uint16) byte {
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+
+	if page := m.chrCIRAM(bank); page >= 0 {
+		return m.ciram[page][offset]
+	}
+
+	idx := int(m.chrRegs[bank])*0x0400 + offset
+	return m.rom.CHR[idx%len(m.rom.CHR)]
+}
+
+func (m *Mapper19) WriteCHR(addr uint16, data byte) {
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+
+	if page := m.chrCIRAM(bank); page >= 0 {
+		m.ciram[page][offset] = data
+		return
+	}
+
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper19: write to read-only chr at %04X", addr)
+		return
+	}
+
+	idx := int(m.chrRegs[bank])*0x0400 + offset
+	m.rom.CHR[idx%len(m.rom.CHR)] = data
+}
+
+func (m *Mapper19) readNametable(addr uint16) byte {
+	reg := m.ntRegs[(addr>>10)&0x03]
+	offset := int(addr & 0x03FF)
+
+	if reg >= 0xE0 {
+		return m.ciram[reg&0x01][offset]
+	}
+
+	return m.rom.CHR[(int(reg)*0x0400+offset)%len(m.rom.CHR)]
+}
+
+func (m *Mapper19) writeNametable(addr uint16, data byte) {
+	reg := m.ntRegs[(addr>>10)&0x03]
+
+	// CHR-ROM mapped into the nametables is read-only.
+	if reg >= 0xE0 {
+		m.ciram[reg&0x01][addr&0x03FF] = data
+	}
+}
+
+func (m *Mapper19) TickAudio() {
+	m.audio.Tick()
+}
+
+func (m *Mapper19) AudioOutput() float32 {
+	return m.audio.Output()
+}
+
+// BatteryRAM returns both the PRG-RAM and the internal RAM, since both are
+// backed by the battery, and some games only have the latter.
+func (m *Mapper19) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.battery
+}
+
+func (m *Mapper19) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		m.audio.SaveState(w),
+		w.WriteByteSlice(m.battery),
+		w.WriteByteSlice(m.prgRegs[:]),
+		w.WriteByteSlice(m.chrRegs[:]),
+		w.WriteByteSlice(m.ntRegs[:]),
+		w.WriteUint8(m.ramAddr),
+		w.WriteUint16(m.irqCounter),
+		w.WriteBool(m.irqEnable),
+		w.WriteBool(m.irqPending),
+	)
+}
+
+func (m *Mapper19) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		m.audio.LoadState(r),
+		r.ReadByteSliceTo(m.battery),
+		r.ReadByteSliceTo(m.prgRegs[:]),
+		r.ReadByteSliceTo(m.chrRegs[:]),
+		r.ReadByteSliceTo(m.ntRegs[:]),
+		r.ReadUint8To(&m.ramAddr),
+		r.ReadUint16To(&m.irqCounter),
+		r.ReadBoolTo(&m.irqEnable),
+		r.ReadBoolTo(&m.irqPending),
+	)
+
+	m.updateBanks()
+
+	return err
+}
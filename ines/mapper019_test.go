@@ -0,0 +1,104 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper19_InternalRAM(t *testing.T) {
+	m := NewMapper19(newTestROM(19, 256*1024, 256*1024))
+	m.Reset()
+
+	// Bit 7 of the address port enables the auto-increment, which wraps
+	// around the 128 bytes of RAM.
+	m.WritePRG(0xF800, 0x80|0x7E)
+	m.WritePRG(0x4800, 0x11)
+	m.WritePRG(0x4800, 0x22)
+	m.WritePRG(0x4800, 0x33)
+	testutil.Equal(t, m.intRAM[0x7E], 0x11)
+	testutil.Equal(t, m.intRAM[0x7F], 0x22)
+	testutil.Equal(t, m.intRAM[0x00], 0x33)
+
+	m.WritePRG(0xF800, 0x80|0x7F)
+	testutil.Equal(t, m.ReadPRG(0x4800), 0x22)
+	testutil.Equal(t, m.ReadPRG(0x4800), 0x33)
+
+	// Without it, the same byte is accessed every time.
+	m.WritePRG(0xF800, 0x7E)
+	testutil.Equal(t, m.ReadPRG(0x4800), 0x11)
+	testutil.Equal(t, m.ReadPRG(0x4800), 0x11)
+	m.WritePRG(0x4800, 0x44)
+	m.WritePRG(0x4800, 0x55)
+	testutil.Equal(t, m.intRAM[0x7E], 0x55)
+	testutil.Equal(t, m.intRAM[0x7F], 0x22)
+}
+
+func TestMapper19_IRQ(t *testing.T) {
+	m := NewMapper19(newTestROM(19, 256*1024, 256*1024))
+	m.Reset()
+
+	// The counter does not run until enabled.
+	m.WritePRG(0x5000, 0xFD)
+	m.WritePRG(0x5800, 0x7F)
+	m.TickCPU()
+	testutil.Equal(t, m.ReadPRG(0x5000), 0xFD)
+	testutil.Equal(t, m.ReadPRG(0x5800), 0x7F)
+
+	// The IRQ fires when the counter reaches $7FFF, where it stops.
+	m.WritePRG(0x5800, 0x80|0x7F)
+	m.TickCPU()
+	testutil.Equal(t, m.PendingIRQ(), false)
+	m.TickCPU()
+	testutil.Equal(t, m.PendingIRQ(), true)
+
+	m.TickCPU()
+	testutil.Equal(t, m.ReadPRG(0x5000), 0xFF)
+	testutil.Equal(t, m.ReadPRG(0x5800), 0x80|0x7F)
+
+	// Writing the counter acknowledges the IRQ.
+	m.WritePRG(0x5000, 0x00)
+	testutil.Equal(t, m.PendingIRQ(), false)
+}
+
+func TestMapper19_SaveLoadState(t *testing.T) {
+	rom := newTestROM(19, 256*1024, 256*1024)
+	rom.Battery = true
+
+	m := NewMapper19(rom)
+	m.Reset()
+
+	m.WritePRG(0xE000, 0x05)
+	m.WritePRG(0x8800, 0x21)
+	m.WritePRG(0xF800, 0x80|0x10)
+	m.WritePRG(0x4800, 0xAA)
+	m.WritePRG(0x4800, 0xBB)
+
+	m.WritePRG(0x5000, 0xF0)
+	m.WritePRG(0x5800, 0x80|0x7F)
+	for i := 0; i < 5; i++ {
+		m.TickCPU()
+	}
+
+	restored := NewMapper19(rom)
+	restored.Reset()
+	loadState(t, restored, saveState(t, m))
+
+	testutil.Equal(t, restored.ReadPRG(0x8000), 5)
+	testutil.Equal(t, restored.ReadCHR(0x0400), 0x21)
+	testutil.Equal(t, restored.intRAM[0x10], 0xAA)
+	testutil.Equal(t, restored.intRAM[0x11], 0xBB)
+	testutil.Equal(t, restored.BatteryRAM()[len(restored.sram)+0x11], 0xBB)
+
+	// The auto-increment continues where it stopped.
+	restored.WritePRG(0x4800, 0xCC)
+	testutil.Equal(t, restored.intRAM[0x12], 0xCC)
+
+	testutil.Equal(t, restored.ReadPRG(0x5000), 0xF5)
+	for i := 0; i < 9; i++ {
+		restored.TickCPU()
+	}
+	testutil.Equal(t, restored.PendingIRQ(), false)
+	restored.TickCPU()
+	testutil.Equal(t, restored.PendingIRQ(), true)
+}
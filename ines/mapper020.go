@@ -0,0 +1,457 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/apu"
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+const (
+	fdsByteCycles   = 150     // CPU cycles per byte, the drive runs at ~96 kbit/s
+	fdsRewindCycles = 50000   // delay before the head reaches the first byte
+	fdsInsertCycles = 1800000 // how long the disk stays out when switching sides
+)
+
+// Mapper20 implements the Famicom Disk System RAM adapter. It is not a real
+// mapper, but number 20 is reserved for it. The adapter has 32 KB of RAM for
+// the program loaded from the disk, 8 KB of CHR-RAM, the BIOS at $E000, a
+// timer IRQ, the disk drive interface and a wavetable sound channel.
+//
+// The disk sides are emulated as the raw stream of bytes passing under the
+// drive head, with all the gaps and block marks. Games may write to the disk,
+// so the stream is exposed as the battery RAM and persisted in the .sav file.
+// https://www.nesdev.org/wiki/Family_Computer_Disk_System
+type Mapper20 struct {
+	rom   *ROM
+	ram   []byte
+	disk  []byte   // all sides in the raw form, one after another
+	sides [][]byte // views into disk
+	audio apu.FDSAudio
+
+	side        uint8
+	inserted    bool
+	insertDelay uint32
+
+	diskIO     bool // $4023 bit 0
+	soundIO    bool // $4023 bit 1
+	irqReload  uint16
+	irqCounter uint16
+	irqEnable  bool
+	irqRepeat  bool
+	timerIRQ   bool
+	diskIRQ    bool
+
+	control     byte // $4025
+	motorOn     bool
+	scanning    bool
+	endOfHead   bool
+	gapEnded    bool
+	transferred bool
+	crcControl  bool // previous state of the $4025 CRC bit
+	crc         uint16
+	writeData   byte
+	readData    byte
+	position    uint32
+	delay       uint32
+}
+
+func NewMapper20(rom *ROM) *Mapper20 {
+	var (
+		raw  = make([][]byte, len(rom.Disk))
+		size int
+	)
+
+	for i, side := range rom.Disk {
+		raw[i] = fdsRawSide(side)
+		size += len(raw[i])
+	}
+
+	m := &Mapper20{
+		rom:   rom,
+		ram:   rom.newPRGRAM(),
+		disk:  make([]byte, 0, size),
+		sides: make([][]byte, len(raw)),
+	}
+
+	for i, side := range raw {
+		start := len(m.disk)
+		m.disk = append(m.disk, side...)
+		m.sides[i] = m.disk[start:len(m.disk):len(m.disk)]
+	}
+
+	// Games boot from the first side.
+	m.inserted = len(m.sides) > 0
+
+	return m
+}
+
+func (m *Mapper20) Reset() {
+	m.diskIO = true
+	m.soundIO = true
+	m.irqReload = 0
+	m.irqCounter = 0
+	m.irqEnable = false
+	m.irqRepeat = false
+	m.timerIRQ = false
+	m.diskIRQ = false
+	m.control = 0
+	m.motorOn = false
+	m.scanning = false
+	m.endOfHead = true
+	m.gapEnded = false
+	m.transferred = false
+	m.crcControl = false
+	m.crc = 0
+	m.writeData = 0
+	m.readData = 0
+	m.position = 0
+	m.delay = 0
+
+	m.audio.Reset()
+}
+
+// SwitchSide ejects the disk and inserts the next side after a short delay,
+// so that the BIOS notices the change. After the last side, it goes back to
+// the first one.
+func (m *Mapper20) SwitchSide() {
+	if len(m.sides) == 0 {
+		return
+	}
+
+	m.side = uint8((int(m.side) + 1) % len(m.sides))
+	m.inserted = false
+	m.insertDelay = fdsInsertCycles
+
+	log.Printf("[INFO] fds: disk ejected, inserting side %d of %d", m.side+1, len(m.sides))
+}
+
+func (m *Mapper20) ScanlineTick() {}
+
+func (m *Mapper20) TickCPU() {
+	m.tickTimer()
+	m.tickDrive()
+}
+
+func (m *Mapper20) tickTimer() {
+	if !m.irqEnable || !m.diskIO {
+		return
+	}
+
+	if m.irqCounter > 0 {
+		m.irqCounter--
+		return
+	}
+
+	m.timerIRQ = true
+	m.irqCounter = m.irqReload
+
+	if !m.irqRepeat {
+		m.irqEnable = false
+	}
+}
+
+// tickDrive moves the disk under the drive head, transferring one byte every
+// fdsByteCycles cycles while the motor is running.
+func (m *Mapper20) tickDrive() {
+	// The head goes back to the start while the disk is out, so that the new
+	// side is read from the beginning.
+	if m.insertDelay > 0 {
+		if m.insertDelay--; m.insertDelay == 0 {
+			m.inserted = true
+		}
+	}
+
+	if !m.inserted || !m.motorOn {
+		m.endOfHead = true
+		m.scanning = false
+		return
+	}
+
+	if m.control&0x02 != 0 && !m.scanning {
+		return // transfer reset
+	}
+
+	if m.endOfHead {
+		m.delay = fdsRewindCycles
+		m.endOfHead = false
+		m.position = 0
+		m.gapEnded = false
+		return
+	}
+
+	if m.delay > 0 {
+		m.delay--
+		return
+	}
+
+	m.scanning = true
+	m.transferByte()
+
+	side := m.sides[m.side]
+	if m.position++; int(m.position) >= len(side) {
+		m.motorOn = false
+		m.endOfHead = true
+	} else {
+		m.delay = fdsByteCycles
+	}
+}
+
+func (m *Mapper20) transferByte() {
+	var (
+		side       = m.sides[m.side]
+		irqEnable  = m.control&0x80 != 0
+		ready      = m.control&0x40 != 0
+		crcControl = m.control&0x10 != 0
+		readMode   = m.control&0x04 != 0
+	)
+
+	defer func() {
+		m.crcControl = crcControl
+	}()
+
+	if readMode {
+		data := side[m.position]
+
+		if !m.crcControl {
+			m.crc = fdsCRC(m.crc, data)
+		}
+
+		if !ready {
+			m.gapEnded = false
+			m.crc = 0
+		} else if data != 0 && !m.gapEnded {
+			// The block start mark ends the gap, but does not raise an IRQ.
+			m.gapEnded = true
+			irqEnable = false
+		}
+
+		if m.gapEnded {
+			m.transferred = true
+			m.readData = data
+			m.diskIRQ = m.diskIRQ || irqEnable
+		}
+
+		return
+	}
+
+	var data byte
+
+	if !crcControl {
+		m.transferred = true
+		m.diskIRQ = m.diskIRQ || irqEnable
+		data = m.writeData
+	}
+
+	if !ready {
+		data = 0x00
+	}
+
+	if !crcControl {
+		m.crc = fdsCRC(m.crc, data)
+	} else {
+		if !m.crcControl {
+			m.crc = fdsCRC(fdsCRC(m.crc, 0), 0)
+		}
+
+		data = byte(m.crc)
+		m.crc >>= 8
+	}
+
+	side[m.position] = data
+	m.gapEnded = false
+}
+
+func (m *Mapper20) PendingIRQ() bool {
+	return m.timerIRQ || m.diskIRQ
+}
+
+func (m *Mapper20) MirrorMode() MirrorMode {
+	if m.control&0x08 != 0 {
+		return MirrorHorizontal
+	}
+
+	return MirrorVertical
+}
+
+func (m *Mapper20) ReadPRG(addr uint16) byte {
+	switch {
+	case addr == 0x4030 && m.diskIO:
+		var status byte
+		if m.timerIRQ {
+			status |= 0x01
+		}
+		if m.transferred {
+			status |= 0x02
+		}
+		if m.endOfHead {
+			status |= 0x40
+		}
+
+		m.transferred = false
+		m.timerIRQ = false
+		m.diskIRQ = false
+
+		return status
+	case addr == 0x4031 && m.diskIO:
+		m.transferred = false
+		m.diskIRQ = false
+		return m.readData
+	case addr == 0x4032 && m.diskIO:
+		var status byte = 0x40
+		if !m.inserted {
+			status |= 0x05 // no disk, not writable
+		}
+		if !m.inserted || !m.scanning {
+			status |= 0x02 // not ready
+		}
+		return status
+	case addr == 0x4033 && m.diskIO:
+		return 0x80 // battery is good
+	case addr >= 0x4040 && addr <= 0x4092:
+		if m.soundIO {
+			return m.audio.Read(addr)
+		}
+		return 0
+	case addr >= 0x6000 && addr <= 0xDFFF:
+		return m.ram[addr-0x6000]
+	case addr >= 0xE000:
+		return m.rom.PRG[addr-0xE000]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper20) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr == 0x4023:
+		m.diskIO = data&0x01 != 0
+		m.soundIO = data&0x02 != 0
+
+		if !m.diskIO {
+			m.irqEnable = false
+			m.timerIRQ = false
+			m.diskIRQ = false
+		}
+	case addr >= 0x4020 && addr <= 0x4026 && !m.diskIO:
+		// Disk registers are disabled.
+	case addr == 0x4020:
+		m.irqReload = m.irqReload&0xFF00 | uint16(data)
+	case addr == 0x4021:
+		m.irqReload = m.irqReload&0x00FF | uint16(data)<<8
+	case addr == 0x4022:
+		m.irqRepeat = data&0x01 != 0
+		m.irqEnable = data&0x02 != 0
+
+		if m.irqEnable {
+			m.irqCounter = m.irqReload
+		} else {
+			m.timerIRQ = false
+		}
+	case addr == 0x4024:
+		m.writeData = data
+		m.transferred = false
+		m.diskIRQ = false
+	case addr == 0x4025:
+		m.control = data
+		m.motorOn = data&0x01 != 0
+		m.diskIRQ = false
+	case addr == 0x4026:
+		// External connector output, not used.
+	case addr >= 0x4040 && addr <= 0x408A:
+		if m.soundIO {
+			m.audio.Write(addr, data)
+		}
+	case addr >= 0x6000 && addr <= 0xDFFF:
+		m.ram[addr-0x6000] = data
+	case addr >= 0xE000:
+		log.Printf("[WARN] mapper20: write to bios at %04X", addr)
+	}
+}
+
+func (m *Mapper20) ReadCHR(addr uint16) byte {
+	return m.rom.CHR[addr]
+}
+
+func (m *Mapper20) WriteCHR(addr uint16, data byte) {
+	m.rom.CHR[addr] = data
+}
+
+func (m *Mapper20) TickAudio() {
+	m.audio.Tick()
+}
+
+func (m *Mapper20) AudioOutput() float32 {
+	return m.audio.Output()
+}
+
+// BatteryRAM returns the raw disk contents, so that the changes made by the
+// game are persisted in the .sav file rather than in the original image.
+func (m *Mapper20) BatteryRAM() []byte {
+	return m.disk
+}
+
+func (m *Mapper20) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		m.audio.SaveState(w),
+		w.WriteByteSlice(m.ram),
+		w.WriteByteSlice(m.disk),
+		w.WriteUint8(m.side),
+		w.WriteBool(m.inserted),
+		w.WriteUint32(m.insertDelay),
+		w.WriteBool(m.diskIO),
+		w.WriteBool(m.soundIO),
+		w.WriteUint16(m.irqReload),
+		w.WriteUint16(m.irqCounter),
+		w.WriteBool(m.irqEnable),
+		w.WriteBool(m.irqRepeat),
+		w.WriteBool(m.timerIRQ),
+		w.WriteBool(m.diskIRQ),
+		w.WriteUint8(m.control),
+		w.WriteBool(m.motorOn),
+		w.WriteBool(m.scanning),
+		w.WriteBool(m.endOfHead),
+		w.WriteBool(m.gapEnded),
+		w.WriteBool(m.transferred),
+		w.WriteBool(m.crcControl),
+		w.WriteUint16(m.crc),
+		w.WriteUint8(m.writeData),
+		w.WriteUint8(m.readData),
+		w.WriteUint32(m.position),
+		w.WriteUint32(m.delay),
+	)
+}
+
+func (m *Mapper20) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		m.rom.LoadState(r),
+		m.audio.LoadState(r),
+		r.ReadByteSliceTo(m.ram),
+		r.ReadByteSliceTo(m.disk),
+		r.ReadUint8To(&m.side),
+		r.ReadBoolTo(&m.inserted),
+		r.ReadUint32To(&m.insertDelay),
+		r.ReadBoolTo(&m.diskIO),
+		r.ReadBoolTo(&m.soundIO),
+		r.ReadUint16To(&m.irqReload),
+		r.ReadUint16To(&m.irqCounter),
+		r.ReadBoolTo(&m.irqEnable),
+		r.ReadBoolTo(&m.irqRepeat),
+		r.ReadBoolTo(&m.timerIRQ),
+		r.ReadBoolTo(&m.diskIRQ),
+		r.ReadUint8To(&m.control),
+		r.ReadBoolTo(&m.motorOn),
+		r.ReadBoolTo(&m.scanning),
+		r.ReadBoolTo(&m.endOfHead),
+		r.ReadBoolTo(&m.gapEnded),
+		r.ReadBoolTo(&m.transferred),
+		r.ReadBoolTo(&m.crcControl),
+		r.ReadUint16To(&m.crc),
+		r.ReadUint8To(&m.writeData),
+		r.ReadUint8To(&m.readData),
+		r.ReadUint32To(&m.position),
+		r.ReadUint32To(&m.delay),
+	)
+}
@@ -0,0 +1,177 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+// newTestDisk returns a disk image with the given number of sides. Each side
+// only has the disk info block, with the side number at its usual place, and
+// an empty file list.
+func newTestDisk(t *testing.T, numSides int) *ROM {
+	image := make([]byte, numSides*fdsSideSize)
+
+	for i := 0; i < numSides; i++ {
+		side := image[i*fdsSideSize:]
+		side[0] = 0x01
+		copy(side[1:], "*NINTENDO-HVC*")
+		side[0x16] = byte(i)
+		side[56] = 0x02
+	}
+
+	rom, err := NewFromFDSBuffer(image, make([]byte, fdsBIOSSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rom
+}
+
+// readDiskByte runs the drive until the disk IRQ, and returns the transferred
+// byte the same way the BIOS does. The block start mark does not raise the IRQ,
+// so it is skipped.
+func readDiskByte(t *testing.T, m *Mapper20) byte {
+	for i := 0; !m.PendingIRQ(); i++ {
+		if i > fdsRewindCycles+fdsLeadInGap*fdsByteCycles*2 {
+			t.Fatal("no data from the disk")
+		}
+
+		m.TickCPU()
+	}
+
+	return m.ReadPRG(0x4031)
+}
+
+func TestMapper20_TimerIRQ(t *testing.T) {
+	m := NewMapper20(newTestDisk(t, 1))
+	m.Reset()
+
+	m.WritePRG(0x4020, 0x02)
+	m.WritePRG(0x4021, 0x00)
+	m.WritePRG(0x4022, 0x02)
+
+	// The IRQ fires on the cycle after the counter reaches zero.
+	m.TickCPU()
+	m.TickCPU()
+	testutil.Equal(t, m.PendingIRQ(), false)
+	m.TickCPU()
+	testutil.Equal(t, m.PendingIRQ(), true)
+
+	// Reading the status acknowledges it.
+	testutil.Equal(t, m.ReadPRG(0x4030)&0x01, 0x01)
+	testutil.Equal(t, m.PendingIRQ(), false)
+
+	// Without the repeat flag, the timer stops after the first IRQ.
+	for i := 0; i < 10; i++ {
+		m.TickCPU()
+	}
+	testutil.Equal(t, m.PendingIRQ(), false)
+
+	// With it, the counter is reloaded and keeps firing.
+	m.WritePRG(0x4022, 0x03)
+	for n := 0; n < 2; n++ {
+		for i := 0; i < 2; i++ {
+			m.TickCPU()
+		}
+		testutil.Equal(t, m.PendingIRQ(), false)
+		m.TickCPU()
+		testutil.Equal(t, m.PendingIRQ(), true)
+		m.ReadPRG(0x4030)
+	}
+
+	// Disabling the disk registers stops the timer.
+	m.WritePRG(0x4023, 0x00)
+	for i := 0; i < 10; i++ {
+		m.TickCPU()
+	}
+	testutil.Equal(t, m.PendingIRQ(), false)
+}
+
+func TestMapper20_SwitchSide(t *testing.T) {
+	m := NewMapper20(newTestDisk(t, 2))
+	m.Reset()
+
+	readSide := func() byte {
+		m.WritePRG(0x4025, 0xC5) // motor on, read mode, ready, IRQ
+
+		var info [0x17]byte
+		for i := range info {
+			info[i] = readDiskByte(t, m)
+		}
+
+		testutil.Equal(t, info[0], 0x01)
+		testutil.Equal(t, string(info[1:15]), "*NINTENDO-HVC*")
+		m.WritePRG(0x4025, 0x00)
+
+		return info[0x16]
+	}
+
+	testutil.Equal(t, m.ReadPRG(0x4032)&0x01, 0x00)
+	testutil.Equal(t, readSide(), 0)
+
+	// The disk stays out for a while, so that the BIOS notices the change.
+	m.SwitchSide()
+	testutil.Equal(t, m.ReadPRG(0x4032)&0x01, 0x01)
+
+	for i := 0; i < fdsInsertCycles; i++ {
+		m.TickCPU()
+	}
+
+	testutil.Equal(t, m.ReadPRG(0x4032)&0x01, 0x00)
+	testutil.Equal(t, readSide(), 1)
+
+	// After the last side, it goes back to the first one.
+	m.SwitchSide()
+	for i := 0; i < fdsInsertCycles; i++ {
+		m.TickCPU()
+	}
+
+	testutil.Equal(t, readSide(), 0)
+}
+
+func TestMapper20_SaveLoadState(t *testing.T) {
+	rom := newTestDisk(t, 2)
+
+	m := NewMapper20(rom)
+	m.Reset()
+
+	m.WritePRG(0x6000, 0xAA)
+	m.WritePRG(0xDFFF, 0xBB)
+	m.WritePRG(0x4025, 0x08)
+	m.disk[0] = 0xCC // written by the game
+
+	m.WritePRG(0x4020, 0x10)
+	m.WritePRG(0x4021, 0x00)
+	m.WritePRG(0x4022, 0x02)
+	m.SwitchSide()
+
+	for i := 0; i < 6; i++ {
+		m.TickCPU()
+	}
+
+	restored := NewMapper20(rom)
+	restored.Reset()
+	loadState(t, restored, saveState(t, m))
+
+	testutil.Equal(t, restored.ReadPRG(0x6000), 0xAA)
+	testutil.Equal(t, restored.ReadPRG(0xDFFF), 0xBB)
+	testutil.Equal(t, restored.MirrorMode(), MirrorHorizontal)
+	testutil.Equal(t, restored.BatteryRAM()[0], 0xCC)
+
+	// The disk is still out, and the timer continues where it stopped.
+	testutil.Equal(t, restored.side, 1)
+	testutil.Equal(t, restored.ReadPRG(0x4032)&0x01, 0x01)
+
+	for i := 0; i < 10; i++ {
+		restored.TickCPU()
+	}
+	testutil.Equal(t, restored.PendingIRQ(), false)
+	restored.TickCPU()
+	testutil.Equal(t, restored.PendingIRQ(), true)
+
+	for i := 0; i < fdsInsertCycles-17; i++ {
+		restored.TickCPU()
+	}
+	testutil.Equal(t, restored.ReadPRG(0x4032)&0x01, 0x00)
+}
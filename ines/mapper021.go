@@ -0,0 +1,308 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// vrc24Variant describes how a VRC2 or VRC4 chip is wired on the board. The
+// chips only differ in a few features, but the boards connect different CPU
+// address lines to the chip's A0 and A1 pins, which select the register within
+// each group of four.
+type vrc24Variant struct {
+	vrc2 bool   // no IRQ and PRG swap mode, but has the microwire latch
+	a0   uint16 // CPU address lines wired to the chip's A0
+	a1   uint16 // CPU address lines wired to the chip's A1
+	chr1 bool   // VRC2a ignores the low bit of the CHR banks
+}
+
+// vrc24VariantOf returns the wiring of the board from its mapper number and
+// NES 2.0 submapper. When the submapper is unknown, the lines of both possible
+// variants are accepted, since the games only write to one set of addresses.
+func vrc24VariantOf(rom *ROM) vrc24Variant {
+	switch rom.MapperID {
+	case 21:
+		switch rom.Submapper {
+		case 1: // VRC4a
+			return vrc24Variant{a0: 0x02, a1: 0x04}
+		case 2: // VRC4c
+			return vrc24Variant{a0: 0x40, a1: 0x80}
+		default:
+			return vrc24Variant{a0: 0x42, a1: 0x84}
+		}
+	case 22: // VRC2a
+		return vrc24Variant{vrc2: true, a0: 0x02, a1: 0x01, chr1: true}
+	case 23:
+		switch rom.Submapper {
+		case 1: // VRC4f
+			return vrc24Variant{a0: 0x01, a1: 0x02}
+		case 2: // VRC4e
+			return vrc24Variant{a0: 0x04, a1: 0x08}
+		case 3: // VRC2b
+			return vrc24Variant{vrc2: true, a0: 0x01, a1: 0x02}
+		default:
+			return vrc24Variant{a0: 0x05, a1: 0x0A}
+		}
+	default: // 25
+		switch rom.Submapper {
+		case 1: // VRC4b
+			return vrc24Variant{a0: 0x02, a1: 0x01}
+		case 2: // VRC4d
+			return vrc24Variant{a0: 0x08, a1: 0x04}
+		case 3: // VRC2c
+			return vrc24Variant{vrc2: true, a0: 0x02, a1: 0x01}
+		default:
+			return vrc24Variant{a0: 0x0A, a1: 0x05}
+		}
+	}
+}
+
+// Mapper21 implements the Konami VRC2 and VRC4 mappers (iNES mappers #21, #22,
+// #23 and #25). There are two switchable 8 KB PRG banks and eight 1 KB CHR
+// banks. VRC4 can also swap the PRG banks at $8000 and $C000, and has the same
+// IRQ counter as VRC6 and VRC7. VRC2 has a one-bit latch instead, which games
+// use to talk to an EEPROM on other boards, or as a copy protection.
+// https://www.nesdev.org/wiki/VRC2_and_VRC4
+type Mapper21 struct {
+	rom     *ROM
+	sram    []byte
+	variant vrc24Variant
+	irq     vrcIRQ
+
+	prgRegs [2]byte // $8000, $A000
+	chrLow  [8]byte // $B000-$E003, even registers
+	chrHigh [8]byte // $B000-$E003, odd registers
+	mirror  byte    // $9000
+	swap    bool    // $9002
+	latch   byte    // $6000 (VRC2 only)
+	prgBank [4]int
+	chrBank [8]int
+}
+
+func NewMapper21(rom *ROM) *Mapper21 {
+	return &Mapper21{
+		rom:     rom,
+		sram:    rom.newPRGRAM(),
+		variant: vrc24VariantOf(rom),
+	}
+}
+
+func (m *Mapper21) Reset() {
+	m.prgRegs = [2]byte{}
+	m.chrLow = [8]byte{}
+	m.chrHigh = [8]byte{}
+	m.mirror = 0
+	m.swap = false
+	m.latch = 0
+
+	m.irq.reset()
+	m.updateBanks()
+}
+
+func (m *Mapper21) updateBanks() {
+	numPRG := len(m.rom.PRG) / 0x2000
+
+	var (
+		first  = int(m.prgRegs[0] & 0x1F)
+		second = int(m.prgRegs[1] & 0x1F)
+		fixed  = numPRG - 2
+	)
+
+	if m.swap {
+		first, fixed = fixed, first
+	}
+
+	banks := [4]int{first, second, fixed, numPRG - 1}
+	for i, b := range banks {
+		m.prgBank[i] = b % numPRG * 0x2000
+	}
+
+	for i := range m.chrBank {
+		bank := int(m.chrHigh[i]&0x1F)<<4 | int(m.chrLow[i]&0x0F)
+		if m.variant.chr1 {
+			bank >>= 1
+		}
+
+		m.chrBank[i] = bank * 0x0400 % len(m.rom.CHR)
+	}
+}
+
+func (m *Mapper21) ScanlineTick() {}
+
+func (m *Mapper21) TickCPU() {
+	m.irq.tick()
+}
+
+func (m *Mapper21) PendingIRQ() bool {
+	return m.irq.pending
+}
+
+func (m *Mapper21) MirrorMode() MirrorMode {
+	mode := m.mirror & 0x03
+	if m.variant.vrc2 {
+		mode &= 0x01
+	}
+
+	switch mode {
+	case 0:
+		return MirrorVertical
+	case 1:
+		return MirrorHorizontal
+	case 2:
+		return MirrorSingle0
+	default:
+		return MirrorSingle1
+	}
+}
+
+// register translates the CPU address into the chip's register address, which
+// is $x000-$x003 with the A0 and A1 pins in the lowest two bits.
+func (m *Mapper21) register(addr uint16) uint16 {
+	reg := addr & 0xF000
+
+	if addr&m.variant.a0 != 0 {
+		reg |= 0x01
+	}
+
+	if addr&m.variant.a1 != 0 {
+		reg |= 0x02
+	}
+
+	return reg
+}
+
+func (m *Mapper21) writeRegister(addr uint16, data byte) {
+	reg := m.register(addr)
+
+	switch {
+	case reg >= 0x8000 && reg <= 0x8003:
+		m.prgRegs[0] = data
+		m.updateBanks()
+	case reg >= 0x9000 && reg <= 0x9003:
+		// VRC2 only has the mirroring register, mirrored four times.
+		if m.variant.vrc2 || reg <= 0x9001 {
+			m.mirror = data
+		} else {
+			m.swap = data&0x02 != 0
+			m.updateBanks()
+		}
+	case reg >= 0xA000 && reg <= 0xA003:
+		m.prgRegs[1] = data
+		m.updateBanks()
+	case reg >= 0xB000 && reg <= 0xE003:
+		idx := (reg-0xB000)>>12*2 + reg&0x02>>1
+		if reg&0x01 == 0 {
+			m.chrLow[idx] = data
+		} else {
+			m.chrHigh[idx] = data
+		}
+		m.updateBanks()
+	case m.variant.vrc2:
+		// There is no IRQ counter on VRC2.
+	case reg == 0xF000:
+		m.irq.latch = m.irq.latch&0xF0 | data&0x0F
+	case reg == 0xF001:
+		m.irq.latch = m.irq.latch&0x0F | data<<4
+	case reg == 0xF002:
+		m.irq.writeControl(data)
+	case reg == 0xF003:
+		m.irq.acknowledge()
+	}
+}
+
+func (m *Mapper21) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) > 0 {
+			return m.sram[int(addr-0x6000)%len(m.sram)]
+		}
+
+		// Without PRG-RAM, VRC2 exposes its latch at $6000-$6FFF and
+		// the rest of the bits are open bus.
+		if m.variant.vrc2 && addr <= 0x6FFF {
+			return byte(addr>>8)&0xFE | m.latch&0x01
+		}
+
+		return byte(addr >> 8) // open bus
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x2000
+		offset := int(addr % 0x2000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper21) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		} else if m.variant.vrc2 && addr <= 0x6FFF {
+			m.latch = data & 0x01
+		}
+	case addr >= 0x8000:
+		m.writeRegister(addr, data)
+	default:
+		log.Printf("[WARN] mapper21: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper21) ReadCHR(addr uint16) byte {
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	return m.rom.CHR[m.chrBank[bank]+offset]
+}
+
+func (m *Mapper21) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper21: write to read-only chr at %04X", addr)
+		return
+	}
+
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	m.rom.CHR[m.chrBank[bank]+offset] = data
+}
+
+func (m *Mapper21) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper21) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		m.irq.saveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteByteSlice(m.prgRegs[:]),
+		w.WriteByteSlice(m.chrLow[:]),
+		w.WriteByteSlice(m.chrHigh[:]),
+		w.WriteUint8(m.mirror),
+		w.WriteBool(m.swap),
+		w.WriteUint8(m.latch),
+	)
+}
+
+func (m *Mapper21) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		m.irq.loadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadByteSliceTo(m.prgRegs[:]),
+		r.ReadByteSliceTo(m.chrLow[:]),
+		r.ReadByteSliceTo(m.chrHigh[:]),
+		r.ReadUint8To(&m.mirror),
+		r.ReadBoolTo(&m.swap),
+		r.ReadUint8To(&m.latch),
+	)
+
+	m.updateBanks()
+
+	return err
+}
@@ -0,0 +1,107 @@
+package ines
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper21_Wiring(t *testing.T) {
+	tests := map[string]struct {
+		mapperID  uint16
+		submapper uint8
+		a0, a1    uint16
+		chr       byte // 1 KB bank at $0400 after writing $23 to the CHR bank 1
+	}{
+		"VRC4a":    {mapperID: 21, submapper: 1, a0: 0x02, a1: 0x04, chr: 0x23},
+		"VRC4c":    {mapperID: 21, submapper: 2, a0: 0x40, a1: 0x80, chr: 0x23},
+		"21 (any)": {mapperID: 21, a0: 0x40, a1: 0x80, chr: 0x23},
+		"VRC2a":    {mapperID: 22, a0: 0x02, a1: 0x01, chr: 0x11},
+		"VRC4f":    {mapperID: 23, submapper: 1, a0: 0x01, a1: 0x02, chr: 0x23},
+		"VRC4e":    {mapperID: 23, submapper: 2, a0: 0x04, a1: 0x08, chr: 0x23},
+		"VRC2b":    {mapperID: 23, submapper: 3, a0: 0x01, a1: 0x02, chr: 0x23},
+		"VRC4b":    {mapperID: 25, submapper: 1, a0: 0x02, a1: 0x01, chr: 0x23},
+		"VRC4d":    {mapperID: 25, submapper: 2, a0: 0x08, a1: 0x04, chr: 0x23},
+		"VRC2c":    {mapperID: 25, submapper: 3, a0: 0x02, a1: 0x01, chr: 0x23},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rom := newTestROM(tt.mapperID, 256*1024, 256*1024)
+			rom.Submapper = tt.submapper
+
+			m := NewMapper21(rom)
+			m.Reset()
+
+			// $B002 and $B003 on the chip: low and high bits of CHR bank 1.
+			m.WritePRG(0xB000|tt.a1, 0x03)
+			m.WritePRG(0xB000|tt.a1|tt.a0, 0x02)
+			testutil.Equal(t, m.ReadCHR(0x0000), 0)
+			testutil.Equal(t, m.ReadCHR(0x0400), tt.chr)
+
+			m.WritePRG(0x8000, 0x05)
+			m.WritePRG(0xA000, 0x07)
+			testutil.Equal(t, m.ReadPRG(0x8000), 5)
+			testutil.Equal(t, m.ReadPRG(0xA000), 7)
+			testutil.Equal(t, m.ReadPRG(0xC000), 30)
+			testutil.Equal(t, m.ReadPRG(0xE000), 31)
+		})
+	}
+}
+
+func TestMapper21_PRGSwap(t *testing.T) {
+	rom := newTestROM(21, 256*1024, 256*1024)
+	rom.Submapper = 1
+
+	m := NewMapper21(rom)
+	m.Reset()
+
+	m.WritePRG(0x8000, 0x05)
+	m.WritePRG(0x9004, 0x02) // $9002 on VRC4a
+	testutil.Equal(t, m.ReadPRG(0x8000), 30)
+	testutil.Equal(t, m.ReadPRG(0xC000), 5)
+	testutil.Equal(t, m.ReadPRG(0xE000), 31)
+
+	// VRC2 has no swap mode, the register is a mirror of the mirroring one.
+	rom = newTestROM(22, 256*1024, 256*1024)
+
+	m = NewMapper21(rom)
+	m.Reset()
+
+	m.WritePRG(0x8000, 0x05)
+	m.WritePRG(0x9001, 0x03)
+	testutil.Equal(t, m.ReadPRG(0x8000), 5)
+	testutil.Equal(t, m.MirrorMode(), MirrorHorizontal)
+}
+
+func TestMapper21_GameDBWiring(t *testing.T) {
+	// Both boards are mapper 25 in the iNES header, but wired differently,
+	// which only the database knows (see cmd/dendy-gamedb).
+	db, err := parseGameDB(strings.Join([]string{
+		"0000000D\t25.2\tKONAMI-VRC-4\t-\t2\t0\tntsc\tstd\tVRC4d Game",
+		"0000002C\t25.3\tKONAMI-VRC-2\t-\t0\t0\tntsc\tstd\tVRC2c Game",
+	}, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[uint32]vrc24Variant{
+		0x0000000D: {a0: 0x08, a1: 0x04},
+		0x0000002C: {vrc2: true, a0: 0x02, a1: 0x01},
+	}
+
+	for crc, want := range tests {
+		rom := newTestROM(25, 256*1024, 256*1024)
+		rom.CRC32 = crc
+		rom.applyGameInfo(db[rom.CRC32])
+
+		m := NewMapper21(rom)
+		m.Reset()
+		testutil.Equal(t, m.variant, want)
+
+		m.WritePRG(0xB000|want.a1, 0x03)
+		m.WritePRG(0xB000|want.a1|want.a0, 0x02)
+		testutil.Equal(t, m.ReadCHR(0x0400), 0x23)
+	}
+}
@@ -0,0 +1,237 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/apu"
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper24 implements the Konami VRC6 mapper (iNES mappers #24 and #26). The
+// two variants only differ in how the A0 and A1 lines are wired to the chip.
+// https://www.nesdev.org/wiki/VRC6
+type Mapper24 struct {
+	rom     *ROM
+	sram    []byte
+	swapA01 bool // VRC6b (mapper 26)
+	audio   apu.VRC6Audio
+	irq     vrcIRQ
+
+	prgReg16  byte    // $8000
+	prgReg8   byte    // $C000
+	chrRegs   [8]byte // $D000-$E003
+	control   byte    // $B003
+	prgBank   [4]int
+	chrBank   [8]int
+	ramEnable bool
+}
+
+func NewMapper24(rom *ROM, swapA01 bool) *Mapper24 {
+	return &Mapper24{
+		rom:     rom,
+		sram:    rom.newPRGRAM(),
+		swapA01: swapA01,
+	}
+}
+
+func (m *Mapper24) Reset() {
+	m.prgReg16 = 0
+	m.prgReg8 = 0
+	m.chrRegs = [8]byte{}
+	m.control = 0
+	m.ramEnable = false
+
+	m.irq.reset()
+	m.audio.Reset()
+	m.updateBanks()
+}
+
+func (m *Mapper24) prgOffset(idx int) int {
+	if idx < 0 {
+		idx += len(m.rom.PRG) / 0x2000
+	}
+
+	return idx * 0x2000 % len(m.rom.PRG)
+}
+
+func (m *Mapper24) chrOffset(idx int) int {
+	return idx * 0x0400 % len(m.rom.CHR)
+}
+
+func (m *Mapper24) updateBanks() {
+	m.prgBank[0] = m.prgOffset(int(m.prgReg16&0x0F) * 2)
+	m.prgBank[1] = m.prgOffset(int(m.prgReg16&0x0F)*2 + 1)
+	m.prgBank[2] = m.prgOffset(int(m.prgReg8 & 0x1F))
+	m.prgBank[3] = m.prgOffset(-1)
+
+	regs := &m.chrRegs
+
+	switch m.control & 0x03 {
+	case 0: // Eight 1 KB banks.
+		for i := range m.chrBank {
+			m.chrBank[i] = m.chrOffset(int(regs[i]))
+		}
+	case 1: // Four 2 KB banks, the low bit comes from PPU A10.
+		for i := range m.chrBank {
+			m.chrBank[i] = m.chrOffset(int(regs[i/2]&0xFE) | i&1)
+		}
+	default: // Four 1 KB banks and two 2 KB banks.
+		for i := 0; i < 4; i++ {
+			m.chrBank[i] = m.chrOffset(int(regs[i]))
+		}
+		for i := 4; i < 8; i++ {
+			m.chrBank[i] = m.chrOffset(int(regs[4+(i-4)/2]&0xFE) | i&1)
+		}
+	}
+}
+
+func (m *Mapper24) ScanlineTick() {}
+
+func (m *Mapper24) TickCPU() {
+	m.irq.tick()
+}
+
+func (m *Mapper24) PendingIRQ() bool {
+	return m.irq.pending
+}
+
+func (m *Mapper24) MirrorMode() MirrorMode {
+	switch (m.control >> 2) & 0x03 {
+	case 0:
+		return MirrorVertical
+	case 1:
+		return MirrorHorizontal
+	case 2:
+		return MirrorSingle0
+	default:
+		return MirrorSingle1
+	}
+}
+
+func (m *Mapper24) writeRegister(addr uint16, data byte) {
+	addr &= 0xF003
+	if m.swapA01 {
+		addr = addr&0xF000 | (addr&0x01)<<1 | (addr&0x02)>>1
+	}
+
+	switch {
+	case addr >= 0x8000 && addr <= 0x8003:
+		m.prgReg16 = data
+		m.updateBanks()
+	case addr >= 0x9000 && addr <= 0xB002:
+		m.audio.Write(addr, data)
+	case addr == 0xB003:
+		m.control = data
+		m.ramEnable = data&0x80 != 0
+		m.updateBanks()
+	case addr >= 0xC000 && addr <= 0xC003:
+		m.prgReg8 = data
+		m.updateBanks()
+	case addr >= 0xD000 && addr <= 0xE003:
+		idx := (addr-0xD000)>>12*4 + addr&0x03
+		m.chrRegs[idx] = data
+		m.updateBanks()
+	case addr == 0xF000:
+		m.irq.latch = data
+	case addr == 0xF001:
+		m.irq.writeControl(data)
+	case addr == 0xF002:
+		m.irq.acknowledge()
+	}
+}
+
+func (m *Mapper24) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if !m.ramEnable || len(m.sram) == 0 {
+			return 0
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x2000
+		offset := int(addr % 0x2000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		log.Printf("[WARN] mapper24: unhandled prg read at %04X", addr)
+		return 0
+	}
+}
+
+func (m *Mapper24) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if m.ramEnable && len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
+	case addr >= 0x8000:
+		m.writeRegister(addr, data)
+	default:
+		log.Printf("[WARN] mapper24: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper24) ReadCHR(addr uint16) byte {
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	return m.rom.CHR[m.chrBank[bank]+offset]
+}
+
+func (m *Mapper24) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper24: write to read-only chr at %04X", addr)
+		return
+	}
+
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	m.rom.CHR[m.chrBank[bank]+offset] = data
+}
+
+func (m *Mapper24) TickAudio() {
+	m.audio.Tick()
+}
+
+func (m *Mapper24) AudioOutput() float32 {
+	return m.audio.Output()
+}
+
+func (m *Mapper24) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper24) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		m.audio.SaveState(w),
+		m.irq.saveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteUint8(m.prgReg16),
+		w.WriteUint8(m.prgReg8),
+		w.WriteByteSlice(m.chrRegs[:]),
+		w.WriteUint8(m.control),
+		w.WriteBool(m.ramEnable),
+	)
+}
+
+func (m *Mapper24) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		m.audio.LoadState(r),
+		m.irq.loadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadUint8To(&m.prgReg16),
+		r.ReadUint8To(&m.prgReg8),
+		r.ReadByteSliceTo(m.chrRegs[:]),
+		r.ReadUint8To(&m.control),
+		r.ReadBoolTo(&m.ramEnable),
+	)
+
+	m.updateBanks()
+
+	return err
+}
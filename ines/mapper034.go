@@ -0,0 +1,141 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper34 implements two unrelated boards sharing the same mapper number:
+// BNROM (Deadly Towers), which only switches 32 KB of PRG-ROM, and NINA-001
+// (Impossible Mission II), which also has two 4 KB CHR banks and PRG-RAM. The
+// NES 2.0 submapper tells them apart, otherwise the CHR-ROM size does.
+// https://www.nesdev.org/wiki/INES_Mapper_034
+type Mapper34 struct {
+	rom     *ROM
+	sram    []byte
+	nina    bool
+	prgBank uint8
+	chrBank [2]uint8
+}
+
+func NewMapper34(rom *ROM) *Mapper34 {
+	m := &Mapper34{rom: rom}
+
+	switch rom.Submapper {
+	case 1:
+		m.nina = true
+	case 2:
+		m.nina = false
+	default:
+		m.nina = len(rom.CHR) > 0x2000
+	}
+
+	if m.nina {
+		m.sram = rom.newPRGRAM()
+	}
+
+	return m
+}
+
+func (m *Mapper34) Reset() {
+	m.prgBank = 0
+	m.chrBank = [2]uint8{0, 1}
+}
+
+func (m *Mapper34) ScanlineTick() {}
+
+func (m *Mapper34) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper34) MirrorMode() MirrorMode {
+	return m.rom.MirrorMode
+}
+
+func (m *Mapper34) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF && len(m.sram) > 0:
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case addr >= 0x8000:
+		idx := int(m.prgBank)*0x8000 + int(addr-0x8000)
+		return m.rom.PRG[idx%len(m.rom.PRG)]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper34) WritePRG(addr uint16, data byte) {
+	switch {
+	case m.nina && addr >= 0x6000 && addr <= 0x7FFF:
+		// The registers are mapped over the RAM, which also gets the write.
+		switch addr {
+		case 0x7FFD:
+			m.prgBank = data & 0x01
+		case 0x7FFE:
+			m.chrBank[0] = data & 0x0F
+		case 0x7FFF:
+			m.chrBank[1] = data & 0x0F
+		}
+
+		if len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
+	case !m.nina && addr >= 0x8000:
+		// BNROM has bus conflicts.
+		m.prgBank = data & m.ReadPRG(addr)
+	default:
+		log.Printf("[WARN] mapper34: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper34) chrIndex(addr uint16) int {
+	if !m.nina {
+		return int(addr) % len(m.rom.CHR)
+	}
+
+	bank := m.chrBank[addr/0x1000]
+	idx := int(bank)*0x1000 + int(addr%0x1000)
+
+	return idx % len(m.rom.CHR)
+}
+
+func (m *Mapper34) ReadCHR(addr uint16) byte {
+	return m.rom.CHR[m.chrIndex(addr)]
+}
+
+func (m *Mapper34) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper34: write to read-only chr at %04X", addr)
+		return
+	}
+
+	m.rom.CHR[m.chrIndex(addr)] = data
+}
+
+func (m *Mapper34) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper34) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteUint8(m.prgBank),
+		w.WriteByteSlice(m.chrBank[:]),
+	)
+}
+
+func (m *Mapper34) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadUint8To(&m.prgBank),
+		r.ReadByteSliceTo(m.chrBank[:]),
+	)
+}
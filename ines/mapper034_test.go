@@ -0,0 +1,35 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper34_BNROM(t *testing.T) {
+	m := NewMapper34(newTestROM(34, 128*1024, 0x2000))
+	m.Reset()
+
+	m.WritePRG(0x80FF, 0x03)
+	testutil.Equal(t, m.ReadPRG(0x8000), 12)
+	testutil.Equal(t, m.ReadPRG(0xE000), 15)
+
+	// Bus conflict with the $0C at $8000 in bank 3, which masks out bit 0.
+	m.WritePRG(0x8000, 0x01)
+	testutil.Equal(t, m.ReadPRG(0x8000), 0)
+}
+
+func TestMapper34_NINA001(t *testing.T) {
+	m := NewMapper34(newTestROM(34, 64*1024, 64*1024))
+	m.Reset()
+
+	m.WritePRG(0x7FFD, 0x01)
+	m.WritePRG(0x7FFE, 0x05)
+	m.WritePRG(0x7FFF, 0x07)
+	testutil.Equal(t, m.ReadPRG(0x8000), 4)
+	testutil.Equal(t, m.ReadCHR(0x0000), 20)
+	testutil.Equal(t, m.ReadCHR(0x1000), 28)
+
+	// The registers are mapped over the RAM.
+	testutil.Equal(t, m.ReadPRG(0x7FFE), 0x05)
+}
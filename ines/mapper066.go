@@ -0,0 +1,96 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper66 implements GxROM (and MxROM), used by the Super Mario Bros. + Duck
+// Hunt multicart among others. A single register selects a 32 KB PRG bank and
+// an 8 KB CHR bank.
+// https://www.nesdev.org/wiki/GxROM
+type Mapper66 struct {
+	rom       *ROM
+	conflicts bool
+	prgBank   uint8
+	chrBank   uint8
+}
+
+func NewMapper66(rom *ROM) *Mapper66 {
+	return &Mapper66{
+		rom:       rom,
+		conflicts: rom.busConflicts(true),
+	}
+}
+
+func (m *Mapper66) Reset() {
+	m.prgBank = 0
+	m.chrBank = 0
+}
+
+func (m *Mapper66) ScanlineTick() {}
+
+func (m *Mapper66) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper66) MirrorMode() MirrorMode {
+	return m.rom.MirrorMode
+}
+
+func (m *Mapper66) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x8000:
+		idx := int(m.prgBank)*0x8000 + int(addr-0x8000)
+		return m.rom.PRG[idx%len(m.rom.PRG)]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper66) WritePRG(addr uint16, data byte) {
+	if addr < 0x8000 {
+		log.Printf("[WARN] mapper66: unhandled prg write at %04X", addr)
+		return
+	}
+
+	if m.conflicts {
+		data &= m.ReadPRG(addr)
+	}
+
+	m.prgBank = (data >> 4) & 0x03
+	m.chrBank = data & 0x03
+}
+
+func (m *Mapper66) ReadCHR(addr uint16) byte {
+	idx := int(m.chrBank)*0x2000 + int(addr)
+	return m.rom.CHR[idx%len(m.rom.CHR)]
+}
+
+func (m *Mapper66) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper66: write to read-only chr at %04X", addr)
+		return
+	}
+
+	idx := int(m.chrBank)*0x2000 + int(addr)
+	m.rom.CHR[idx%len(m.rom.CHR)] = data
+}
+
+func (m *Mapper66) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteUint8(m.prgBank),
+		w.WriteUint8(m.chrBank),
+	)
+}
+
+func (m *Mapper66) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		m.rom.LoadState(r),
+		r.ReadUint8To(&m.prgBank),
+		r.ReadUint8To(&m.chrBank),
+	)
+}
@@ -0,0 +1,18 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper66_Banks(t *testing.T) {
+	m := NewMapper66(newTestROM(66, 128*1024, 32*1024))
+	m.Reset()
+
+	m.WritePRG(0x80FF, 0x21)
+	testutil.Equal(t, m.ReadPRG(0x8000), 8)
+	testutil.Equal(t, m.ReadPRG(0xE000), 11)
+	testutil.Equal(t, m.ReadCHR(0x0000), 8)
+	testutil.Equal(t, m.ReadCHR(0x1C00), 15)
+}
@@ -0,0 +1,233 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/apu"
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper69 implements the Sunsoft FME-7 mapper, and its 5A/5B variants. The
+// 5B also has a sound chip, which is always emulated, since it is silent until
+// the game writes to it.
+// https://www.nesdev.org/wiki/Sunsoft_FME-7
+type Mapper69 struct {
+	rom   *ROM
+	sram  []byte
+	audio apu.Sunsoft5BAudio
+
+	command    byte
+	prgRegs    [4]byte // $6000, $8000, $A000, $C000
+	chrRegs    [8]byte
+	mirror     MirrorMode
+	prgBank    [5]int
+	chrBank    [8]int
+	irqEnable  bool
+	irqCount   bool // counter decrement enabled
+	irqCounter uint16
+	irqPending bool
+}
+
+func NewMapper69(rom *ROM) *Mapper69 {
+	return &Mapper69{
+		rom:  rom,
+		sram: rom.newPRGRAM(),
+	}
+}
+
+func (m *Mapper69) Reset() {
+	m.command = 0
+	m.prgRegs = [4]byte{}
+	m.chrRegs = [8]byte{}
+	m.mirror = MirrorVertical
+	m.irqEnable = false
+	m.irqCount = false
+	m.irqCounter = 0
+	m.irqPending = false
+
+	m.audio.Reset()
+	m.updateBanks()
+}
+
+func (m *Mapper69) updateBanks() {
+	numPRG := len(m.rom.PRG) / 0x2000
+
+	for i, reg := range m.prgRegs {
+		m.prgBank[i] = int(reg&0x3F) % numPRG * 0x2000
+	}
+
+	m.prgBank[4] = (numPRG - 1) * 0x2000
+
+	// $6000 can be mapped to either ROM or RAM.
+	if m.prgRegs[0]&0x40 != 0 && len(m.sram) > 0 {
+		m.prgBank[0] = int(m.prgRegs[0]&0x3F) * 0x2000 % len(m.sram)
+	}
+
+	for i, reg := range m.chrRegs {
+		m.chrBank[i] = int(reg) * 0x0400 % len(m.rom.CHR)
+	}
+}
+
+func (m *Mapper69) ScanlineTick() {}
+
+func (m *Mapper69) TickCPU() {
+	if !m.irqCount {
+		return
+	}
+
+	m.irqCounter--
+
+	if m.irqCounter == 0xFFFF && m.irqEnable {
+		m.irqPending = true
+	}
+}
+
+func (m *Mapper69) PendingIRQ() bool {
+	return m.irqPending
+}
+
+func (m *Mapper69) MirrorMode() MirrorMode {
+	return m.mirror
+}
+
+func (m *Mapper69) writeParameter(data byte) {
+	switch cmd := m.command; {
+	case cmd <= 0x07:
+		m.chrRegs[cmd] = data
+		m.updateBanks()
+	case cmd <= 0x0B:
+		m.prgRegs[cmd-0x08] = data
+		m.updateBanks()
+	case cmd == 0x0C:
+		switch data & 0x03 {
+		case 0:
+			m.mirror = MirrorVertical
+		case 1:
+			m.mirror = MirrorHorizontal
+		case 2:
+			m.mirror = MirrorSingle0
+		case 3:
+			m.mirror = MirrorSingle1
+		}
+	case cmd == 0x0D:
+		m.irqEnable = data&0x01 != 0
+		m.irqCount = data&0x80 != 0
+		m.irqPending = false
+	case cmd == 0x0E:
+		m.irqCounter = m.irqCounter&0xFF00 | uint16(data)
+	case cmd == 0x0F:
+		m.irqCounter = m.irqCounter&0x00FF | uint16(data)<<8
+	}
+}
+
+func (m *Mapper69) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		offset := int(addr % 0x2000)
+
+		if m.prgRegs[0]&0x40 == 0 {
+			return m.rom.PRG[m.prgBank[0]+offset]
+		}
+
+		if m.prgRegs[0]&0x80 == 0 || len(m.sram) == 0 {
+			return 0 // RAM disabled, open bus
+		}
+
+		return m.sram[(m.prgBank[0]+offset)%len(m.sram)]
+	case addr >= 0x8000:
+		bank := (addr-0x8000)/0x2000 + 1
+		offset := int(addr % 0x2000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		log.Printf("[WARN] mapper69: unhandled prg read at %04X", addr)
+		return 0
+	}
+}
+
+func (m *Mapper69) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if m.prgRegs[0]&0xC0 == 0xC0 && len(m.sram) > 0 {
+			offset := int(addr % 0x2000)
+			m.sram[(m.prgBank[0]+offset)%len(m.sram)] = data
+		}
+	case addr >= 0x8000 && addr <= 0x9FFF:
+		m.command = data & 0x0F
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		m.writeParameter(data)
+	case addr >= 0xC000:
+		m.audio.Write(addr, data)
+	default:
+		log.Printf("[WARN] mapper69: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper69) ReadCHR(addr uint16) byte {
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	return m.rom.CHR[m.chrBank[bank]+offset]
+}
+
+func (m *Mapper69) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper69: write to read-only chr at %04X", addr)
+		return
+	}
+
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	m.rom.CHR[m.chrBank[bank]+offset] = data
+}
+
+func (m *Mapper69) TickAudio() {
+	m.audio.Tick()
+}
+
+func (m *Mapper69) AudioOutput() float32 {
+	return m.audio.Output()
+}
+
+func (m *Mapper69) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper69) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		m.audio.SaveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteUint8(m.command),
+		w.WriteByteSlice(m.prgRegs[:]),
+		w.WriteByteSlice(m.chrRegs[:]),
+		w.WriteUint8(m.mirror),
+		w.WriteBool(m.irqEnable),
+		w.WriteBool(m.irqCount),
+		w.WriteUint16(m.irqCounter),
+		w.WriteBool(m.irqPending),
+	)
+}
+
+func (m *Mapper69) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		m.audio.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadUint8To(&m.command),
+		r.ReadByteSliceTo(m.prgRegs[:]),
+		r.ReadByteSliceTo(m.chrRegs[:]),
+		r.ReadUint8To(&m.mirror),
+		r.ReadBoolTo(&m.irqEnable),
+		r.ReadBoolTo(&m.irqCount),
+		r.ReadUint16To(&m.irqCounter),
+		r.ReadBoolTo(&m.irqPending),
+	)
+
+	m.updateBanks()
+
+	return err
+}
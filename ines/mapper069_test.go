@@ -0,0 +1,134 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper69_Banking(t *testing.T) {
+	m := NewMapper69(newTestROM(69, 256*1024, 256*1024))
+	m.Reset()
+
+	command := func(cmd, data byte) {
+		m.WritePRG(0x8000, cmd)
+		m.WritePRG(0xA000, data)
+	}
+
+	command(0x09, 0x05)
+	command(0x0A, 0x06)
+	command(0x0B, 0x27) // wraps around the 32 banks
+	testutil.Equal(t, m.ReadPRG(0x8000), 5)
+	testutil.Equal(t, m.ReadPRG(0xA000), 6)
+	testutil.Equal(t, m.ReadPRG(0xC000), 7)
+	testutil.Equal(t, m.ReadPRG(0xE000), 31) // fixed to the last bank
+
+	command(0x00, 0x10)
+	command(0x07, 0x2A)
+	testutil.Equal(t, m.ReadCHR(0x0000), 0x10)
+	testutil.Equal(t, m.ReadCHR(0x1C00), 0x2A)
+
+	command(0x0C, 0x01)
+	testutil.Equal(t, m.MirrorMode(), MirrorHorizontal)
+	command(0x0C, 0x03)
+	testutil.Equal(t, m.MirrorMode(), MirrorSingle1)
+
+	// Bit 6 of the $6000 bank maps the RAM instead of ROM, bit 7 enables it.
+	command(0x08, 0x03)
+	testutil.Equal(t, m.ReadPRG(0x6000), 3)
+	command(0x08, 0x40)
+	m.WritePRG(0x6000, 0xAA)
+	testutil.Equal(t, m.ReadPRG(0x6000), 0x00)
+	command(0x08, 0xC0)
+	m.WritePRG(0x6000, 0xAA)
+	testutil.Equal(t, m.ReadPRG(0x6000), 0xAA)
+}
+
+func TestMapper69_IRQ(t *testing.T) {
+	m := NewMapper69(newTestROM(69, 256*1024, 256*1024))
+	m.Reset()
+
+	command := func(cmd, data byte) {
+		m.WritePRG(0x8000, cmd)
+		m.WritePRG(0xA000, data)
+	}
+
+	command(0x0E, 0x02)
+	command(0x0F, 0x00)
+
+	// The counter does not run until enabled.
+	for i := 0; i < 10; i++ {
+		m.TickCPU()
+	}
+	testutil.Equal(t, m.irqCounter, 2)
+
+	// The IRQ fires when the counter wraps from $0000 to $FFFF.
+	command(0x0D, 0x81)
+	m.TickCPU()
+	m.TickCPU()
+	testutil.Equal(t, m.PendingIRQ(), false)
+	m.TickCPU()
+	testutil.Equal(t, m.PendingIRQ(), true)
+
+	// Any write to the control register acknowledges it.
+	command(0x0D, 0x81)
+	testutil.Equal(t, m.PendingIRQ(), false)
+
+	// With the IRQ disabled, the counter still runs, but never fires.
+	command(0x0E, 0x00)
+	command(0x0F, 0x00)
+	command(0x0D, 0x80)
+	m.TickCPU()
+	testutil.Equal(t, m.irqCounter, 0xFFFF)
+	testutil.Equal(t, m.PendingIRQ(), false)
+}
+
+func TestMapper69_SaveLoadState(t *testing.T) {
+	rom := newTestROM(69, 256*1024, 256*1024)
+	rom.Battery = true
+
+	m := NewMapper69(rom)
+	m.Reset()
+
+	command := func(cmd, data byte) {
+		m.WritePRG(0x8000, cmd)
+		m.WritePRG(0xA000, data)
+	}
+
+	command(0x08, 0xC0)
+	command(0x09, 0x05)
+	command(0x03, 0x11)
+	command(0x0C, 0x02)
+	m.WritePRG(0x6123, 0x42)
+
+	command(0x0E, 0x10)
+	command(0x0F, 0x00)
+	command(0x0D, 0x81)
+
+	for i := 0; i < 8; i++ {
+		m.TickCPU()
+	}
+
+	// Leave the command register pointing at the counter.
+	m.WritePRG(0x8000, 0x0F)
+
+	restored := NewMapper69(rom)
+	restored.Reset()
+	loadState(t, restored, saveState(t, m))
+
+	testutil.Equal(t, restored.ReadPRG(0x8000), 5)
+	testutil.Equal(t, restored.ReadPRG(0x6123), 0x42)
+	testutil.Equal(t, restored.ReadCHR(0x0C00), 0x11)
+	testutil.Equal(t, restored.MirrorMode(), MirrorSingle0)
+	testutil.Equal(t, restored.BatteryRAM()[0x0123], 0x42)
+
+	restored.WritePRG(0xA000, 0x00)
+	testutil.Equal(t, restored.irqCounter, 0x0008)
+
+	for i := 0; i < 8; i++ {
+		restored.TickCPU()
+	}
+	testutil.Equal(t, restored.PendingIRQ(), false)
+	restored.TickCPU()
+	testutil.Equal(t, restored.PendingIRQ(), true)
+}
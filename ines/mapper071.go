@@ -0,0 +1,112 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper71 implements the Camerica/Codemasters BF909x boards. It works like
+// UxROM, with the register at $C000-$FFFF. The BF9097 variant used by Fire
+// Hawk also controls single-screen mirroring with writes to $8000-$9FFF. It
+// is either selected by NES 2.0 submapper 1, or enabled as soon as the game
+// writes there, since the other games never do.
+// https://www.nesdev.org/wiki/INES_Mapper_071
+type Mapper71 struct {
+	rom     *ROM
+	prgBank uint8
+	mirror  MirrorMode
+	bf9097  bool
+}
+
+func NewMapper71(rom *ROM) *Mapper71 {
+	return &Mapper71{
+		rom:    rom,
+		bf9097: rom.Submapper == 1,
+	}
+}
+
+func (m *Mapper71) Reset() {
+	m.prgBank = 0
+	m.mirror = MirrorSingle0
+}
+
+func (m *Mapper71) ScanlineTick() {}
+
+func (m *Mapper71) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper71) MirrorMode() MirrorMode {
+	if m.bf9097 {
+		return m.mirror
+	}
+
+	return m.rom.MirrorMode
+}
+
+func (m *Mapper71) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x8000 && addr <= 0xBFFF:
+		idx := int(m.prgBank)*0x4000 + int(addr-0x8000)
+		return m.rom.PRG[idx%len(m.rom.PRG)]
+	case addr >= 0xC000:
+		idx := len(m.rom.PRG) - 0x4000 + int(addr-0xC000)
+		return m.rom.PRG[idx]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper71) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x8000 && addr <= 0x9FFF:
+		if addr >= 0x9000 {
+			m.bf9097 = true
+		}
+
+		if data&0x10 != 0 {
+			m.mirror = MirrorSingle1
+		} else {
+			m.mirror = MirrorSingle0
+		}
+	case addr >= 0xC000:
+		m.prgBank = data & 0x0F
+	case addr >= 0xA000:
+		// Unused.
+	default:
+		log.Printf("[WARN] mapper71: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper71) ReadCHR(addr uint16) byte {
+	return m.rom.CHR[addr]
+}
+
+func (m *Mapper71) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper71: write to read-only chr at %04X", addr)
+		return
+	}
+
+	m.rom.CHR[addr] = data
+}
+
+func (m *Mapper71) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteUint8(m.prgBank),
+		w.WriteUint8(m.mirror),
+		w.WriteBool(m.bf9097),
+	)
+}
+
+func (m *Mapper71) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		m.rom.LoadState(r),
+		r.ReadUint8To(&m.prgBank),
+		r.ReadUint8To(&m.mirror),
+		r.ReadBoolTo(&m.bf9097),
+	)
+}
@@ -0,0 +1,33 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper71_Banks(t *testing.T) {
+	m := NewMapper71(newTestROM(71, 256*1024, 0x2000))
+	m.Reset()
+
+	m.WritePRG(0xC000, 0x05)
+	testutil.Equal(t, m.ReadPRG(0x8000), 10)
+	testutil.Equal(t, m.ReadPRG(0xC000), 30) // fixed to the last bank
+}
+
+func TestMapper71_Mirroring(t *testing.T) {
+	rom := newTestROM(71, 256*1024, 0x2000)
+	rom.MirrorMode = MirrorVertical
+
+	m := NewMapper71(rom)
+	m.Reset()
+
+	// The header is used until the game writes to $9000-$9FFF.
+	m.WritePRG(0x8000, 0x10)
+	testutil.Equal(t, m.MirrorMode(), MirrorVertical)
+
+	m.WritePRG(0x9000, 0x10)
+	testutil.Equal(t, m.MirrorMode(), MirrorSingle1)
+	m.WritePRG(0x9000, 0x00)
+	testutil.Equal(t, m.MirrorMode(), MirrorSingle0)
+}
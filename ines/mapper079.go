@@ -0,0 +1,92 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper79 implements the AVE NINA-03 and NINA-06 boards. The register is
+// mapped to $4100-$5FFF (only the addresses with A8 set), and selects a 32 KB
+// PRG bank and an 8 KB CHR bank.
+// https://www.nesdev.org/wiki/NINA-003-006
+type Mapper79 struct {
+	rom     *ROM
+	prgBank uint8
+	chrBank uint8
+}
+
+func NewMapper79(rom *ROM) *Mapper79 {
+	return &Mapper79{
+		rom: rom,
+	}
+}
+
+func (m *Mapper79) Reset() {
+	m.prgBank = 0
+	m.chrBank = 0
+}
+
+func (m *Mapper79) ScanlineTick() {}
+
+func (m *Mapper79) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper79) MirrorMode() MirrorMode {
+	return m.rom.MirrorMode
+}
+
+func (m *Mapper79) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x8000:
+		idx := int(m.prgBank)*0x8000 + int(addr-0x8000)
+		return m.rom.PRG[idx%len(m.rom.PRG)]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper79) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x4100 && addr <= 0x5FFF && addr&0x0100 != 0:
+		m.prgBank = (data >> 3) & 0x01
+		m.chrBank = data & 0x07
+	case addr >= 0x4020 && addr <= 0x5FFF:
+		// Not decoded by the board.
+	default:
+		log.Printf("[WARN] mapper79: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper79) ReadCHR(addr uint16) byte {
+	idx := int(m.chrBank)*0x2000 + int(addr)
+	return m.rom.CHR[idx%len(m.rom.CHR)]
+}
+
+func (m *Mapper79) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper79: write to read-only chr at %04X", addr)
+		return
+	}
+
+	idx := int(m.chrBank)*0x2000 + int(addr)
+	m.rom.CHR[idx%len(m.rom.CHR)] = data
+}
+
+func (m *Mapper79) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteUint8(m.prgBank),
+		w.WriteUint8(m.chrBank),
+	)
+}
+
+func (m *Mapper79) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		m.rom.LoadState(r),
+		r.ReadUint8To(&m.prgBank),
+		r.ReadUint8To(&m.chrBank),
+	)
+}
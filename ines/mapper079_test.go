@@ -0,0 +1,22 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper79_Banks(t *testing.T) {
+	m := NewMapper79(newTestROM(79, 64*1024, 64*1024))
+	m.Reset()
+
+	m.WritePRG(0x4100, 0x0D)
+	testutil.Equal(t, m.ReadPRG(0x8000), 4)
+	testutil.Equal(t, m.ReadCHR(0x0000), 40)
+
+	// The register is only decoded when A8 is set.
+	m.WritePRG(0x4200, 0x00)
+	testutil.Equal(t, m.ReadPRG(0x8000), 4)
+	m.WritePRG(0x5F00, 0x00)
+	testutil.Equal(t, m.ReadPRG(0x8000), 0)
+}
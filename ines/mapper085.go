@@ -0,0 +1,219 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/apu"
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper85 implements the Konami VRC7 mapper. There are two board variants,
+// which use either A4 (VRC7a) or A3 (VRC7b) to select the second register in
+// each pair. Both are accepted, as they do not overlap.
+// https://www.nesdev.org/wiki/VRC7
+type Mapper85 struct {
+	rom   *ROM
+	sram  []byte
+	audio apu.VRC7Audio
+	irq   vrcIRQ
+
+	prgRegs   [3]byte
+	chrRegs   [8]byte
+	control   byte // $E000
+	prgBank   [4]int
+	chrBank   [8]int
+	ramEnable bool
+}
+
+func NewMapper85(rom *ROM) *Mapper85 {
+	return &Mapper85{
+		rom:  rom,
+		sram: rom.newPRGRAM(),
+	}
+}
+
+func (m *Mapper85) Reset() {
+	m.prgRegs = [3]byte{}
+	m.chrRegs = [8]byte{}
+	m.control = 0
+	m.ramEnable = false
+
+	m.irq.reset()
+	m.audio.Reset()
+	m.updateBanks()
+}
+
+func (m *Mapper85) updateBanks() {
+	numPRG := len(m.rom.PRG) / 0x2000
+
+	for i, reg := range m.prgRegs {
+		m.prgBank[i] = int(reg&0x3F) % numPRG * 0x2000
+	}
+
+	m.prgBank[3] = (numPRG - 1) * 0x2000
+
+	for i, reg := range m.chrRegs {
+		m.chrBank[i] = int(reg) * 0x0400 % len(m.rom.CHR)
+	}
+}
+
+func (m *Mapper85) ScanlineTick() {}
+
+func (m *Mapper85) TickCPU() {
+	m.irq.tick()
+}
+
+func (m *Mapper85) PendingIRQ() bool {
+	return m.irq.pending
+}
+
+func (m *Mapper85) MirrorMode() MirrorMode {
+	switch m.control & 0x03 {
+	case 0:
+		return MirrorVertical
+	case 1:
+		return MirrorHorizontal
+	case 2:
+		return MirrorSingle0
+	default:
+		return MirrorSingle1
+	}
+}
+
+func (m *Mapper85) writeRegister(addr uint16, data byte) {
+	second := addr&0x18 != 0
+
+	switch addr & 0xF000 {
+	case 0x8000:
+		if second {
+			m.prgRegs[1] = data
+		} else {
+			m.prgRegs[0] = data
+		}
+		m.updateBanks()
+	case 0x9000:
+		switch {
+		case addr&0xF030 == 0x9010 || addr&0xF030 == 0x9030:
+			m.audio.Write(addr&0xF030, data)
+		case !second:
+			m.prgRegs[2] = data
+			m.updateBanks()
+		}
+	case 0xA000, 0xB000, 0xC000, 0xD000:
+		idx := (addr - 0xA000) >> 12 * 2
+		if second {
+			idx++
+		}
+		m.chrRegs[idx] = data
+		m.updateBanks()
+	case 0xE000:
+		if second {
+			m.irq.latch = data
+			return
+		}
+
+		m.control = data
+		m.ramEnable = data&0x80 != 0
+		m.audio.Silence(data&0x40 != 0)
+	case 0xF000:
+		if second {
+			m.irq.acknowledge()
+		} else {
+			m.irq.writeControl(data)
+		}
+	}
+}
+
+func (m *Mapper85) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if !m.ramEnable || len(m.sram) == 0 {
+			return 0
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x2000
+		offset := int(addr % 0x2000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		log.Printf("[WARN] mapper85: unhandled prg read at %04X", addr)
+		return 0
+	}
+}
+
+func (m *Mapper85) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if m.ramEnable && len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
+	case addr >= 0x8000:
+		m.writeRegister(addr, data)
+	default:
+		log.Printf("[WARN] mapper85: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper85) ReadCHR(addr uint16) byte {
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	return m.rom.CHR[m.chrBank[bank]+offset]
+}
+
+func (m *Mapper85) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper85: write to read-only chr at %04X", addr)
+		return
+	}
+
+	bank := int(addr / 0x0400)
+	offset := int(addr % 0x0400)
+	m.rom.CHR[m.chrBank[bank]+offset] = data
+}
+
+func (m *Mapper85) TickAudio() {
+	m.audio.Tick()
+}
+
+func (m *Mapper85) AudioOutput() float32 {
+	return m.audio.Output()
+}
+
+func (m *Mapper85) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper85) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		m.audio.SaveState(w),
+		m.irq.saveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteByteSlice(m.prgRegs[:]),
+		w.WriteByteSlice(m.chrRegs[:]),
+		w.WriteUint8(m.control),
+		w.WriteBool(m.ramEnable),
+	)
+}
+
+func (m *Mapper85) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		m.audio.LoadState(r),
+		m.irq.loadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadByteSliceTo(m.prgRegs[:]),
+		r.ReadByteSliceTo(m.chrRegs[:]),
+		r.ReadUint8To(&m.control),
+		r.ReadBoolTo(&m.ramEnable),
+	)
+
+	m.updateBanks()
+
+	return err
+}
@@ -0,0 +1,512 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// jyNametables tells whether a J.Y. Company board can map CHR-ROM into the
+// nametables, which is the only difference between the mappers 90, 209 and 211.
+type jyNametables uint8
+
+const (
+	jyNametablesNone     jyNametables = iota // mapper 90
+	jyNametablesOptional                     // mapper 209, enabled by bit 5 of $D000
+	jyNametablesAlways                       // mapper 211
+)
+
+// J.Y. Company IRQ counter clock sources, selected by the low bits of $C001.
+const (
+	jyIRQSourceCPU      = 0
+	jyIRQSourceA12      = 1
+	jyIRQSourcePPURead  = 2
+	jyIRQSourceCPUWrite = 3
+)
+
+// Mapper90 implements the J.Y. Company ASIC used by many Asian pirate games and
+// multicarts (mappers 90, 209 and 211). It has flexible PRG and CHR banking
+// modes, CHR-ROM nametables, a hardware multiplier, and an IRQ counter with a
+// prescaler that can be clocked from several sources. The outer bank register
+// ($D003) selects the game on multicarts, and the DIP switches at $5000 are
+// read by some of them to choose the menu.
+// https://www.nesdev.org/wiki/J.Y._Company_ASIC
+type Mapper90 struct {
+	rom        *ROM
+	sram       []byte
+	ciram      *[2][1024]byte
+	nametables jyNametables
+
+	prgRegs  [4]byte
+	chrRegs  [8]uint16
+	ntRegs   [4]uint16
+	mode     byte // $D000
+	mirror   byte // $D001
+	ntSelect byte // $D002
+	outer    byte // $D003
+	prgBank  [5]int
+	chrBank  [8]int
+
+	mulA    byte
+	mulB    byte
+	scratch byte // $5803
+
+	irqEnable    bool
+	irqMode      byte // $C001
+	irqPrescaler byte
+	irqCounter   byte
+	irqXOR       byte
+	irqPending   bool
+}
+
+func NewMapper90(rom *ROM, nametables jyNametables) *Mapper90 {
+	return &Mapper90{
+		rom:        rom,
+		sram:       rom.newPRGRAM(),
+		nametables: nametables,
+	}
+}
+
+func (m *Mapper90) Reset() {
+	m.prgRegs = [4]byte{}
+	m.chrRegs = [8]uint16{}
+	m.ntRegs = [4]uint16{}
+	m.mode = 0
+	m.mirror = 0
+	m.ntSelect = 0
+	m.outer = 0
+	m.mulA = 0
+	m.mulB = 0
+	m.scratch = 0
+	m.irqEnable = false
+	m.irqMode = 0
+	m.irqPrescaler = 0
+	m.irqCounter = 0
+	m.irqXOR = 0
+	m.irqPending = false
+
+	m.updateBanks()
+}
+
+// reverseBits reverses the order of the low 6 bits, used by PRG mode 3.
+func reverseBits(v byte) byte {
+	var r byte
+	for i := 0; i < 6; i++ {
+		r |= (v >> i & 0x01) << (5 - i)
+	}
+	return r
+}
+
+func (m *Mapper90) updateBanks() {
+	var (
+		numPRG   = len(m.rom.PRG) / 0x2000
+		prgOuter = int(m.outer&0x06) << 5 // 512 KB
+		regs     = m.prgRegs
+	)
+
+	for i := range regs {
+		regs[i] &= 0x3F
+
+		if m.mode&0x03 == 3 {
+			regs[i] = reverseBits(regs[i])
+		}
+	}
+
+	// The last bank is fixed, unless bit 2 of $D000 is set.
+	last := regs[3]
+	if m.mode&0x04 == 0 {
+		last = 0x3F
+	}
+
+	var banks [5]int // $6000, $8000, $A000, $C000, $E000
+
+	switch m.mode & 0x03 {
+	case 0: // 32 KB
+		b := int(last) * 4
+		banks = [5]int{int(regs[3])*4 + 3, b, b + 1, b + 2, b + 3}
+	case 1: // 16 KB
+		lo, hi := int(regs[1])*2, int(last)*2
+		banks = [5]int{int(regs[3])*2 + 1, lo, lo + 1, hi, hi + 1}
+	default: // 8 KB
+		banks = [5]int{int(regs[3]), int(regs[0]), int(regs[1]), int(regs[2]), int(last)}
+	}
+
+	for i, b := range banks {
+		m.prgBank[i] = (prgOuter | b) % numPRG * 0x2000
+	}
+
+	for i := range m.chrBank {
+		m.chrBank[i] = m.chrOffset(i)
+	}
+}
+
+// chrOffset returns the CHR offset of the given 1 KB slot of the pattern tables.
+func (m *Mapper90) chrOffset(slot int) int {
+	var (
+		reg    = func(i int) int { return int(m.chrRegs[i]) }
+		offset int
+	)
+
+	switch (m.mode >> 3) & 0x03 {
+	case 0: // 8 KB
+		offset = reg(0)*0x2000 + slot*0x0400
+	case 1: // 4 KB
+		offset = reg(slot&^3)*0x1000 + slot&3*0x0400
+	case 2: // 2 KB
+		offset = reg(slot&^1)*0x0800 + slot&1*0x0400
+	case 3: // 1 KB
+		offset = reg(slot) * 0x0400
+	}
+
+	// Unless bit 5 of $D003 is set, the high CHR registers are ignored, and the
+	// outer bank register selects a 256 KB block instead.
+	if m.outer&0x20 == 0 {
+		block := int(m.outer&0x01) | int(m.outer&0x18)>>2
+		offset = block*0x40000 + offset%0x40000
+	}
+
+	return offset % len(m.rom.CHR)
+}
+
+// clockIRQ advances the IRQ prescaler, and the counter when the prescaler
+// wraps around, in the direction selected by the bits 6-7 of $C001.
+func (m *Mapper90) clockIRQ() {
+	mask := byte(0xFF)
+	if m.irqMode&0x04 != 0 {
+		mask = 0x07
+	}
+
+	switch m.irqMode >> 6 {
+	case 1: // up
+		if m.irqPrescaler++; m.irqPrescaler&mask != 0 {
+			return
+		}
+
+		if m.irqCounter++; m.irqCounter == 0x00 && m.irqEnable {
+			m.irqPending = true
+		}
+	case 2: // down
+		if m.irqPrescaler--; m.irqPrescaler&mask != mask {
+			return
+		}
+
+		if m.irqCounter--; m.irqCounter == 0xFF && m.irqEnable {
+			m.irqPending = true
+		}
+	}
+}
+
+func (m *Mapper90) ScanlineTick() {}
+
+func (m *Mapper90) TickCPU() {
+	if m.irqMode&0x03 == jyIRQSourceCPU {
+		m.clockIRQ()
+	}
+}
+
+func (m *Mapper90) ConnectPPU(ciram *[2][1024]byte) PPUEvent {
+	m.ciram = ciram
+
+	// Without the ROM nametables, the mirroring register works as usual.
+	if m.nametables == jyNametablesNone {
+		return EventA12
+	}
+
+	return EventA12 | EventNametableRead | EventNametableWrite
+}
+
+func (m *Mapper90) PPUAccess(a BusAccess) byte {
+	switch a.Event {
+	case EventA12:
+		m.watchA12()
+	case EventNametableRead:
+		return m.readNametable(a.Addr)
+	case EventNametableWrite:
+		m.writeNametable(a.Addr, a.Data)
+	}
+
+	return 0
+}
+
+// watchA12 counts the A12 rises. The PPU reads source is approximated by them
+// too, since the emulator does not report every PPU read to the cartridge.
+func (m *Mapper90) watchA12() {
+	if src := m.irqMode & 0x03; src == jyIRQSourceA12 || src == jyIRQSourcePPURead {
+		m.clockIRQ()
+	}
+}
+
+func (m *Mapper90) PendingIRQ() bool {
+	return m.irqPending
+}
+
+func (m *Mapper90) MirrorMode() MirrorMode {
+	switch m.mirror & 0x03 {
+	case 0:
+		return MirrorVertical
+	case 1:
+		return MirrorHorizontal
+	case 2:
+		return MirrorSingle0
+	default:
+		return MirrorSingle1
+	}
+}
+
+func (m *Mapper90) ReadPRG(addr uint16) byte {
+	switch {
+	case addr == 0x5000:
+		return 0x00 // DIP switches, the first menu is selected
+	case addr == 0x5800:
+		return byte(uint16(m.mulA) * uint16(m.mulB))
+	case addr == 0x5801:
+		return byte(uint16(m.mulA) * uint16(m.mulB) >> 8)
+	case addr == 0x5803:
+		return m.scratch
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		offset := int(addr % 0x2000)
+
+		if m.mode&0x80 != 0 {
+			return m.rom.PRG[m.prgBank[0]+offset]
+		}
+
+		if len(m.sram) == 0 {
+			return 0
+		}
+
+		return m.sram[offset%len(m.sram)]
+	case addr >= 0x8000:
+		bank := (addr-0x8000)/0x2000 + 1
+		offset := int(addr % 0x2000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper90) writeIRQ(addr uint16, data byte) {
+	switch addr & 0x07 {
+	case 0:
+		if m.irqEnable = data&0x01 != 0; !m.irqEnable {
+			m.irqPending = false
+		}
+	case 1:
+		m.irqMode = data
+	case 2:
+		m.irqEnable = false
+		m.irqPending = false
+	case 3:
+		m.irqEnable = true
+	case 4:
+		m.irqPrescaler = data ^ m.irqXOR
+	case 5:
+		m.irqCounter = data ^ m.irqXOR
+	case 6:
+		m.irqXOR = data
+	}
+}
+
+func (m *Mapper90) WritePRG(addr uint16, data byte) {
+	if m.irqMode&0x03 == jyIRQSourceCPUWrite {
+		m.clockIRQ() // only the writes to the cartridge space are seen here
+	}
+
+	switch {
+	case addr == 0x5800:
+		m.mulA = data
+	case addr == 0x5801:
+		m.mulB = data
+	case addr == 0x5803:
+		m.scratch = data
+	case addr >= 0x5000 && addr <= 0x5FFF:
+		// Unused.
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if m.mode&0x80 == 0 && len(m.sram) > 0 {
+			m.sram[int(addr%0x2000)%len(m.sram)] = data
+		}
+	case addr >= 0x8000 && addr <= 0x8FFF:
+		m.prgRegs[addr&0x03] = data
+		m.updateBanks()
+	case addr >= 0x9000 && addr <= 0x9FFF:
+		i := addr & 0x07
+		m.chrRegs[i] = m.chrRegs[i]&0xFF00 | uint16(data)
+		m.updateBanks()
+	case addr >= 0xA000 && addr <= 0xAFFF:
+		i := addr & 0x07
+		m.chrRegs[i] = m.chrRegs[i]&0x00FF | uint16(data)<<8
+		m.updateBanks()
+	case addr >= 0xB000 && addr <= 0xBFFF:
+		if i := addr & 0x03; addr&0x04 == 0 {
+			m.ntRegs[i] = m.ntRegs[i]&0xFF00 | uint16(data)
+		} else {
+			m.ntRegs[i] = m.ntRegs[i]&0x00FF | uint16(data)<<8
+		}
+	case addr >= 0xC000 && addr <= 0xCFFF:
+		m.writeIRQ(addr, data)
+	case addr >= 0xD000 && addr <= 0xDFFF:
+		switch addr & 0x03 {
+		case 0:
+			m.mode = data
+		case 1:
+			m.mirror = data
+		case 2:
+			m.ntSelect = data
+		case 3:
+			m.outer = data
+		}
+		m.updateBanks()
+	default:
+		log.Printf("[WARN] mapper90: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper90) ReadCHR(addr uint16) byte {
+	bank := addr / 0x0400
+	offset := int(addr % 0x0400)
+	return m.rom.CHR[m.chrBank[bank]+offset]
+}
+
+func (m *Mapper90) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper90: write to read-only chr at %04X", addr)
+		return
+	}
+
+	bank := addr / 0x0400
+	offset := int(addr % 0x0400)
+	m.rom.CHR[m.chrBank[bank]+offset] = data
+}
+
+// romNametables tells whether the nametables can be mapped to CHR-ROM.
+func (m *Mapper90) romNametables() bool {
+	return m.nametables == jyNametablesAlways ||
+		m.nametables == jyNametablesOptional && m.mode&0x20 != 0
+}
+
+// romNametable returns the CHR offset of the nametable mapped to the given
+// slot, or -1 if it is one of the console's nametables.
+func (m *Mapper90) romNametable(slot int) int {
+	if !m.romNametables() {
+		return -1
+	}
+
+	// Banks with the bit 7 matching $D002 select the console's nametables,
+	// unless bit 6 of $D000 forces CHR-ROM for all of them.
+	reg := m.ntRegs[slot]
+	if m.mode&0x40 == 0 && byte(reg)&0x80 == m.ntSelect&0x80 {
+		return -1
+	}
+
+	return int(reg) * 0x0400 % len(m.rom.CHR)
+}
+
+// ciramPage returns the console's nametable mapped to the given slot.
+func (m *Mapper90) ciramPage(slot int) int {
+	if m.romNametables() {
+		return int(m.ntRegs[slot] & 0x01)
+	}
+
+	switch m.MirrorMode() {
+	case MirrorVertical:
+		return slot & 0x01
+	case MirrorHorizontal:
+		return slot >> 1
+	case MirrorSingle0:
+		return 0
+	default:
+		return 1
+	}
+}
+
+func (m *Mapper90) readNametable(addr uint16) byte {
+	slot := int(addr>>10) & 0x03
+	offset := int(addr & 0x03FF)
+
+	if rom := m.romNametable(slot); rom >= 0 {
+		return m.rom.CHR[rom+offset]
+	}
+
+	return m.ciram[m.ciramPage(slot)][offset]
+}
+
+func (m *Mapper90) writeNametable(addr uint16, data byte) {
+	slot := int(addr>>10) & 0x03
+
+	// CHR-ROM mapped into the nametables is read-only.
+	if m.romNametable(slot) < 0 {
+		m.ciram[m.ciramPage(slot)][addr&0x03FF] = data
+	}
+}
+
+func (m *Mapper90) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper90) SaveState(w *binario.Writer) error {
+	err := errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteByteSlice(m.prgRegs[:]),
+		w.WriteUint8(m.mode),
+		w.WriteUint8(m.mirror),
+		w.WriteUint8(m.ntSelect),
+		w.WriteUint8(m.outer),
+		w.WriteUint8(m.mulA),
+		w.WriteUint8(m.mulB),
+		w.WriteUint8(m.scratch),
+		w.WriteBool(m.irqEnable),
+		w.WriteUint8(m.irqMode),
+		w.WriteUint8(m.irqPrescaler),
+		w.WriteUint8(m.irqCounter),
+		w.WriteUint8(m.irqXOR),
+		w.WriteBool(m.irqPending),
+	)
+
+	for _, reg := range m.chrRegs {
+		err = errors.Join(err, w.WriteUint16(reg))
+	}
+
+	for _, reg := range m.ntRegs {
+		err = errors.Join(err, w.WriteUint16(reg))
+	}
+
+	return err
+}
+
+func (m *Mapper90) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadByteSliceTo(m.prgRegs[:]),
+		r.ReadUint8To(&m.mode),
+		r.ReadUint8To(&m.mirror),
+		r.ReadUint8To(&m.ntSelect),
+		r.ReadUint8To(&m.outer),
+		r.ReadUint8To(&m.mulA),
+		r.ReadUint8To(&m.mulB),
+		r.ReadUint8To(&m.scratch),
+		r.ReadBoolTo(&m.irqEnable),
+		r.ReadUint8To(&m.irqMode),
+		r.ReadUint8To(&m.irqPrescaler),
+		r.ReadUint8To(&m.irqCounter),
+		r.ReadUint8To(&m.irqXOR),
+		r.ReadBoolTo(&m.irqPending),
+	)
+
+	for i := range m.chrRegs {
+		err = errors.Join(err, r.ReadUint16To(&m.chrRegs[i]))
+	}
+
+	for i := range m.ntRegs {
+		err = errors.Join(err, r.ReadUint16To(&m.ntRegs[i]))
+	}
+
+	m.updateBanks()
+
+	return err
+}
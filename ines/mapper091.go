@@ -0,0 +1,168 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// mapper91IRQScanlines is the number of scanlines after which the IRQ fires.
+const mapper91IRQScanlines = 8
+
+// Mapper91 implements the J.Y. Company boards used by pirate ports of fighting
+// games (Street Fighter III, Mortal Kombat II). It has two switchable 8 KB PRG
+// banks, four 2 KB CHR banks and a simple IRQ that fires 8 scanlines after it
+// is started. The 2-in-1 versions also have an outer bank register at $8000.
+// https://www.nesdev.org/wiki/INES_Mapper_091
+type Mapper91 struct {
+	rom        *ROM
+	prgRegs    [2]byte
+	chrRegs    [4]byte
+	outer      uint8
+	prgBank    [4]int
+	chrBank    [4]int
+	irqEnable  bool
+	irqCounter uint8
+	irqPending bool
+}
+
+func NewMapper91(rom *ROM) *Mapper91 {
+	return &Mapper91{
+		rom: rom,
+	}
+}
+
+func (m *Mapper91) Reset() {
+	m.prgRegs = [2]byte{}
+	m.chrRegs = [4]byte{}
+	m.outer = 0
+	m.irqEnable = false
+	m.irqCounter = 0
+	m.irqPending = false
+	m.updateBanks()
+}
+
+func (m *Mapper91) updateBanks() {
+	var (
+		numPRG   = len(m.rom.PRG) / 0x2000
+		numCHR   = len(m.rom.CHR) / 0x0800
+		prgOuter = int(m.outer) << 4 // 128 KB
+		chrOuter = int(m.outer) << 8 // 512 KB
+	)
+
+	banks := [4]int{
+		prgOuter | int(m.prgRegs[0]&0x0F),
+		prgOuter | int(m.prgRegs[1]&0x0F),
+		prgOuter | 0x0E,
+		prgOuter | 0x0F,
+	}
+
+	for i, b := range banks {
+		m.prgBank[i] = b % numPRG * 0x2000
+	}
+
+	for i, reg := range m.chrRegs {
+		m.chrBank[i] = (chrOuter | int(reg)) % numCHR * 0x0800
+	}
+}
+
+func (m *Mapper91) ScanlineTick() {
+	if !m.irqEnable || m.irqCounter >= mapper91IRQScanlines {
+		return
+	}
+
+	if m.irqCounter++; m.irqCounter == mapper91IRQScanlines {
+		m.irqPending = true
+	}
+}
+
+func (m *Mapper91) PendingIRQ() (v bool) {
+	v, m.irqPending = m.irqPending, false
+	return v
+}
+
+func (m *Mapper91) MirrorMode() MirrorMode {
+	return m.rom.MirrorMode
+}
+
+func (m *Mapper91) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x2000
+		offset := int(addr % 0x2000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper91) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x6FFF:
+		m.chrRegs[addr&0x03] = data
+		m.updateBanks()
+	case addr >= 0x7000 && addr <= 0x7FFF:
+		switch addr & 0x03 {
+		case 0, 1:
+			m.prgRegs[addr&0x01] = data
+			m.updateBanks()
+		case 2:
+			m.irqEnable = false
+			m.irqCounter = 0
+			m.irqPending = false
+		case 3:
+			m.irqEnable = true
+		}
+	case addr >= 0x8000 && addr <= 0x9FFF:
+		m.outer = uint8(addr & 0x01)
+		m.updateBanks()
+	default:
+		log.Printf("[WARN] mapper91: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper91) ReadCHR(addr uint16) byte {
+	bank := addr / 0x0800
+	offset := int(addr % 0x0800)
+	return m.rom.CHR[m.chrBank[bank]+offset]
+}
+
+func (m *Mapper91) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper91: write to read-only chr at %04X", addr)
+		return
+	}
+
+	bank := addr / 0x0800
+	offset := int(addr % 0x0800)
+	m.rom.CHR[m.chrBank[bank]+offset] = data
+}
+
+func (m *Mapper91) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.prgRegs[:]),
+		w.WriteByteSlice(m.chrRegs[:]),
+		w.WriteUint8(m.outer),
+		w.WriteBool(m.irqEnable),
+		w.WriteUint8(m.irqCounter),
+		w.WriteBool(m.irqPending),
+	)
+}
+
+func (m *Mapper91) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.prgRegs[:]),
+		r.ReadByteSliceTo(m.chrRegs[:]),
+		r.ReadUint8To(&m.outer),
+		r.ReadBoolTo(&m.irqEnable),
+		r.ReadUint8To(&m.irqCounter),
+		r.ReadBoolTo(&m.irqPending),
+	)
+
+	m.updateBanks()
+
+	return err
+}
@@ -0,0 +1,30 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper91_Banks(t *testing.T) {
+	rom := newTestROM(91, 256*1024, 1024*1024)
+	rom.CHR[(256+7)*0x0800] = 0xEE // the bank number does not fit in a byte
+
+	m := NewMapper91(rom)
+	m.Reset()
+
+	m.WritePRG(0x7000, 0x03)
+	m.WritePRG(0x7001, 0x05)
+	m.WritePRG(0x6002, 0x07) // 2 KB CHR bank at $1000
+	testutil.Equal(t, m.ReadPRG(0x8000), 3)
+	testutil.Equal(t, m.ReadPRG(0xA000), 5)
+	testutil.Equal(t, m.ReadPRG(0xC000), 14)
+	testutil.Equal(t, m.ReadPRG(0xE000), 15)
+	testutil.Equal(t, m.ReadCHR(0x1000), 14)
+
+	// The outer bank of the 2-in-1 carts.
+	m.WritePRG(0x8001, 0)
+	testutil.Equal(t, m.ReadPRG(0x8000), 19)
+	testutil.Equal(t, m.ReadPRG(0xE000), 31)
+	testutil.Equal(t, m.ReadCHR(0x1000), 0xEE)
+}
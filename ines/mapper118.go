@@ -0,0 +1,40 @@
+package ines
+
+// Mapper118 implements the TxSROM boards (TKSROM, TLSROM) used by Armadillo
+// and Goal! Two. It is an MMC3, but the nametables are selected by bit 7 of the
+// CHR bank mapped to the corresponding 1 KB of the first pattern table, rather
+// than by the mirroring register. This allows games to change the mirroring
+// in the middle of the frame, since the banks can be switched at any time.
+// https://www.nesdev.org/wiki/INES_Mapper_118
+type Mapper118 struct {
+	*Mapper4
+	ciram *[2][1024]byte
+}
+
+func NewMapper118(rom *ROM) *Mapper118 {
+	return &Mapper118{
+		Mapper4: NewMapper4(rom),
+	}
+}
+
+func (m *Mapper118) ciramPage(addr uint16) int {
+	slot := int(addr-0x2000) / 0x0400 % 4
+	return m.chrRegister(slot) >> 7 & 0x01
+}
+
+func (m *Mapper118) ConnectPPU(ciram *[2][1024]byte) PPUEvent {
+	m.ciram = ciram
+	return m.Mapper4.ConnectPPU(ciram) | EventNametableRead | EventNametableWrite
+}
+
+func (m *Mapper118) PPUAccess(a BusAccess) byte {
+	switch a.Event {
+	case EventNametableRead:
+		return m.ciram[m.ciramPage(a.Addr)][a.Addr%1024]
+	case EventNametableWrite:
+		m.ciram[m.ciramPage(a.Addr)][a.Addr%1024] = a.Data
+		return 0
+	default:
+		return m.Mapper4.PPUAccess(a)
+	}
+}
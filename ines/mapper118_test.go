@@ -0,0 +1,38 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper118_Nametables(t *testing.T) {
+	m := NewMapper118(newTestROM(118, 128*1024, 128*1024))
+	m.Reset()
+
+	var ciram [2][1024]byte
+	ciram[0][0x10] = 0xAA
+	ciram[1][0x10] = 0xBB
+
+	events := m.ConnectPPU(&ciram)
+	testutil.Equal(t, events, EventA12|EventNametableRead|EventNametableWrite)
+
+	// Bit 7 of the 2 KB banks at $0000 and $0800 selects the CIRAM page.
+	m.WritePRG(0x8000, 0x00)
+	m.WritePRG(0x8001, 0x80)
+	m.WritePRG(0x8000, 0x01)
+	m.WritePRG(0x8001, 0x00)
+	testutil.Equal(t, m.PPUAccess(BusAccess{Event: EventNametableRead, Addr: 0x2010}), 0xBB)
+	testutil.Equal(t, m.PPUAccess(BusAccess{Event: EventNametableRead, Addr: 0x2410}), 0xBB)
+	testutil.Equal(t, m.PPUAccess(BusAccess{Event: EventNametableRead, Addr: 0x2810}), 0xAA)
+	testutil.Equal(t, m.PPUAccess(BusAccess{Event: EventNametableRead, Addr: 0x2C10}), 0xAA)
+
+	m.PPUAccess(BusAccess{Event: EventNametableWrite, Addr: 0x2C10, Data: 0xCC})
+	testutil.Equal(t, ciram[0][0x10], 0xCC)
+
+	// With the CHR inversion, the 1 KB banks are used instead.
+	m.WritePRG(0x8000, 0x82)
+	m.WritePRG(0x8001, 0x80)
+	testutil.Equal(t, m.PPUAccess(BusAccess{Event: EventNametableRead, Addr: 0x2010}), 0xBB)
+	testutil.Equal(t, m.PPUAccess(BusAccess{Event: EventNametableRead, Addr: 0x2410}), 0xCC)
+}
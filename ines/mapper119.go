@@ -0,0 +1,64 @@
+package ines
+
+import (
+	"errors"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper119 implements the TQROM board used by Pin-Bot and High Speed. It is an
+// MMC3 with both 64 KB of CHR-ROM and 8 KB of CHR-RAM, and bit 6 of each CHR
+// bank number selects which of the two is mapped.
+// https://www.nesdev.org/wiki/INES_Mapper_119
+type Mapper119 struct {
+	*Mapper4
+	chrRAM [0x2000]byte
+}
+
+func NewMapper119(rom *ROM) *Mapper119 {
+	return &Mapper119{
+		Mapper4: NewMapper4(rom),
+	}
+}
+
+// ramOffset returns the offset in CHR-RAM for the given address, or -1 if the
+// address is mapped to CHR-ROM.
+func (m *Mapper119) ramOffset(addr uint16) int {
+	bank := m.chrRegister(int(addr / 0x0400))
+	if bank&0x40 == 0 {
+		return -1
+	}
+
+	return bank&0x07*0x0400 + int(addr%0x0400)
+}
+
+func (m *Mapper119) ReadCHR(addr uint16) byte {
+	if offset := m.ramOffset(addr); offset >= 0 {
+		return m.chrRAM[offset]
+	}
+
+	return m.Mapper4.ReadCHR(addr)
+}
+
+func (m *Mapper119) WriteCHR(addr uint16, data byte) {
+	if offset := m.ramOffset(addr); offset >= 0 {
+		m.chrRAM[offset] = data
+		return
+	}
+
+	m.Mapper4.WriteCHR(addr, data)
+}
+
+func (m *Mapper119) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.Mapper4.SaveState(w),
+		w.WriteByteSlice(m.chrRAM[:]),
+	)
+}
+
+func (m *Mapper119) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		m.Mapper4.LoadState(r),
+		r.ReadByteSliceTo(m.chrRAM[:]),
+	)
+}
@@ -0,0 +1,31 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper119_CHR(t *testing.T) {
+	m := NewMapper119(newTestROM(119, 128*1024, 64*1024))
+	m.Reset()
+
+	// Bit 6 of the bank number selects the CHR-RAM.
+	m.WritePRG(0x8000, 0x02)
+	m.WritePRG(0x8001, 0x41)
+	m.WritePRG(0x8000, 0x03)
+	m.WritePRG(0x8001, 0x05)
+
+	m.WriteCHR(0x1000, 0xAA)
+	testutil.Equal(t, m.ReadCHR(0x1000), 0xAA)
+	testutil.Equal(t, m.ReadCHR(0x1400), 5)
+
+	// The CHR-ROM is not writable.
+	m.WriteCHR(0x1400, 0xBB)
+	testutil.Equal(t, m.ReadCHR(0x1400), 5)
+
+	// The RAM has eight 1 KB banks, selected by the low three bits.
+	m.WritePRG(0x8000, 0x03)
+	m.WritePRG(0x8001, 0x49)
+	testutil.Equal(t, m.ReadCHR(0x1400), 0xAA)
+}
@@ -0,0 +1,126 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper225 implements the ET-4310/K-1010 multicart boards (mapper 225), and
+// the same board without the extra RAM (mapper 255). All the bank numbers are
+// latched from the address bits of a write to $8000-$FFFF, with bit 14 being
+// the outer bank, which selects the second half of the bigger carts.
+// https://www.nesdev.org/wiki/INES_Mapper_225
+type Mapper225 struct {
+	rom     *ROM
+	ram     [4]byte // 4-bit registers at $5800-$5FFF
+	latch   uint16  // address of the last write
+	prgBank [2]int
+	chrBank int
+}
+
+func NewMapper225(rom *ROM) *Mapper225 {
+	return &Mapper225{
+		rom: rom,
+	}
+}
+
+func (m *Mapper225) Reset() {
+	m.latch = 0
+	m.updateBanks()
+}
+
+func (m *Mapper225) updateBanks() {
+	var (
+		outer = int(m.latch>>14) & 0x01
+		prg   = outer<<6 | int(m.latch>>6)&0x3F // 16 KB
+		chr   = outer<<6 | int(m.latch)&0x3F    // 8 KB
+	)
+
+	if m.latch&0x1000 != 0 {
+		m.prgBank[0] = prg * 0x4000
+		m.prgBank[1] = prg * 0x4000
+	} else {
+		m.prgBank[0] = (prg &^ 1) * 0x4000
+		m.prgBank[1] = (prg | 1) * 0x4000
+	}
+
+	for i := range m.prgBank {
+		m.prgBank[i] %= len(m.rom.PRG)
+	}
+
+	m.chrBank = chr * 0x2000 % len(m.rom.CHR)
+}
+
+func (m *Mapper225) ScanlineTick() {}
+
+func (m *Mapper225) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper225) MirrorMode() MirrorMode {
+	if m.latch&0x2000 != 0 {
+		return MirrorHorizontal
+	}
+
+	return MirrorVertical
+}
+
+func (m *Mapper225) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x5800 && addr <= 0x5FFF:
+		return m.ram[addr&0x03]
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x4000
+		offset := int(addr % 0x4000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper225) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x5800 && addr <= 0x5FFF:
+		m.ram[addr&0x03] = data & 0x0F
+	case addr >= 0x8000:
+		m.latch = addr
+		m.updateBanks()
+	default:
+		log.Printf("[WARN] mapper225: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper225) ReadCHR(addr uint16) byte {
+	return m.rom.CHR[m.chrBank+int(addr)]
+}
+
+func (m *Mapper225) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper225: write to read-only chr at %04X", addr)
+		return
+	}
+
+	m.rom.CHR[m.chrBank+int(addr)] = data
+}
+
+func (m *Mapper225) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.ram[:]),
+		w.WriteUint16(m.latch),
+	)
+}
+
+func (m *Mapper225) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.ram[:]),
+		r.ReadUint16To(&m.latch),
+	)
+
+	m.updateBanks()
+
+	return err
+}
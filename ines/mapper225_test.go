@@ -0,0 +1,39 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper225_Banks(t *testing.T) {
+	rom := newTestROM(225, 2*1024*1024, 1024*1024)
+	rom.CHR[67*0x2000] = 0xEE // the bank number does not fit in a byte
+
+	m := NewMapper225(rom)
+	m.Reset()
+
+	// 16 KB mode, PRG bank 5, CHR bank 3, horizontal mirroring.
+	m.WritePRG(0x8000|0x3000|5<<6|3, 0)
+	testutil.Equal(t, m.ReadPRG(0x8000), 10)
+	testutil.Equal(t, m.ReadPRG(0xC000), 10)
+	testutil.Equal(t, m.ReadCHR(0x0000), 24)
+	testutil.Equal(t, m.MirrorMode(), MirrorHorizontal)
+
+	// A14 selects the second 1 MB of PRG and 512 KB of CHR.
+	m.WritePRG(0xC000|5<<6|3, 0)
+	testutil.Equal(t, m.ReadPRG(0x8000), 136)
+	testutil.Equal(t, m.ReadPRG(0xC000), 138)
+	testutil.Equal(t, m.ReadCHR(0x0000), 0xEE)
+	testutil.Equal(t, m.MirrorMode(), MirrorVertical)
+}
+
+func TestMapper225_RAM(t *testing.T) {
+	m := NewMapper225(newTestROM(225, 2*1024*1024, 1024*1024))
+	m.Reset()
+
+	// Four 4-bit registers mirrored across $5800-$5FFF.
+	m.WritePRG(0x5801, 0xAB)
+	testutil.Equal(t, m.ReadPRG(0x5801), 0x0B)
+	testutil.Equal(t, m.ReadPRG(0x5FFD), 0x0B)
+}
@@ -0,0 +1,111 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper226 implements the 76-in-1 and 1200-in-1 multicart boards. There are
+// two registers at even and odd addresses, and the one at odd addresses holds
+// the outer bank bit, which selects one of the 1 MB halves of the bigger carts.
+// https://www.nesdev.org/wiki/INES_Mapper_226
+type Mapper226 struct {
+	rom     *ROM
+	regs    [2]byte
+	prgBank [2]int
+}
+
+func NewMapper226(rom *ROM) *Mapper226 {
+	return &Mapper226{
+		rom: rom,
+	}
+}
+
+func (m *Mapper226) Reset() {
+	m.regs = [2]byte{}
+	m.updateBanks()
+}
+
+func (m *Mapper226) updateBanks() {
+	bank := int(m.regs[0]&0x1F) | int(m.regs[0]&0x80)>>2 | int(m.regs[1]&0x01)<<6 // 16 KB
+
+	if m.regs[0]&0x20 != 0 {
+		m.prgBank[0] = bank * 0x4000
+		m.prgBank[1] = bank * 0x4000
+	} else {
+		m.prgBank[0] = (bank &^ 1) * 0x4000
+		m.prgBank[1] = (bank | 1) * 0x4000
+	}
+
+	for i := range m.prgBank {
+		m.prgBank[i] %= len(m.rom.PRG)
+	}
+}
+
+func (m *Mapper226) ScanlineTick() {}
+
+func (m *Mapper226) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper226) MirrorMode() MirrorMode {
+	if m.regs[0]&0x40 != 0 {
+		return MirrorVertical
+	}
+
+	return MirrorHorizontal
+}
+
+func (m *Mapper226) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x4000
+		offset := int(addr % 0x4000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper226) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x8000:
+		m.regs[addr&0x01] = data
+		m.updateBanks()
+	default:
+		log.Printf("[WARN] mapper226: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper226) ReadCHR(addr uint16) byte {
+	return m.rom.CHR[addr]
+}
+
+func (m *Mapper226) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper226: write to read-only chr at %04X", addr)
+		return
+	}
+
+	m.rom.CHR[addr] = data
+}
+
+func (m *Mapper226) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.regs[:]),
+	)
+}
+
+func (m *Mapper226) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.regs[:]),
+	)
+
+	m.updateBanks()
+
+	return err
+}
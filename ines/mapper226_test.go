@@ -0,0 +1,26 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper226_Banks(t *testing.T) {
+	m := NewMapper226(newTestROM(226, 2*1024*1024, 0x2000))
+	m.Reset()
+
+	// 16 KB mode, bank 5, vertical mirroring.
+	m.WritePRG(0x8000, 0x65)
+	testutil.Equal(t, m.ReadPRG(0x8000), 10)
+	testutil.Equal(t, m.ReadPRG(0xC000), 10)
+	testutil.Equal(t, m.MirrorMode(), MirrorVertical)
+
+	// 32 KB mode, bit 7 is bank bit 5, and the register at odd addresses
+	// selects the second 1 MB.
+	m.WritePRG(0x8000, 0x85)
+	m.WritePRG(0x8001, 0x01)
+	testutil.Equal(t, m.ReadPRG(0x8000), 200)
+	testutil.Equal(t, m.ReadPRG(0xC000), 202)
+	testutil.Equal(t, m.MirrorMode(), MirrorHorizontal)
+}
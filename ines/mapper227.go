@@ -0,0 +1,146 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper227 implements the 1200-in-1 multicart board. Like mapper 225, it
+// latches the address of the write to $8000-$FFFF. Besides the usual 16/32 KB
+// modes, it has an UNROM-like mode, where the bank at $C000 is fixed to either
+// the first or the last bank of the 128 KB outer bank.
+// https://www.nesdev.org/wiki/INES_Mapper_227
+type Mapper227 struct {
+	rom     *ROM
+	sram    []byte
+	latch   uint16
+	prgBank [2]int
+}
+
+func NewMapper227(rom *ROM) *Mapper227 {
+	return &Mapper227{
+		rom:  rom,
+		sram: rom.newPRGRAM(),
+	}
+}
+
+func (m *Mapper227) Reset() {
+	m.latch = 0
+	m.updateBanks()
+}
+
+func (m *Mapper227) updateBanks() {
+	var (
+		bank   = int(m.latch>>2)&0x1F | int(m.latch&0x100)>>3 // 16 KB
+		size32 = m.latch&0x01 != 0
+		last   = m.latch&0x200 != 0
+	)
+
+	var lo, hi int
+
+	switch {
+	case m.latch&0x80 != 0 && size32:
+		lo, hi = bank&^1, bank|1
+	case m.latch&0x80 != 0:
+		lo, hi = bank, bank
+	default:
+		if lo = bank; size32 {
+			lo &^= 1
+		}
+
+		// The bank at $C000 is fixed within the outer bank.
+		if hi = bank & 0x38; last {
+			hi |= 0x07
+		}
+	}
+
+	m.prgBank[0] = lo * 0x4000 % len(m.rom.PRG)
+	m.prgBank[1] = hi * 0x4000 % len(m.rom.PRG)
+}
+
+func (m *Mapper227) ScanlineTick() {}
+
+func (m *Mapper227) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper227) MirrorMode() MirrorMode {
+	if m.latch&0x02 != 0 {
+		return MirrorHorizontal
+	}
+
+	return MirrorVertical
+}
+
+func (m *Mapper227) ReadPRG(addr uint16) byte {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) == 0 {
+			return 0
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case addr >= 0x8000:
+		bank := (addr - 0x8000) / 0x4000
+		offset := int(addr % 0x4000)
+		return m.rom.PRG[m.prgBank[bank]+offset]
+	default:
+		return 0 // open bus
+	}
+}
+
+func (m *Mapper227) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x6000 && addr <= 0x7FFF:
+		if len(m.sram) > 0 {
+			m.sram[int(addr-0x6000)%len(m.sram)] = data
+		}
+	case addr >= 0x8000:
+		m.latch = addr
+		m.updateBanks()
+	default:
+		log.Printf("[WARN] mapper227: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper227) ReadCHR(addr uint16) byte {
+	return m.rom.CHR[addr]
+}
+
+func (m *Mapper227) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper227: write to read-only chr at %04X", addr)
+		return
+	}
+
+	m.rom.CHR[addr] = data
+}
+
+func (m *Mapper227) BatteryRAM() []byte {
+	if !m.rom.Battery {
+		return nil
+	}
+
+	return m.sram
+}
+
+func (m *Mapper227) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteByteSlice(m.sram),
+		w.WriteUint16(m.latch),
+	)
+}
+
+func (m *Mapper227) LoadState(r *binario.Reader) error {
+	err := errors.Join(
+		m.rom.LoadState(r),
+		r.ReadByteSliceTo(m.sram),
+		r.ReadUint16To(&m.latch),
+	)
+
+	m.updateBanks()
+
+	return err
+}
@@ -0,0 +1,31 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper227_Banks(t *testing.T) {
+	tests := map[string]struct {
+		addr   uint16
+		lo, hi byte // 8 KB banks at $8000 and $C000
+	}{
+		"NROM-128":   {addr: 0x8080 | 3<<2, lo: 6, hi: 6},
+		"NROM-256":   {addr: 0x8081 | 3<<2, lo: 4, hi: 6},
+		"UNROM":      {addr: 0x8000 | 0x0B<<2, lo: 22, hi: 16},
+		"UNROM last": {addr: 0x8200 | 0x0B<<2, lo: 22, hi: 30},
+		"outer bank": {addr: 0x8300 | 0x0B<<2, lo: 86, hi: 94},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := NewMapper227(newTestROM(227, 1024*1024, 0x2000))
+			m.Reset()
+			m.WritePRG(tt.addr, 0)
+
+			testutil.Equal(t, m.ReadPRG(0x8000), tt.lo)
+			testutil.Equal(t, m.ReadPRG(0xC000), tt.hi)
+		})
+	}
+}
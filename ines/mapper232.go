@@ -0,0 +1,102 @@
+package ines
+
+import (
+	"errors"
+	"log"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// Mapper232 implements the Camerica BF9096 board, used by the Quattro
+// multicarts. The outer register at $8000-$BFFF selects a 64 KB block, in which
+// the inner register at $C000-$FFFF switches 16 KB banks UxROM-style. On the
+// Aladdin Deck Enhancer version (submapper 1), the outer bank bits are swapped.
+// https://www.nesdev.org/wiki/INES_Mapper_232
+type Mapper232 struct {
+	rom     *ROM
+	aladdin bool
+	outer   uint8
+	inner   uint8
+}
+
+func NewMapper232(rom *ROM) *Mapper232 {
+	return &Mapper232{
+		rom:     rom,
+		aladdin: rom.Submapper == 1,
+	}
+}
+
+func (m *Mapper232) Reset() {
+	m.outer = 0
+	m.inner = 0
+}
+
+func (m *Mapper232) ScanlineTick() {}
+
+func (m *Mapper232) PendingIRQ() bool {
+	return false
+}
+
+func (m *Mapper232) MirrorMode() MirrorMode {
+	return m.rom.MirrorMode
+}
+
+func (m *Mapper232) ReadPRG(addr uint16) byte {
+	var bank int
+
+	switch {
+	case addr >= 0x8000 && addr <= 0xBFFF:
+		bank = int(m.outer)<<2 | int(m.inner)
+	case addr >= 0xC000:
+		bank = int(m.outer)<<2 | 0x03
+	default:
+		return 0 // open bus
+	}
+
+	idx := bank*0x4000 + int(addr%0x4000)
+	return m.rom.PRG[idx%len(m.rom.PRG)]
+}
+
+func (m *Mapper232) WritePRG(addr uint16, data byte) {
+	switch {
+	case addr >= 0x8000 && addr <= 0xBFFF:
+		if m.aladdin {
+			m.outer = (data>>4)&0x01 | (data>>2)&0x02
+		} else {
+			m.outer = (data >> 3) & 0x03
+		}
+	case addr >= 0xC000:
+		m.inner = data & 0x03
+	default:
+		log.Printf("[WARN] mapper232: unhandled prg write at %04X", addr)
+	}
+}
+
+func (m *Mapper232) ReadCHR(addr uint16) byte {
+	return m.rom.CHR[addr]
+}
+
+func (m *Mapper232) WriteCHR(addr uint16, data byte) {
+	if !m.rom.chrRAM {
+		log.Printf("[WARN] mapper232: write to read-only chr at %04X", addr)
+		return
+	}
+
+	m.rom.CHR[addr] = data
+}
+
+func (m *Mapper232) SaveState(w *binario.Writer) error {
+	return errors.Join(
+		m.rom.SaveState(w),
+		w.WriteUint8(m.outer),
+		w.WriteUint8(m.inner),
+	)
+}
+
+func (m *Mapper232) LoadState(r *binario.Reader) error {
+	return errors.Join(
+		m.rom.LoadState(r),
+		r.ReadUint8To(&m.outer),
+		r.ReadUint8To(&m.inner),
+	)
+}
@@ -0,0 +1,33 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestMapper232_Banks(t *testing.T) {
+	tests := map[string]struct {
+		submapper uint8
+		lo, hi    byte // 8 KB banks at $8000 and $C000
+	}{
+		"quattro": {submapper: 0, lo: 18, hi: 22},
+		"aladdin": {submapper: 1, lo: 10, hi: 14},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rom := newTestROM(232, 256*1024, 0x2000)
+			rom.Submapper = tt.submapper
+
+			m := NewMapper232(rom)
+			m.Reset()
+
+			// The outer bank bits are swapped on the Aladdin Deck Enhancer.
+			m.WritePRG(0x8000, 0x10)
+			m.WritePRG(0xC000, 0x01)
+			testutil.Equal(t, m.ReadPRG(0x8000), tt.lo)
+			testutil.Equal(t, m.ReadPRG(0xC000), tt.hi)
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package ines
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+var (
+	ErrInvalidPatch  = errors.New("invalid patch file")
+	ErrPatchChecksum = errors.New("patch checksum mismatch (probably a different rom)")
+)
+
+// PatchExtensions are the file extensions of the supported patch formats.
+var PatchExtensions = []string{".ips", ".ups", ".bps"}
+
+// ApplyPatch applies an IPS, UPS or BPS patch to the raw ROM image (with the
+// header) and returns the patched copy. The format is detected by the magic
+// string at the beginning of the patch. UPS and BPS patches carry the CRC32 of
+// the original and the patched files, which are validated, so a patch made for
+// a different revision of the game fails with ErrPatchChecksum.
+func ApplyPatch(data, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte("PATCH")):
+		return applyIPS(data, patch)
+	case bytes.HasPrefix(patch, []byte("UPS1")):
+		return applyUPS(data, patch)
+	case bytes.HasPrefix(patch, []byte("BPS1")):
+		return applyBPS(data, patch)
+	default:
+		return nil, fmt.Errorf("%w: unknown format", ErrInvalidPatch)
+	}
+}
+
+// applyIPS applies an IPS patch, which is a list of records, each with a 24-bit
+// offset and either the data to write or a run-length encoded byte. There are
+// no checksums, so any file can be patched. The "EOF" marker can be followed
+// by the size to truncate the file to.
+// https://zerosoft.zophar.net/ips.php
+func applyIPS(data, patch []byte) ([]byte, error) {
+	out := bytes.Clone(data)
+	p := patchReader{data: patch, pos: 5}
+
+	for {
+		offset, err := p.readUint24()
+		if err != nil {
+			return nil, err
+		}
+
+		if offset == 0x454F46 { // "EOF"
+			break
+		}
+
+		size, err := p.readUint16()
+		if err != nil {
+			return nil, err
+		}
+
+		var chunk []byte
+
+		if size == 0 {
+			count, err := p.readUint16()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := p.readByte()
+			if err != nil {
+				return nil, err
+			}
+
+			chunk = bytes.Repeat([]byte{value}, int(count))
+		} else if chunk, err = p.readBytes(int(size)); err != nil {
+			return nil, err
+		}
+
+		if end := offset + len(chunk); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+
+		copy(out[offset:], chunk)
+	}
+
+	// Optional truncation extension.
+	if size, err := p.readUint24(); err == nil && size < len(out) {
+		out = out[:size]
+	}
+
+	return out, nil
+}
+
+// applyUPS applies a UPS patch, which stores the XOR difference between the
+// original and the patched files as a list of runs separated by the number of
+// unchanged bytes. The sizes and CRC32s of both files are in the patch.
+// https://www.romhacking.net/documents/392/
+func applyUPS(data, patch []byte) ([]byte, error) {
+	if err := checkPatchCRC(patch); err != nil {
+		return nil, err
+	}
+
+	p := patchReader{data: patch[:len(patch)-12], pos: 4}
+
+	sourceSize, err := p.readVarInt()
+	if err != nil {
+		return nil, err
+	}
+
+	targetSize, err := p.readVarInt()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSource(data, patch, sourceSize); err != nil {
+		return nil, err
+	}
+
+	if targetSize > maxROMSize {
+		return nil, fmt.Errorf("%w: target is too big", ErrInvalidPatch)
+	}
+
+	out := make([]byte, targetSize)
+	copy(out, data)
+
+	for pos := 0; p.remaining() > 0; {
+		skip, err := p.readVarInt()
+		if err != nil {
+			return nil, err
+		}
+
+		pos += skip
+
+		for {
+			x, err := p.readByte()
+			if err != nil {
+				return nil, err
+			}
+
+			// The terminating zero also stands for an unchanged byte.
+			if pos < len(out) {
+				out[pos] ^= x
+			}
+
+			pos++
+
+			if x == 0 {
+				break
+			}
+		}
+	}
+
+	return out, checkTarget(out, patch)
+}
+
+// applyBPS applies a BPS patch, which builds the patched file from a sequence
+// of commands copying the data either from the original file, from the patch
+// itself, or from the already written part of the output. Like UPS, it has the
+// sizes and the CRC32s of both files.
+// https://www.romhacking.net/documents/746/
+func applyBPS(data, patch []byte) ([]byte, error) {
+	const (
+		sourceRead = iota
+		targetRead
+		sourceCopy
+		targetCopy
+	)
+
+	if err := checkPatchCRC(patch); err != nil {
+		return nil, err
+	}
+
+	p := patchReader{data: patch[:len(patch)-12], pos: 4}
+
+	sourceSize, err := p.readVarInt()
+	if err != nil {
+		return nil, err
+	}
+
+	targetSize, err := p.readVarInt()
+	if err != nil {
+		return nil, err
+	}
+
+	metadataSize, err := p.readVarInt()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.readBytes(metadataSize); err != nil {
+		return nil, err
+	}
+
+	if err := checkSource(data, patch, sourceSize); err != nil {
+		return nil, err
+	}
+
+	if targetSize > maxROMSize {
+		return nil, fmt.Errorf("%w: target is too big", ErrInvalidPatch)
+	}
+
+	var (
+		out          = make([]byte, targetSize)
+		outPos       = 0
+		sourceOffset = 0
+		targetOffset = 0
+	)
+
+	for p.remaining() > 0 {
+		cmd, err := p.readVarInt()
+		if err != nil {
+			return nil, err
+		}
+
+		length := cmd>>2 + 1
+		if outPos+length > len(out) {
+			return nil, fmt.Errorf("%w: write past the end of the target", ErrInvalidPatch)
+		}
+
+		switch cmd & 0x03 {
+		case sourceRead:
+			if outPos+length > len(data) {
+				return nil, fmt.Errorf("%w: read past the end of the source", ErrInvalidPatch)
+			}
+
+			copy(out[outPos:], data[outPos:outPos+length])
+
+		case targetRead:
+			chunk, err := p.readBytes(length)
+			if err != nil {
+				return nil, err
+			}
+
+			copy(out[outPos:], chunk)
+
+		case sourceCopy, targetCopy:
+			offset, err := p.readVarInt()
+			if err != nil {
+				return nil, err
+			}
+
+			// The offset is relative to the previous copy of the same kind,
+			// with the sign in the lowest bit.
+			delta := offset >> 1
+			if offset&0x01 != 0 {
+				delta = -delta
+			}
+
+			if cmd&0x03 == sourceCopy {
+				sourceOffset += delta
+				if sourceOffset < 0 || sourceOffset+length > len(data) {
+					return nil, fmt.Errorf("%w: read past the end of the source", ErrInvalidPatch)
+				}
+
+				copy(out[outPos:], data[sourceOffset:sourceOffset+length])
+				sourceOffset += length
+			} else {
+				targetOffset += delta
+				if targetOffset < 0 || targetOffset >= outPos {
+					return nil, fmt.Errorf("%w: invalid target offset", ErrInvalidPatch)
+				}
+
+				// The ranges may overlap, which is used for run-length
+				// encoding, so the bytes are copied one by one.
+				for i := 0; i < length; i++ {
+					out[outPos+i] = out[targetOffset]
+					targetOffset++
+				}
+			}
+		}
+
+		outPos += length
+	}
+
+	return out, checkTarget(out, patch)
+}
+
+// checkPatchCRC validates the UPS/BPS footer, which has the CRC32 of the
+// source, the target and the patch itself (excluding the last 4 bytes).
+func checkPatchCRC(patch []byte) error {
+	if len(patch) < 16 {
+		return fmt.Errorf("%w: too short", ErrInvalidPatch)
+	}
+
+	want := binary.LittleEndian.Uint32(patch[len(patch)-4:])
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != want {
+		return fmt.Errorf("%w: corrupted patch", ErrInvalidPatch)
+	}
+
+	return nil
+}
+
+func checkSource(data, patch []byte, size int) error {
+	want := binary.LittleEndian.Uint32(patch[len(patch)-12:])
+	if len(data) != size || crc32.ChecksumIEEE(data) != want {
+		return ErrPatchChecksum
+	}
+
+	return nil
+}
+
+func checkTarget(out, patch []byte) error {
+	want := binary.LittleEndian.Uint32(patch[len(patch)-8:])
+	if crc32.ChecksumIEEE(out) != want {
+		return fmt.Errorf("%w: patched rom", ErrPatchChecksum)
+	}
+
+	return nil
+}
+
+// patchReader reads the patch data, returning ErrInvalidPatch when the data
+// ends unexpectedly.
+type patchReader struct {
+	data []byte
+	pos  int
+}
+
+func (p *patchReader) remaining() int {
+	return len(p.data) - p.pos
+}
+
+func (p *patchReader) readBytes(n int) ([]byte, error) {
+	if n < 0 || n > p.remaining() {
+		return nil, fmt.Errorf("%w: unexpected end of patch", ErrInvalidPatch)
+	}
+
+	b := p.data[p.pos : p.pos+n]
+	p.pos += n
+
+	return b, nil
+}
+
+func (p *patchReader) readByte() (byte, error) {
+	b, err := p.readBytes(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+func (p *patchReader) readUint16() (int, error) {
+	b, err := p.readBytes(2)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(b[0])<<8 | int(b[1]), nil
+}
+
+func (p *patchReader) readUint24() (int, error) {
+	b, err := p.readBytes(3)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2]), nil
+}
+
+// readVarInt reads the variable-length number used by UPS and BPS. Unlike the
+// usual LEB128, every continuation adds one to the next group, so that each
+// number has exactly one encoding.
+func (p *patchReader) readVarInt() (int, error) {
+	var (
+		value = 0
+		shift = 1
+	)
+
+	for {
+		x, err := p.readByte()
+		if err != nil {
+			return 0, err
+		}
+
+		value += int(x&0x7F) * shift
+		if x&0x80 != 0 {
+			break
+		}
+
+		if shift >= 1<<42 {
+			return 0, fmt.Errorf("%w: number is too big", ErrInvalidPatch)
+		}
+
+		shift <<= 7
+		value += shift
+	}
+
+	return value, nil
+}
@@ -0,0 +1,131 @@
+package ines
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func patchVarInt(v int) []byte {
+	var out []byte
+
+	for {
+		x := byte(v & 0x7F)
+		if v >>= 7; v == 0 {
+			return append(out, 0x80|x)
+		}
+
+		out = append(out, x)
+		v--
+	}
+}
+
+// patchFooter appends the source, target and patch CRC32s used by UPS and BPS.
+func patchFooter(patch, source, target []byte) []byte {
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(source))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+}
+
+func TestApplyPatch_IPS(t *testing.T) {
+	source := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+
+	patch := []byte("PATCH")
+	patch = append(patch, 0x00, 0x00, 0x02, 0x00, 0x02, 0xAA, 0xBB)       // 2 bytes at $02
+	patch = append(patch, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x04, 0xCC) // RLE at $06, past the end
+	patch = append(patch, "EOF"...)
+
+	out, err := ApplyPatch(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, string(out), string([]byte{0, 1, 0xAA, 0xBB, 4, 5, 0xCC, 0xCC, 0xCC, 0xCC}))
+
+	// Truncation extension.
+	out, err = ApplyPatch(source, append(bytes.Clone(patch), 0x00, 0x00, 0x04))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, string(out), string([]byte{0, 1, 0xAA, 0xBB}))
+}
+
+func TestApplyPatch_UPS(t *testing.T) {
+	var (
+		source = []byte{0, 1, 2, 3, 4, 5, 6, 7}
+		target = []byte{0, 1, 2, 0xAA, 4, 5, 6, 7, 0xBB, 0xCC}
+	)
+
+	patch := []byte("UPS1")
+	patch = append(patch, patchVarInt(len(source))...)
+	patch = append(patch, patchVarInt(len(target))...)
+	patch = append(patch, patchVarInt(3)...)
+	patch = append(patch, 3^0xAA, 0x00)
+	patch = append(patch, patchVarInt(3)...)
+	patch = append(patch, 0xBB, 0xCC, 0x00)
+	patch = patchFooter(patch, source, target)
+
+	out, err := ApplyPatch(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, string(out), string(target))
+
+	_, err = ApplyPatch([]byte{7, 6, 5, 4, 3, 2, 1, 0}, patch)
+	testutil.Equal(t, errors.Is(err, ErrPatchChecksum), true)
+}
+
+func TestApplyPatch_BPS(t *testing.T) {
+	var (
+		source = []byte("ABCDEFGH")
+		target = []byte("ABCDxyGHEFEFEFEF")
+	)
+
+	patch := []byte("BPS1")
+	patch = append(patch, patchVarInt(len(source))...)
+	patch = append(patch, patchVarInt(len(target))...)
+	patch = append(patch, patchVarInt(0)...) // no metadata
+
+	// "ABCD" from the source.
+	patch = append(patch, patchVarInt(3<<2|0)...)
+
+	// "xy" from the patch.
+	patch = append(patch, patchVarInt(1<<2|1)...)
+	patch = append(patch, "xy"...)
+
+	// "GH" from the source at the same offset.
+	patch = append(patch, patchVarInt(1<<2|0)...)
+
+	// "EF" from the source at offset 4.
+	patch = append(patch, patchVarInt(1<<2|2)...)
+	patch = append(patch, patchVarInt(4<<1)...)
+
+	// "EFEFEF" from the output at offset 8, overlapping with itself.
+	patch = append(patch, patchVarInt(5<<2|3)...)
+	patch = append(patch, patchVarInt(8<<1)...)
+	patch = patchFooter(patch, source, target)
+
+	out, err := ApplyPatch(source, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, string(out), string(target))
+
+	_, err = ApplyPatch([]byte("HGFEDCBA"), patch)
+	testutil.Equal(t, errors.Is(err, ErrPatchChecksum), true)
+}
+
+func TestApplyPatch_Invalid(t *testing.T) {
+	_, err := ApplyPatch(nil, []byte("PATCH\x00\x00"))
+	testutil.Equal(t, errors.Is(err, ErrInvalidPatch), true)
+
+	_, err = ApplyPatch(nil, []byte("something"))
+	testutil.Equal(t, errors.Is(err, ErrInvalidPatch), true)
+}
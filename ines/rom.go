@@ -7,8 +7,8 @@ import (
 	"hash/crc32"
 	"io"
 	"log"
-	"os"
 
+	"github.com/maxpoletaev/dendy/consts"
 	"github.com/maxpoletaev/dendy/internal/binario"
 )
 
@@ -23,72 +23,231 @@ const (
 	MirrorVertical   MirrorMode = 1
 	MirrorSingle0    MirrorMode = 2
 	MirrorSingle1    MirrorMode = 3
+	MirrorFourScreen MirrorMode = 4 // extra 2 KB of VRAM on the cartridge
 )
 
-var mapperNames = map[uint8]string{
-	0: "NROM",
-	1: "SxROM",
-	2: "UxROM",
-	3: "CNROM",
-	4: "TxROM",
-	7: "AxROM",
+var mapperNames = map[uint16]string{
+	0:   "NROM",
+	1:   "SxROM",
+	2:   "UxROM",
+	3:   "CNROM",
+	4:   "TxROM",
+	5:   "ExROM",
+	7:   "AxROM",
+	9:   "MMC2",
+	10:  "MMC4",
+	11:  "Color Dreams",
+	15:  "K-1029",
+	16:  "Bandai FCG",
+	19:  "Namco 163",
+	20:  "FDS",
+	21:  "VRC4a/VRC4c",
+	22:  "VRC2a",
+	23:  "VRC2b/VRC4e/VRC4f",
+	24:  "VRC6a",
+	25:  "VRC2c/VRC4b/VRC4d",
+	26:  "VRC6b",
+	34:  "BNROM/NINA-001",
+	66:  "GxROM",
+	69:  "FME-7",
+	71:  "Camerica",
+	79:  "NINA-03/06",
+	85:  "VRC7",
+	90:  "J.Y. Company",
+	91:  "J.Y. Company",
+	118: "TxSROM",
+	119: "TQROM",
+	153: "Bandai LZ93D50",
+	159: "Bandai LZ93D50",
+	209: "J.Y. Company",
+	211: "J.Y. Company",
+	225: "ET-4310",
+	226: "BMC 76-in-1",
+	227: "BMC 1200-in-1",
+	232: "Camerica Quattro",
+	255: "BMC 110-in-1",
 }
 
+// ConsoleType is the type of the console the ROM was made for, as stored in
+// the NES 2.0 header. Values above 2 are the extended console types from byte 13.
+type ConsoleType uint8
+
+const (
+	ConsoleNES        ConsoleType = 0
+	ConsoleVsSystem   ConsoleType = 1
+	ConsolePlaychoice ConsoleType = 2
+)
+
+// ExpansionDevice is the default input device specified in the NES 2.0 header.
+// Only the most common values are named here, the rest are kept as is.
+// https://www.nesdev.org/wiki/NES_2.0#Default_Expansion_Device
+type ExpansionDevice uint8
+
+const (
+	ExpansionUnspecified ExpansionDevice = 0x00
+	ExpansionStandard    ExpansionDevice = 0x01
+	ExpansionFourScore   ExpansionDevice = 0x02
+	ExpansionFourPlayers ExpansionDevice = 0x03
+	ExpansionZapper      ExpansionDevice = 0x08
+)
+
 type ROM struct {
-	MirrorMode MirrorMode
-	MapperID   uint8
-	Battery    bool
-	PRGBanks   int
-	CHRBanks   int
-	PRG        []byte
-	CHR        []byte
-	CRC32      uint32
-	chrRAM     bool
+	MirrorMode      MirrorMode
+	MapperID        uint16
+	Submapper       uint8
+	Battery         bool
+	PRGBanks        int
+	CHRBanks        int
+	PRG             []byte
+	CHR             []byte
+	PRGRAMSize      int
+	PRGNVRAMSize    int
+	CHRRAMSize      int
+	CHRNVRAMSize    int
+	CRC32           uint32
+	Region          consts.Region
+	ConsoleType     ConsoleType
+	ExpansionDevice ExpansionDevice
+	NES2            bool
+	Disk            [][]byte // FDS disk sides, see NewFromFDSFile
+	Title           string   // from the game database, if known
+	Trainer         []byte   // 512 bytes loaded at $7000, if present
+	chrRAM          bool
+	trainerLoaded   bool // set by newPRGRAM
 }
 
 func NewFromBuffer(buf []byte) (*ROM, error) {
 	return newROM(bytes.NewReader(buf))
 }
 
+// NewFromFile loads an iNES or UNIF file, which can also be compressed with
+// gzip or put in a zip archive (see ReadROMFile).
 func NewFromFile(filename string) (*ROM, error) {
-	file, err := os.Open(filename)
+	data, _, err := ReadROMFile(filename, "")
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() {
-		_ = file.Close()
-	}()
+	return NewFromBuffer(data)
+}
+
+// nes2RAMSize decodes a NES 2.0 RAM size shift count (64 << shift bytes).
+func nes2RAMSize(shift uint8) int {
+	if shift == 0 {
+		return 0
+	}
+
+	return 64 << shift
+}
+
+// nes2ROMSize decodes a NES 2.0 ROM size from the LSB and MSB nibble. When the
+// nibble is $F, the size is stored in the exponent-multiplier notation instead.
+func nes2ROMSize(lsb, msb uint8, unit int) int {
+	if msb == 0x0F {
+		exponent := int(lsb >> 2)
+		multiplier := int(lsb&0x03)*2 + 1
+		return (1 << exponent) * multiplier
+	}
 
-	return newROM(file)
+	return (int(msb)<<8 | int(lsb)) * unit
 }
 
 func newROM(file io.ReadSeeker) (*ROM, error) {
 	// Read header.
 	header := make([]uint8, 16)
-	_, err := file.Read(header)
-	if err != nil {
+	if _, err := io.ReadFull(file, header); err != nil {
 		return nil, err
 	}
 
+	// UNIF files have their own header, so start over.
+	if bytes.Equal(header[:4], []byte("UNIF")) {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		return newUNIFROM(file)
+	}
+
 	// Check header signature.
 	if header[0] != 'N' || header[1] != 'E' || header[2] != 'S' || header[3] != 0x1A {
 		return nil, errors.New("invalid ROM file")
 	}
 
+	rom := &ROM{
+		MapperID:   uint16(header[6] >> 4),
+		Battery:    header[6]&(1<<1) != 0,
+		MirrorMode: header[6] & (1 << 0),
+		Region:     consts.RegionNTSC,
+		NES2:       header[7]&0x0C == 0x08,
+	}
+
 	var (
-		mapperID   = ((header[6] >> 4) & 0x0F) | (header[7] & 0xF0)
-		prgBanks   = int(header[4])
-		chrBanks   = int(header[5])
 		hasTrainer = header[6]&(1<<2) != 0
-		hasBattery = header[6]&(1<<1) != 0
-		mirrorMode = header[6] & (1 << 0)
+		fourScreen = header[6]&(1<<3) != 0
+		prgSize    = int(header[4]) * 16384
+		chrSize    = int(header[5]) * 8192
+		noPadding  = bytes.Equal(header[12:16], []byte{0, 0, 0, 0})
 	)
 
-	// Skip trainer if present.
+	switch {
+	case rom.NES2:
+		rom.MapperID |= uint16(header[7]&0xF0) | uint16(header[8]&0x0F)<<8
+		rom.Submapper = header[8] >> 4
+		prgSize = nes2ROMSize(header[4], header[9]&0x0F, 16384)
+		chrSize = nes2ROMSize(header[5], header[9]>>4, 8192)
+		rom.PRGRAMSize = nes2RAMSize(header[10] & 0x0F)
+		rom.PRGNVRAMSize = nes2RAMSize(header[10] >> 4)
+		rom.CHRRAMSize = nes2RAMSize(header[11] & 0x0F)
+		rom.CHRNVRAMSize = nes2RAMSize(header[11] >> 4)
+		rom.ExpansionDevice = ExpansionDevice(header[15] & 0x3F)
+
+		switch header[12] & 0x03 {
+		case 1:
+			rom.Region = consts.RegionPAL
+		case 3:
+			rom.Region = consts.RegionDendy
+		default: // NTSC or multi-region, which are best played as NTSC.
+			rom.Region = consts.RegionNTSC
+		}
+
+		rom.ConsoleType = ConsoleType(header[7] & 0x03)
+		if rom.ConsoleType == 3 {
+			rom.ConsoleType = ConsoleType(header[13] & 0x0F)
+		}
+
+	case noPadding:
+		// Old dumping tools used to put their signature in bytes 7-15, so only
+		// trust the upper mapper nibble and the TV system bit if the padding is zero.
+		rom.MapperID |= uint16(header[7] & 0xF0)
+		rom.ConsoleType = ConsoleType(header[7] & 0x03)
+		if header[9]&0x01 != 0 {
+			rom.Region = consts.RegionPAL
+		}
+	}
+
+	if !rom.NES2 {
+		// iNES 1.0 has no reliable way to specify the RAM size, so assume that
+		// every board has 8 KB of PRG-RAM, which is what most emulators do.
+		if rom.Battery {
+			rom.PRGNVRAMSize = 0x2000
+		} else {
+			rom.PRGRAMSize = 0x2000
+		}
+
+		if chrSize == 0 {
+			rom.CHRRAMSize = 0x2000
+		}
+	}
+
+	if fourScreen {
+		rom.MirrorMode = MirrorFourScreen
+	}
+
+	// The trainer is not included in the CRC32.
 	if hasTrainer {
-		if _, err = file.Seek(512, io.SeekCurrent); err != nil {
-			return nil, fmt.Errorf("failed to skip trainer: %w", err)
+		rom.Trainer = make([]byte, 512)
+		if _, err := io.ReadFull(file, rom.Trainer); err != nil {
+			return nil, fmt.Errorf("failed to read trainer: %w", err)
 		}
 	}
 
@@ -97,41 +256,81 @@ func newROM(file io.ReadSeeker) (*ROM, error) {
 	romReader := io.TeeReader(file, hasher)
 
 	// Read PRG-ROM.
-	prgData := make([]uint8, prgBanks*16384)
-	if _, err = romReader.Read(prgData); err != nil {
+	rom.PRG = make([]uint8, prgSize)
+	if _, err := io.ReadFull(romReader, rom.PRG); err != nil {
 		return nil, fmt.Errorf("failed to read PRG ROM: %w", err)
 	}
 
 	// Read CHR-ROM.
-	chrData := make([]uint8, chrBanks*8192)
-	if _, err = romReader.Read(chrData); err != nil && err != io.EOF {
-		return nil, fmt.Errorf("failed to read chr ROM: %w", err)
+	rom.CHR = make([]uint8, chrSize)
+	if _, err := io.ReadFull(romReader, rom.CHR); err != nil {
+		return nil, fmt.Errorf("failed to read CHR ROM: %w", err)
+	}
+
+	if len(rom.CHR) == 0 {
+		// No CHR-ROM, so allocate CHR-RAM instead. Mappers address the full
+		// 8 KB pattern table window, so it cannot be smaller than that.
+		rom.CHR = make([]uint8, max(rom.CHRRAMSize+rom.CHRNVRAMSize, 0x2000))
+		rom.chrRAM = true
 	}
 
-	var chrRAM bool
-	if len(chrData) == 0 {
-		// No CHR-ROM, so allocate 8KB of CHR-RAM.
-		chrData = make([]uint8, 8192)
-		chrRAM = true
+	rom.PRGBanks = (len(rom.PRG) + 0x3FFF) / 0x4000
+	rom.CHRBanks = chrSize / 0x2000
+	rom.CRC32 = hasher.Sum32()
+	rom.applyGameDB()
+
+	format := "iNES"
+	if rom.NES2 {
+		format = "NES 2.0"
 	}
 
 	log.Printf("[INFO] ROM info:")
-	log.Printf("[INFO]   > mapper ID:  %d (%s)", mapperID, mapperNames[mapperID])
-	log.Printf("[INFO]   > PRG banks:  %d (%d KB)", prgBanks, prgBanks*16)
-	log.Printf("[INFO]   > CHR banks:  %d (%d KB)", chrBanks, chrBanks*8)
-	log.Printf("[INFO]   > CRC32:      %08X", hasher.Sum32())
-
-	return &ROM{
-		PRG:        prgData,
-		CHR:        chrData,
-		MapperID:   mapperID,
-		Battery:    hasBattery,
-		MirrorMode: mirrorMode,
-		PRGBanks:   prgBanks,
-		CHRBanks:   chrBanks,
-		chrRAM:     chrRAM,
-		CRC32:      hasher.Sum32(),
-	}, nil
+	log.Printf("[INFO]   > format:     %s", format)
+	log.Printf("[INFO]   > mapper ID:  %d.%d (%s)", rom.MapperID, rom.Submapper, mapperNames[rom.MapperID])
+	log.Printf("[INFO]   > PRG banks:  %d (%d KB)", rom.PRGBanks, len(rom.PRG)/1024)
+	log.Printf("[INFO]   > CHR banks:  %d (%d KB)", rom.CHRBanks, chrSize/1024)
+	log.Printf("[INFO]   > PRG-RAM:    %d KB (%d KB battery)", rom.prgRAMSize()/1024, rom.PRGNVRAMSize/1024)
+	log.Printf("[INFO]   > region:     %s", rom.Region)
+	log.Printf("[INFO]   > CRC32:      %08X", rom.CRC32)
+
+	return rom, nil
+}
+
+// prgRAMSize returns the combined size of the volatile and battery-backed PRG-RAM.
+func (r *ROM) prgRAMSize() int {
+	return r.PRGRAMSize + r.PRGNVRAMSize
+}
+
+// newPRGRAM allocates the PRG-RAM mapped at $6000-$7FFF, with the trainer
+// loaded at $7000 if there is one.
+func (r *ROM) newPRGRAM() []byte {
+	size := r.prgRAMSize()
+	if r.Trainer != nil {
+		size = max(size, 0x2000)
+	}
+
+	ram := make([]byte, size)
+	if r.Trainer != nil {
+		copy(ram[0x1000:], r.Trainer)
+		r.trainerLoaded = true
+	}
+
+	return ram
+}
+
+// busConflicts tells whether the writes to the mapper registers of a discrete
+// logic board conflict with the PRG-ROM output, in which case the written value
+// is ANDed with the byte in ROM. NES 2.0 submapper 1 means no conflicts and 2
+// means conflicts, otherwise the board's usual behavior is assumed.
+func (r *ROM) busConflicts(usual bool) bool {
+	switch r.Submapper {
+	case 1:
+		return false
+	case 2:
+		return true
+	default:
+		return usual
+	}
 }
 
 func (r *ROM) SaveState(w *binario.Writer) error {
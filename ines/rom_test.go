@@ -0,0 +1,148 @@
+package ines
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/consts"
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestNewFromBuffer_Trainer(t *testing.T) {
+	var (
+		header  = []byte{'N', 'E', 'S', 0x1A, 1, 1, 0x0C, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		trainer = bytes.Repeat([]byte{0xEE}, 512)
+		prg     = bytes.Repeat([]byte{0xAA}, 0x4000)
+		chr     = bytes.Repeat([]byte{0xCC}, 0x2000)
+	)
+
+	rom, err := NewFromBuffer(bytes.Join([][]byte{header, trainer, prg, chr}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, rom.MirrorMode, MirrorFourScreen)
+	testutil.Equal(t, len(rom.Trainer), 512)
+	testutil.Equal(t, rom.PRG[0], 0xAA)
+	testutil.Equal(t, rom.CHR[0], 0xCC)
+
+	// The trainer is not a part of the CRC32.
+	testutil.Equal(t, rom.CRC32, crc32.Update(crc32.ChecksumIEEE(prg), crc32.IEEETable, chr))
+
+	cart, err := NewCartridge(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, cart.ReadPRG(0x6FFF), 0x00)
+	testutil.Equal(t, cart.ReadPRG(0x7000), 0xEE)
+	testutil.Equal(t, cart.ReadPRG(0x71FF), 0xEE)
+	testutil.Equal(t, cart.ReadPRG(0x7200), 0x00)
+	testutil.Equal(t, cart.MirrorMode(), MirrorFourScreen)
+}
+
+func TestNewFromBuffer_TruncatedCHR(t *testing.T) {
+	var (
+		header = []byte{'N', 'E', 'S', 0x1A, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		prg    = make([]byte, 0x4000)
+		chr    = make([]byte, 0x1000)
+	)
+
+	_, err := NewFromBuffer(bytes.Join([][]byte{header, prg, chr}, nil))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
+
+func TestNewFromBuffer_NES2(t *testing.T) {
+	header := []byte{
+		'N', 'E', 'S', 0x1A,
+		2,    // PRG-ROM LSB (2x16 KB)
+		0,    // CHR-ROM LSB (no CHR-ROM)
+		0x42, // mapper low nibble 4, battery
+		0x18, // mapper middle nibble 1, NES 2.0
+		0x21, // submapper 2, mapper high nibble 1
+		0x00, // PRG/CHR-ROM MSB
+		0x70, // PRG-NVRAM 8 KB (64<<7), no PRG-RAM
+		0x09, // CHR-RAM 32 KB (64<<9)
+		0x01, // PAL
+		0x00,
+		0x00,
+		0x08, // zapper
+	}
+
+	rom, err := NewFromBuffer(bytes.Join([][]byte{header, make([]byte, 0x8000)}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, rom.NES2, true)
+	testutil.Equal(t, rom.MapperID, 0x114)
+	testutil.Equal(t, rom.Submapper, 2)
+	testutil.Equal(t, rom.Battery, true)
+	testutil.Equal(t, rom.PRGRAMSize, 0)
+	testutil.Equal(t, rom.PRGNVRAMSize, 0x2000)
+	testutil.Equal(t, rom.CHRRAMSize, 0x8000)
+	testutil.Equal(t, rom.Region, consts.RegionPAL)
+	testutil.Equal(t, rom.ExpansionDevice, ExpansionZapper)
+	testutil.Equal(t, rom.PRGBanks, 2)
+	testutil.Equal(t, len(rom.CHR), 0x8000)
+}
+
+func TestNES2ROMSize(t *testing.T) {
+	tests := map[string]struct {
+		lsb, msb uint8
+		want     int
+	}{
+		"lsb only":    {lsb: 0x08, msb: 0x0, want: 8 * 16384},
+		"lsb and msb": {lsb: 0x00, msb: 0x1, want: 256 * 16384},
+		"exponent":    {lsb: 0x14 << 2, msb: 0xF, want: 1 << 20},
+		"multiplier":  {lsb: 0x0A<<2 | 1, msb: 0xF, want: 3 << 10},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			testutil.Equal(t, nes2ROMSize(tt.lsb, tt.msb, 16384), tt.want)
+		})
+	}
+}
+
+func TestNES2RAMSize(t *testing.T) {
+	testutil.Equal(t, nes2RAMSize(0), 0)
+	testutil.Equal(t, nes2RAMSize(1), 128)
+	testutil.Equal(t, nes2RAMSize(7), 0x2000)
+	testutil.Equal(t, nes2RAMSize(10), 0x10000)
+}
+
+func TestNewCartridge_Trainer(t *testing.T) {
+	tests := map[string]struct {
+		mapperID uint16
+		loaded   bool
+	}{
+		"MMC5":  {mapperID: 5, loaded: true},
+		"N163":  {mapperID: 19, loaded: true},
+		"UxROM": {mapperID: 2, loaded: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rom := newTestROM(tt.mapperID, 0x20000, 0x2000)
+			rom.Trainer = bytes.Repeat([]byte{0xEE}, 512)
+
+			cart, err := NewCartridge(rom)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cart.Reset()
+
+			testutil.Equal(t, rom.trainerLoaded, tt.loaded)
+			if tt.loaded {
+				testutil.Equal(t, cart.ReadPRG(0x7000), 0xEE)
+			}
+		})
+	}
+}
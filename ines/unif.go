@@ -0,0 +1,249 @@
+package ines
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/maxpoletaev/dendy/consts"
+)
+
+var (
+	ErrInvalidUNIF      = errors.New("invalid UNIF file")
+	ErrUnsupportedBoard = errors.New("unsupported UNIF board")
+)
+
+// unifMaxChunkSize is a sanity limit for the chunk length, so that a broken
+// file cannot make us allocate gigabytes of memory.
+const unifMaxChunkSize = 16 << 20
+
+// unifBoard is the mapper implementation used for a UNIF board.
+type unifBoard struct {
+	mapperID  uint16
+	submapper uint8
+}
+
+// unifBoards maps the UNIF board names (without the prefix) to mappers. Only
+// the boards that can be handled by the existing mappers are listed.
+var unifBoards = map[string]unifBoard{
+	"NROM":     {0, 0},
+	"NROM-128": {0, 0},
+	"NROM-256": {0, 0},
+	"RROM":     {0, 0},
+	"RROM-128": {0, 0},
+	"SAROM":    {1, 0},
+	"SBROM":    {1, 0},
+	"SCROM":    {1, 0},
+	"SEROM":    {1, 0},
+	"SFROM":    {1, 0},
+	"SGROM":    {1, 0},
+	"SHROM":    {1, 0},
+	"SJROM":    {1, 0},
+	"SKROM":    {1, 0},
+	"SLROM":    {1, 0},
+	"SL1ROM":   {1, 0},
+	"SNROM":    {1, 0},
+	"SOROM":    {1, 0},
+	"SUROM":    {1, 0},
+	"SXROM":    {1, 0},
+	"UNROM":    {2, 0},
+	"UOROM":    {2, 0},
+	"CNROM":    {3, 0},
+	"TBROM":    {4, 0},
+	"TEROM":    {4, 0},
+	"TFROM":    {4, 0},
+	"TGROM":    {4, 0},
+	"TKROM":    {4, 0},
+	"TLROM":    {4, 0},
+	"TL1ROM":   {4, 0},
+	"TNROM":    {4, 0},
+	"TSROM":    {4, 0},
+	"TVROM":    {4, 0},
+	"HKROM":    {4, 1},
+	"EKROM":    {5, 0},
+	"ELROM":    {5, 0},
+	"ETROM":    {5, 0},
+	"EWROM":    {5, 0},
+	"AMROM":    {7, 0},
+	"ANROM":    {7, 0},
+	"AOROM":    {7, 0},
+	"PEEOROM":  {9, 0},
+	"PNROM":    {9, 0},
+	"FJROM":    {10, 0},
+	"FKROM":    {10, 0},
+	"BNROM":    {34, 2},
+	"GNROM":    {66, 0},
+	"MHROM":    {66, 0},
+	"JLROM":    {69, 0},
+	"JSROM":    {69, 0},
+	"BTR":      {69, 0},
+	"TKSROM":   {118, 0},
+	"TLSROM":   {118, 0},
+	"TQROM":    {119, 0},
+
+	// Multicarts.
+	"42IN1RESETSWITCH":  {226, 0},
+	"GHOSTBUSTERS63IN1": {226, 0},
+}
+
+// unifBoardName strips the manufacturer prefix from the board name, so that
+// "NES-TLROM" and "HVC-TLROM" are both looked up as "TLROM".
+func unifBoardName(name string) string {
+	prefixes := []string{"NES-", "HVC-", "UNL-", "BMC-", "BTL-", "AVE-", "IREM-", "KONAMI-", "TENGEN-"}
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(strings.ToUpper(name), prefix) {
+			return strings.ToUpper(name[len(prefix):])
+		}
+	}
+
+	return strings.ToUpper(name)
+}
+
+// newUNIFROM parses a UNIF file, which is a sequence of tagged chunks following
+// the 32-byte header. The board is identified by its name rather than by the
+// mapper number, and the PRG and CHR data may be split into up to 16 chunks.
+// https://www.nesdev.org/wiki/UNIF
+func newUNIFROM(file io.Reader) (*ROM, error) {
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidUNIF, err)
+	}
+
+	if !bytes.Equal(header[:4], []byte("UNIF")) {
+		return nil, ErrInvalidUNIF
+	}
+
+	var (
+		board     string
+		prgChunks [16][]byte
+		chrChunks [16][]byte
+		mirror    = -1
+		battery   bool
+		region    = consts.RegionNTSC
+	)
+
+	for {
+		chunkHeader := make([]byte, 8)
+		if _, err := io.ReadFull(file, chunkHeader); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidUNIF, err)
+		}
+
+		var (
+			id     = string(chunkHeader[:4])
+			length = binary.LittleEndian.Uint32(chunkHeader[4:])
+		)
+
+		if length > unifMaxChunkSize {
+			return nil, fmt.Errorf("%w: %s chunk is too big", ErrInvalidUNIF, id)
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(file, data); err != nil {
+			return nil, fmt.Errorf("%w: truncated %s chunk", ErrInvalidUNIF, id)
+		}
+
+		switch {
+		case id == "MAPR":
+			board, _, _ = strings.Cut(string(data), "\x00")
+		case strings.HasPrefix(id, "PRG") && isHexDigit(id[3]):
+			prgChunks[hexDigit(id[3])] = data
+		case strings.HasPrefix(id, "CHR") && isHexDigit(id[3]):
+			chrChunks[hexDigit(id[3])] = data
+		case id == "MIRR" && length > 0:
+			mirror = int(data[0])
+		case id == "BATR":
+			battery = length == 0 || data[0] != 0
+		case id == "TVCI" && length > 0:
+			if data[0] == 1 {
+				region = consts.RegionPAL
+			}
+		}
+	}
+
+	if board == "" {
+		return nil, fmt.Errorf("%w: no MAPR chunk", ErrInvalidUNIF)
+	}
+
+	mapper, ok := unifBoards[unifBoardName(board)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedBoard, board)
+	}
+
+	rom := &ROM{
+		MapperID:  mapper.mapperID,
+		Submapper: mapper.submapper,
+		Battery:   battery,
+		Region:    region,
+		PRG:       bytes.Join(prgChunks[:], nil),
+		CHR:       bytes.Join(chrChunks[:], nil),
+	}
+
+	if len(rom.PRG) == 0 {
+		return nil, fmt.Errorf("%w: no PRG chunks", ErrInvalidUNIF)
+	}
+
+	switch mirror {
+	case 0:
+		rom.MirrorMode = MirrorHorizontal
+	case 1:
+		rom.MirrorMode = MirrorVertical
+	case 2:
+		rom.MirrorMode = MirrorSingle0
+	case 3:
+		rom.MirrorMode = MirrorSingle1
+	case 4:
+		rom.MirrorMode = MirrorFourScreen
+	}
+
+	// Like iNES 1.0, UNIF does not specify the RAM size.
+	if battery {
+		rom.PRGNVRAMSize = 0x2000
+	} else {
+		rom.PRGRAMSize = 0x2000
+	}
+
+	// CRC32 of PRG+CHR, same as for iNES.
+	rom.CRC32 = crc32.Update(crc32.ChecksumIEEE(rom.PRG), crc32.IEEETable, rom.CHR)
+	chrSize := len(rom.CHR)
+
+	if chrSize == 0 {
+		rom.CHR = make([]byte, 0x2000)
+		rom.CHRRAMSize = 0x2000
+		rom.chrRAM = true
+	}
+
+	rom.PRGBanks = (len(rom.PRG) + 0x3FFF) / 0x4000
+	rom.CHRBanks = chrSize / 0x2000
+	rom.applyGameDB()
+
+	log.Printf("[INFO] ROM info:")
+	log.Printf("[INFO]   > format:     UNIF")
+	log.Printf("[INFO]   > board:      %s", board)
+	log.Printf("[INFO]   > mapper ID:  %d.%d (%s)", rom.MapperID, rom.Submapper, mapperNames[rom.MapperID])
+	log.Printf("[INFO]   > PRG banks:  %d (%d KB)", rom.PRGBanks, len(rom.PRG)/1024)
+	log.Printf("[INFO]   > CHR banks:  %d (%d KB)", rom.CHRBanks, chrSize/1024)
+	log.Printf("[INFO]   > region:     %s", rom.Region)
+	log.Printf("[INFO]   > CRC32:      %08X", rom.CRC32)
+
+	return rom, nil
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'F'
+}
+
+func hexDigit(c byte) int {
+	if c >= 'A' {
+		return int(c-'A') + 10
+	}
+
+	return int(c - '0')
+}
@@ -0,0 +1,84 @@
+package ines
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func unifChunk(id string, data []byte) []byte {
+	chunk := make([]byte, 8, 8+len(data))
+	copy(chunk, id)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+	return append(chunk, data...)
+}
+
+func unifFile(chunks ...[]byte) []byte {
+	header := make([]byte, 32)
+	copy(header, "UNIF")
+	binary.LittleEndian.PutUint32(header[4:], 7)
+	return bytes.Join(append([][]byte{header}, chunks...), nil)
+}
+
+func TestNewFromBuffer_UNIF(t *testing.T) {
+	var (
+		prg0 = bytes.Repeat([]byte{0xAA}, 0x4000)
+		prg1 = bytes.Repeat([]byte{0xBB}, 0x4000)
+		chr0 = bytes.Repeat([]byte{0xCC}, 0x2000)
+	)
+
+	// The chunks are not required to be in order.
+	rom, err := NewFromBuffer(unifFile(
+		unifChunk("MAPR", []byte("NES-TLROM\x00")),
+		unifChunk("PRG1", prg1),
+		unifChunk("PRG0", prg0),
+		unifChunk("CHR0", chr0),
+		unifChunk("MIRR", []byte{1}),
+		unifChunk("BATR", []byte{1}),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, rom.MapperID, 4)
+	testutil.Equal(t, rom.MirrorMode, MirrorVertical)
+	testutil.Equal(t, rom.Battery, true)
+	testutil.Equal(t, rom.PRGBanks, 2)
+	testutil.Equal(t, rom.CHRBanks, 1)
+	testutil.Equal(t, rom.PRG[0], 0xAA)
+	testutil.Equal(t, rom.PRG[0x4000], 0xBB)
+	testutil.Equal(t, rom.CHR[0], 0xCC)
+
+	if _, err := NewCartridge(rom); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewFromBuffer_UNIFUnsupportedBoard(t *testing.T) {
+	_, err := NewFromBuffer(unifFile(
+		unifChunk("MAPR", []byte("UNL-SOMETHING\x00")),
+		unifChunk("PRG0", make([]byte, 0x4000)),
+	))
+
+	testutil.Equal(t, errors.Is(err, ErrUnsupportedBoard), true)
+}
+
+func TestNewFromBuffer_UNIFMulticart(t *testing.T) {
+	rom, err := NewFromBuffer(unifFile(
+		unifChunk("MAPR", []byte("BMC-42in1ResetSwitch\x00")),
+		unifChunk("PRG0", make([]byte, 0x80000)),
+		unifChunk("CHR0", make([]byte, 0x2000)),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, rom.MapperID, 226)
+
+	if _, err := NewCartridge(rom); err != nil {
+		t.Fatal(err)
+	}
+}
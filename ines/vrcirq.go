@@ -0,0 +1,107 @@
+package ines
+
+import (
+	"errors"
+
+	"github.com/maxpoletaev/dendy/internal/binario"
+)
+
+// vrcIRQ is the IRQ counter shared by the Konami VRC4, VRC6 and VRC7 mappers.
+// It counts CPU cycles, either directly (cycle mode), or through a prescaler
+// that approximates the scanline length (scanline mode).
+// https://www.nesdev.org/wiki/VRC_IRQ
+type vrcIRQ struct {
+	latch       uint8
+	counter     uint8
+	prescaler   int
+	enabled     bool
+	enableOnAck bool
+	cycleMode   bool
+	pending     bool
+}
+
+func (v *vrcIRQ) reset() {
+	v.latch = 0
+	v.counter = 0
+	v.prescaler = 0
+	v.enabled = false
+	v.enableOnAck = false
+	v.cycleMode = false
+	v.pending = false
+}
+
+func (v *vrcIRQ) writeControl(data byte) {
+	v.enableOnAck = data&0x01 != 0
+	v.enabled = data&0x02 != 0
+	v.cycleMode = data&0x04 != 0
+	v.pending = false
+
+	if v.enabled {
+		v.counter = v.latch
+		v.prescaler = 341
+	}
+}
+
+func (v *vrcIRQ) acknowledge() {
+	v.pending = false
+	v.enabled = v.enableOnAck
+}
+
+func (v *vrcIRQ) clock() {
+	if v.counter == 0xFF {
+		v.counter = v.latch
+		v.pending = true
+	} else {
+		v.counter++
+	}
+}
+
+// tick is called on every CPU cycle.
+func (v *vrcIRQ) tick() {
+	if !v.enabled {
+		return
+	}
+
+	if v.cycleMode {
+		v.clock()
+		return
+	}
+
+	// In scanline mode, the prescaler is decremented by 3 every CPU cycle, and
+	// the counter is clocked when it wraps around, which is every 341 PPU dots.
+	v.prescaler -= 3
+	if v.prescaler <= 0 {
+		v.prescaler += 341
+		v.clock()
+	}
+}
+
+func (v *vrcIRQ) saveState(w *binario.Writer) error {
+	return errors.Join(
+		w.WriteUint8(v.latch),
+		w.WriteUint8(v.counter),
+		w.WriteUint32(uint32(v.prescaler)),
+		w.WriteBool(v.enabled),
+		w.WriteBool(v.enableOnAck),
+		w.WriteBool(v.cycleMode),
+		w.WriteBool(v.pending),
+	)
+}
+
+func (v *vrcIRQ) loadState(r *binario.Reader) error {
+	var prescaler uint32
+
+	err := errors.Join(
+		r.ReadUint8To(&v.latch),
+		r.ReadUint8To(&v.counter),
+		r.ReadUint32To(&prescaler),
+		r.ReadBoolTo(&v.enabled),
+		r.ReadBoolTo(&v.enableOnAck),
+		r.ReadBoolTo(&v.cycleMode),
+		r.ReadBoolTo(&v.pending),
+	)
+
+	v.prescaler = int(prescaler)
+
+	return err
+}
@@ -0,0 +1,52 @@
+package ines
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestVRCIRQ_ScanlineMode(t *testing.T) {
+	var irq vrcIRQ
+	irq.reset()
+
+	irq.latch = 0xFD
+	irq.writeControl(0x02) // enabled, scanline mode
+
+	// Three scanlines of 341 dots are exactly 341 CPU cycles.
+	for i := 0; i < 340; i++ {
+		irq.tick()
+	}
+
+	testutil.Equal(t, irq.counter, 0xFF)
+	testutil.Equal(t, irq.pending, false)
+
+	irq.tick()
+	testutil.Equal(t, irq.pending, true)
+	testutil.Equal(t, irq.counter, 0xFD)
+
+	// Without the enable-on-acknowledge bit, the counter stops.
+	irq.acknowledge()
+	testutil.Equal(t, irq.pending, false)
+	testutil.Equal(t, irq.enabled, false)
+}
+
+func TestVRCIRQ_CycleMode(t *testing.T) {
+	var irq vrcIRQ
+	irq.reset()
+
+	irq.latch = 0xFE
+	irq.writeControl(0x07) // enabled, cycle mode, enable on acknowledge
+
+	irq.tick()
+	testutil.Equal(t, irq.pending, false)
+	irq.tick()
+	testutil.Equal(t, irq.pending, true)
+
+	irq.acknowledge()
+	testutil.Equal(t, irq.enabled, true)
+
+	irq.tick()
+	irq.tick()
+	testutil.Equal(t, irq.pending, true)
+}
@@ -0,0 +1,106 @@
+package battery
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Storage is where the battery-backed RAM is kept between the runs.
+type Storage interface {
+	// Load returns the saved RAM contents, or nil if nothing was saved yet.
+	Load() ([]byte, error)
+	// Store replaces the saved RAM contents.
+	Store(data []byte) error
+}
+
+// RAM keeps the battery-backed cartridge RAM in sync with the storage. The
+// storage holds the raw RAM contents, so the .sav files are compatible with
+// other emulators.
+type RAM struct {
+	storage Storage
+	ram     []byte
+	saved   []byte
+}
+
+// New returns nil if the cartridge has no battery (ram is nil). All methods
+// are safe to call on a nil RAM and do nothing.
+func New(ram []byte, storage Storage) *RAM {
+	if ram == nil {
+		return nil
+	}
+
+	return &RAM{
+		storage: storage,
+		saved:   make([]byte, len(ram)),
+		ram:     ram,
+	}
+}
+
+// Load copies the saved contents into the cartridge RAM. It returns false if
+// nothing was saved yet. Loading a save state afterwards overrides the RAM
+// again, since the state has its own copy of it, and it is the state RAM that
+// gets written on the next flush.
+func (b *RAM) Load() (bool, error) {
+	if b == nil {
+		return false, nil
+	}
+
+	data, err := b.storage.Load()
+	if err != nil {
+		return false, err
+	}
+
+	if data == nil {
+		return false, nil
+	}
+
+	if len(data) != len(b.ram) {
+		return false, fmt.Errorf("size mismatch: expected %d bytes, got %d", len(b.ram), len(data))
+	}
+
+	copy(b.ram, data)
+	copy(b.saved, data)
+
+	return true, nil
+}
+
+// Flush writes the cartridge RAM to the storage if it has changed since the
+// last load or flush. It returns false if there was nothing to write.
+func (b *RAM) Flush() (bool, error) {
+	if b == nil || bytes.Equal(b.ram, b.saved) {
+		return false, nil
+	}
+
+	if err := b.storage.Store(b.ram); err != nil {
+		return false, err
+	}
+
+	copy(b.saved, b.ram)
+
+	return true, nil
+}
+
+// File stores the RAM in a file on disk.
+type File string
+
+func (f File) Load() ([]byte, error) {
+	data, err := os.ReadFile(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	return data, err
+}
+
+// Store writes to a temporary file first, so that the previous save is not
+// lost if the emulator crashes in the middle of writing.
+func (f File) Store(data []byte) error {
+	tmpFile := string(f) + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, string(f))
+}
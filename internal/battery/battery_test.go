@@ -0,0 +1,96 @@
+package battery
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestRAM_FlushLoad(t *testing.T) {
+	file := File(filepath.Join(t.TempDir(), "game.sav"))
+
+	ram := []byte{1, 2, 3, 4}
+	b := New(ram, file)
+
+	ok, err := b.Flush()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, true)
+
+	ok, err = b.Flush()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, false) // not changed since the last flush
+
+	data, err := os.ReadFile(string(file))
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, bytes.Equal(data, ram), true)
+
+	ram2 := make([]byte, 4)
+	b2 := New(ram2, file)
+
+	ok, err = b2.Load()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, true)
+	testutil.Equal(t, bytes.Equal(ram2, ram), true)
+
+	ok, err = b2.Flush()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, false) // not changed since the load
+
+	ram2[0] = 0xFF
+	ok, err = b2.Flush()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, true)
+
+	data, err = os.ReadFile(string(file))
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, data[0], 0xFF)
+
+	_, err = os.Stat(string(file) + ".tmp")
+	testutil.Equal(t, os.IsNotExist(err), true)
+}
+
+func TestRAM_LoadMissing(t *testing.T) {
+	file := File(filepath.Join(t.TempDir(), "game.sav"))
+
+	ram := []byte{1, 2, 3, 4}
+	b := New(ram, file)
+
+	ok, err := b.Load()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, false)
+	testutil.Equal(t, bytes.Equal(ram, []byte{1, 2, 3, 4}), true)
+}
+
+func TestRAM_LoadSizeMismatch(t *testing.T) {
+	file := File(filepath.Join(t.TempDir(), "game.sav"))
+	if err := os.WriteFile(string(file), []byte{1, 2}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ram := make([]byte, 4)
+	b := New(ram, file)
+
+	ok, err := b.Load()
+	if err == nil {
+		t.Fatalf("expected size mismatch error")
+	}
+
+	testutil.Equal(t, ok, false)
+	testutil.Equal(t, bytes.Equal(ram, make([]byte, 4)), true)
+}
+
+func TestRAM_NoBattery(t *testing.T) {
+	b := New(nil, File(filepath.Join(t.TempDir(), "game.sav")))
+	testutil.Equal(t, b, nil)
+
+	ok, err := b.Load()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, false)
+
+	ok, err = b.Flush()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, false)
+}
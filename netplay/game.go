@@ -65,6 +65,8 @@ type Game struct {
 	roundTripTime      time.Duration
 	driftFrames        int
 	sleepFrames        uint32
+	timing             consts.Timing
+	ticksPerSample     uint64
 	audioOut           *ui.AudioOut
 	audioBuffer        []float32
 	audioBufferPos     int
@@ -72,15 +74,19 @@ type Game struct {
 }
 
 func NewGame(nes *system.System, audio *ui.AudioOut, localJoy, remoteJoy *input.Joystick) *Game {
+	timing := nes.Region().Timing()
+
 	return &Game{
-		nes:          nes,
-		headState:    newCheckpoint(),
-		syncState:    newCheckpoint(),
-		catchupState: newCheckpoint(),
-		audioOut:     audio,
-		audioBuffer:  make([]float32, consts.AudioBufferSize),
-		localJoy:     localJoy,
-		remoteJoy:    remoteJoy,
+		nes:            nes,
+		headState:      newCheckpoint(),
+		syncState:      newCheckpoint(),
+		catchupState:   newCheckpoint(),
+		timing:         timing,
+		ticksPerSample: uint64(timing.TicksPerAudioSample()),
+		audioOut:       audio,
+		audioBuffer:    make([]float32, timing.AudioBufferSize()),
+		localJoy:       localJoy,
+		remoteJoy:      remoteJoy,
 	}
 }
 
@@ -138,7 +144,7 @@ func (g *Game) playFrame() {
 		g.nes.Tick()
 		g.tick++
 
-		if g.tick%consts.TicksPerAudioSample == 0 {
+		if g.tick%g.ticksPerSample == 0 {
 			if g.audioBufferPos < len(g.audioBuffer) {
 				g.audioBuffer[g.audioBufferPos] = g.nes.AudioSample()
 				g.audioBufferPos++
@@ -250,7 +256,7 @@ func (g *Game) HandleRemoteInput(buttons uint8, frame uint32) {
 
 	if g.roundTripTime > 0 {
 		localFrame := g.frame
-		latencyFrames := uint32(g.roundTripTime / 2 / consts.FrameDuration)
+		latencyFrames := uint32(g.roundTripTime / 2 / g.timing.FrameDuration())
 		remoteFrame := frame + latencyFrames // just a good guess
 
 		if localFrame < remoteFrame {
@@ -263,7 +269,7 @@ func (g *Game) HandleRemoteInput(buttons uint8, frame uint32) {
 
 func (g *Game) replayLocalInput(startTime time.Time, endFrame uint32, inputPos int) {
 	for f := g.frame; f < endFrame; f++ {
-		remainingTime := consts.FrameDuration - time.Since(startTime)
+		remainingTime := g.timing.FrameDuration() - time.Since(startTime)
 
 		if remainingTime < g.frameEmulationTime {
 			g.save(g.catchupState)
@@ -332,7 +338,7 @@ func (g *Game) processDelayedInput(startTime time.Time) {
 
 	// Replay the inputs until the local and remote emulators are in sync.
 	for i := 0; i < numInputs; i++ {
-		remainingTime := consts.FrameDuration - time.Since(startTime)
+		remainingTime := g.timing.FrameDuration() - time.Since(startTime)
 
 		// We only have 16ms to replay all frames. Going over this limit will create a
 		// noticeable stutter and sound glitches. When we are close to the limit, save
@@ -0,0 +1,146 @@
+package ntsc
+
+import (
+	"image/color"
+
+	"github.com/maxpoletaev/dendy/ppu"
+)
+
+const (
+	// Width is the width of the filtered frame. It has about twice as many pixels
+	// as the PPU output stretched to the 8:7 pixel aspect ratio, so that the color
+	// artifacts are not lost, and is meant to be displayed at half the width.
+	Width  = 584
+	Height = ppu.FrameHeight
+)
+
+var (
+	_ ppu.FrameFilter = (*Filter)(nil)
+)
+
+const (
+	samplesPerPixel = 8 // master clock cycles per PPU dot
+	lineSamples     = ppu.FrameWidth * samplesPerPixel
+	lumaWindow      = 12 // one color cycle, filters out the chroma
+	chromaWindow    = 24 // two color cycles, chroma has a lower bandwidth
+	linePadding     = chromaWindow / 2
+)
+
+// Filter emulates the composite video output of the NTSC console. Instead of
+// looking up the colors in a palette, it generates the signal the PPU would
+// produce for each pixel, 8 samples per pixel (12 samples per color cycle), and
+// then decodes it back the way a TV does: the luma is the signal averaged over
+// one color cycle, and the chroma is demodulated over two. The colors bleed into
+// each other at the edges, dithered patterns turn into solid colors, and since
+// the phase of the color subcarrier shifts by a third of a cycle on every
+// scanline and alternates between frames, the edges get the familiar dot crawl.
+type Filter struct {
+	Frame []color.RGBA // Width*Height
+
+	params   ppu.NTSCParams
+	signal   [512][12]float64
+	carrierI [12]float64
+	carrierQ [12]float64
+	oddFrame bool
+
+	// Prefix sums of the current scanline.
+	sumY []float64
+	sumI []float64
+	sumQ []float64
+}
+
+func New(params ppu.NTSCParams) *Filter {
+	n := lineSamples + 2*linePadding + 1
+
+	f := &Filter{
+		Frame:  make([]color.RGBA, Width*Height),
+		params: params,
+		sumY:   make([]float64, n),
+		sumI:   make([]float64, n),
+		sumQ:   make([]float64, n),
+	}
+
+	for idx := range f.signal {
+		for phase := 0; phase < 12; phase++ {
+			f.signal[idx][phase] = ppu.CompositeSignal(uint16(idx), phase)
+		}
+	}
+
+	for phase := 0; phase < 12; phase++ {
+		f.carrierI[phase], f.carrierQ[phase] = params.Carrier(phase)
+	}
+
+	return f
+}
+
+// Apply filters the frame made of the PPU color indices (see ppu.PPU.Indices)
+// and returns the filtered frame. The returned slice is reused between calls.
+func (f *Filter) Apply(indices []uint16) []color.RGBA {
+	// A scanline is 341 dots, 2728 master clock cycles, which shifts the
+	// phase by 4 samples. So does every other frame, since the odd frames
+	// are one dot shorter.
+	framePhase := 0
+	if f.oddFrame {
+		framePhase = 4
+	}
+
+	for y := 0; y < Height; y++ {
+		var (
+			src   = indices[y*ppu.FrameWidth : (y+1)*ppu.FrameWidth]
+			dst   = f.Frame[y*Width : (y+1)*Width]
+			phase = (framePhase + y*4) % 12
+		)
+
+		f.decodeLine(src, dst, phase)
+	}
+
+	f.oddFrame = !f.oddFrame
+
+	return f.Frame
+}
+
+// decodeLine generates the signal of a scanline and decodes it into the output
+// pixels. The signal is accumulated in prefix sums, so that the average over a
+// window of any size takes two lookups. The samples outside of the visible
+// area are blank.
+func (f *Filter) decodeLine(src []uint16, dst []color.RGBA, phase int) {
+	var (
+		sumY, sumI, sumQ float64
+		p                = phase
+		k                = linePadding + 1 // the sums are zero until then
+	)
+
+	for _, idx := range src {
+		signal := &f.signal[idx&0x1FF]
+
+		for n := 0; n < samplesPerPixel; n++ {
+			v := signal[p]
+			sumY += v
+			sumI += v * f.carrierI[p]
+			sumQ += v * f.carrierQ[p]
+
+			f.sumY[k], f.sumI[k], f.sumQ[k] = sumY, sumI, sumQ
+			k++
+
+			if p++; p == 12 {
+				p = 0
+			}
+		}
+	}
+
+	for ; k < len(f.sumY); k++ {
+		f.sumY[k], f.sumI[k], f.sumQ[k] = sumY, sumI, sumQ
+	}
+
+	for x := range dst {
+		c := (2*x+1)*lineSamples/(2*Width) + linePadding
+
+		var (
+			y = (f.sumY[c+lumaWindow/2] - f.sumY[c-lumaWindow/2]) / lumaWindow
+			i = (f.sumI[c+chromaWindow/2] - f.sumI[c-chromaWindow/2]) / chromaWindow
+			q = (f.sumQ[c+chromaWindow/2] - f.sumQ[c-chromaWindow/2]) / chromaWindow
+		)
+
+		dst[x] = f.params.RGB(y, i, q)
+	}
+}
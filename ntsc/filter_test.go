@@ -0,0 +1,52 @@
+package ntsc
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+	"github.com/maxpoletaev/dendy/ppu"
+)
+
+func TestFilter_SolidColor(t *testing.T) {
+	pal := ppu.GeneratePalette(ppu.DefaultNTSCParams)
+	f := New(ppu.DefaultNTSCParams)
+
+	for _, idx := range []uint16{0x0F, 0x16, 0x2A, 0x30, 4<<6 | 0x12} {
+		frame := make([]uint16, ppu.FrameWidth*ppu.FrameHeight)
+		for i := range frame {
+			frame[i] = idx
+		}
+
+		// Away from the edges, a solid color decodes to the palette color,
+		// regardless of the phase.
+		for n := 0; n < 2; n++ {
+			out := f.Apply(frame)
+			testutil.Equal(t, out[10*Width+Width/2], pal[idx])
+			testutil.Equal(t, out[11*Width+Width/3], pal[idx])
+		}
+	}
+}
+
+func TestFilter_Artifacts(t *testing.T) {
+	f := New(ppu.DefaultNTSCParams)
+	frame := make([]uint16, ppu.FrameWidth*ppu.FrameHeight)
+
+	// A pattern of white and black vertical lines.
+	for i := range frame {
+		frame[i] = 0x0F
+		if i%2 == 0 {
+			frame[i] = 0x30
+		}
+	}
+
+	var (
+		c1 = f.Apply(frame)[10*Width+Width/2]
+		c2 = f.Apply(frame)[10*Width+Width/2]
+	)
+
+	// The pattern is averaged into something in between, but not gray, and
+	// not the same in the next frame.
+	testutil.Equal(t, c1.R > 0 && c1.R < 255, true)
+	testutil.Equal(t, c1.R != c1.G || c1.G != c1.B, true)
+	testutil.Equal(t, c1 != c2, true)
+}
@@ -2,26 +2,66 @@ package ppu
 
 import "image/color"
 
-var Colors [64]color.RGBA
-
-func init() {
-	colors := []uint32{
-		0x666666, 0x002A88, 0x1412A7, 0x3B00A4, 0x5C007E, 0x6E0040, 0x6C0600, 0x561D00,
-		0x333500, 0x0B4800, 0x005200, 0x004F08, 0x00404D, 0x000000, 0x000000, 0x000000,
-		0xADADAD, 0x155FD9, 0x4240FF, 0x7527FE, 0xA01ACC, 0xB71E7B, 0xB53120, 0x994E00,
-		0x6B6D00, 0x388700, 0x0C9300, 0x008F32, 0x007C8D, 0x000000, 0x000000, 0x000000,
-		0xFFFEFF, 0x64B0FF, 0x9290FF, 0xC676FF, 0xF36AFF, 0xFE6ECC, 0xFE8170, 0xEA9E22,
-		0xBCBE00, 0x88D800, 0x5CE430, 0x45E082, 0x48CDDE, 0x4F4F4F, 0x000000, 0x000000,
-		0xFFFEFF, 0xC0DFFF, 0xD3D2FF, 0xE8C8FF, 0xFBC2FF, 0xFEC4EA, 0xFECCC5, 0xF7D8A5,
-		0xE4E594, 0xCFEF96, 0xBDF4AB, 0xB3F3CC, 0xB5EBF2, 0xB8B8B8, 0x000000, 0x000000,
+// Palette is the RGB palette of the PPU. The first 64 entries are the base
+// colors, and each of the following 7 groups of 64 has the colors with the
+// emphasis bits from the mask register applied (bit 0 for red, 1 for green and
+// 2 for blue), so the index is emphasis<<6 | color.
+type Palette [512]color.RGBA
+
+// emphasisFactor is how much each of the emphasis bits darkens the other two
+// color channels, which is roughly what the NTSC PPU does to the signal.
+const emphasisFactor = 0.816328
+
+// defaultColors are the base colors of the default palette.
+var defaultColors = [64]uint32{
+	0x666666, 0x002A88, 0x1412A7, 0x3B00A4, 0x5C007E, 0x6E0040, 0x6C0600, 0x561D00,
+	0x333500, 0x0B4800, 0x005200, 0x004F08, 0x00404D, 0x000000, 0x000000, 0x000000,
+	0xADADAD, 0x155FD9, 0x4240FF, 0x7527FE, 0xA01ACC, 0xB71E7B, 0xB53120, 0x994E00,
+	0x6B6D00, 0x388700, 0x0C9300, 0x008F32, 0x007C8D, 0x000000, 0x000000, 0x000000,
+	0xFFFEFF, 0x64B0FF, 0x9290FF, 0xC676FF, 0xF36AFF, 0xFE6ECC, 0xFE8170, 0xEA9E22,
+	0xBCBE00, 0x88D800, 0x5CE430, 0x45E082, 0x48CDDE, 0x4F4F4F, 0x000000, 0x000000,
+	0xFFFEFF, 0xC0DFFF, 0xD3D2FF, 0xE8C8FF, 0xFBC2FF, 0xFEC4EA, 0xFECCC5, 0xF7D8A5,
+	0xE4E594, 0xCFEF96, 0xBDF4AB, 0xB3F3CC, 0xB5EBF2, 0xB8B8B8, 0x000000, 0x000000,
+}
+
+// DefaultPalette returns the built-in palette the emulator starts with.
+func DefaultPalette() *Palette {
+	var base [64]color.RGBA
+
+	for i, c := range defaultColors {
+		base[i] = color.RGBA{R: byte(c >> 16), G: byte(c >> 8), B: byte(c), A: 0xFF}
 	}
 
-	for i, c := range colors {
-		Colors[i] = color.RGBA{
-			R: byte(c >> 16),
-			G: byte(c >> 8),
-			B: byte(c >> 0),
-			A: 0xFF,
+	return emphasize(base)
+}
+
+// emphasize makes a full palette from the 64 base colors, approximating the
+// emphasis by darkening the channels that are not emphasized.
+func emphasize(base [64]color.RGBA) *Palette {
+	var pal Palette
+
+	for emphasis := 0; emphasis < 8; emphasis++ {
+		r, g, b := 1.0, 1.0, 1.0
+
+		if emphasis&0x01 != 0 {
+			g, b = g*emphasisFactor, b*emphasisFactor
+		}
+		if emphasis&0x02 != 0 {
+			r, b = r*emphasisFactor, b*emphasisFactor
+		}
+		if emphasis&0x04 != 0 {
+			r, g = r*emphasisFactor, g*emphasisFactor
+		}
+
+		for i, c := range base {
+			pal[emphasis<<6|i] = color.RGBA{
+				R: byte(float64(c.R) * r),
+				G: byte(float64(c.G) * g),
+				B: byte(float64(c.B) * b),
+				A: 0xFF,
+			}
 		}
 	}
+
+	return &pal
 }
@@ -0,0 +1,40 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/consts"
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestPPU_paletteIndex(t *testing.T) {
+	tests := map[string]struct {
+		region consts.Region
+		mask   MaskFlags
+		want   uint16
+	}{
+		"no emphasis":    {consts.RegionNTSC, 0, 0x16},
+		"red emphasis":   {consts.RegionNTSC, MaskEmphasizeRed, 1<<6 | 0x16},
+		"green emphasis": {consts.RegionNTSC, MaskEmphasizeGreen, 2<<6 | 0x16},
+		"all emphasis":   {consts.RegionNTSC, MaskEmphasizeRed | MaskEmphasizeGreen | MaskEmphasizeBlue, 7<<6 | 0x16},
+		"pal red":        {consts.RegionPAL, MaskEmphasizeRed, 2<<6 | 0x16},
+		"pal green":      {consts.RegionPAL, MaskEmphasizeGreen, 1<<6 | 0x16},
+		"grayscale":      {consts.RegionNTSC, MaskGrayscale | MaskEmphasizeBlue, 4<<6 | 0x10},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := &PPU{timing: tt.region.Timing(), mask: tt.mask}
+			p.paletteTable[0] = 0x16
+
+			testutil.Equal(t, p.paletteIndex(0x3F00), tt.want)
+		})
+	}
+
+	// Emphasizing red darkens the other channels.
+	pal := DefaultPalette()
+	c, e := pal[0x30], pal[1<<6|0x30]
+	testutil.Equal(t, e.R, c.R)
+	testutil.Equal(t, e.G < c.G, true)
+	testutil.Equal(t, e.B < c.B, true)
+}
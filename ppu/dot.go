@@ -0,0 +1,337 @@
+package ppu
+
+import (
+	"github.com/maxpoletaev/dendy/ines"
+)
+
+// The dot renderer emulates the PPU one dot at a time, the way the hardware does
+// it. The background is drawn from the shift registers, which are reloaded every
+// 8 dots from the tile fetched in the meantime. Sprites for the next scanline are
+// evaluated during dots 65-256 and fetched during dots 257-320, and every pixel
+// is composed when its dot is reached. It is slower than rendering the whole
+// scanline at once, but the register writes in the middle of a scanline (status
+// bars, raster effects) and the sprite zero hit happen exactly when they do on
+// the real console.
+// https://www.nesdev.org/wiki/PPU_rendering
+
+// tickDot runs a single dot of the pre-render or a visible scanline.
+func (p *PPU) tickDot() {
+	var (
+		dot       = p.cycle
+		visible   = p.scanline >= 0 && p.scanline < FrameHeight
+		rendering = p.renderingEnabled()
+	)
+
+	if p.scanline == -1 && dot == 1 {
+		p.setStatus(StatusSpriteOverflow, false)
+		p.setStatus(StatusSpriteZeroHit, false)
+		p.setStatus(StatusVBlank, false)
+	}
+
+	// The first dot of the first scanline is skipped on odd frames (NTSC
+	// only), but only when the rendering is enabled.
+	if p.scanline == 0 && dot == 0 && p.oddFrame && rendering && p.timing.SkipOddFrameDot {
+		p.cycle, dot = 1, 1
+	}
+
+	if rendering {
+		p.tickBackground(dot)
+		p.tickSprites(dot, visible)
+		p.tickScroll(dot)
+	}
+
+	if visible && dot >= 1 && dot <= 256 {
+		p.renderDot(dot - 1)
+	}
+
+	if dot == 257 {
+		p.ScanlineComplete = true
+	}
+}
+
+// tickBackground runs the background pipeline: the shift registers are shifted
+// on every dot, and the next tile is fetched in 8 dots, two dots per memory
+// access (nametable, attribute, low and high bitplanes). The first two tiles of
+// a scanline are prefetched at the end of the previous one.
+func (p *PPU) tickBackground(dot int) {
+	if dot >= 2 && dot <= 257 || dot >= 322 && dot <= 337 {
+		p.bgShiftLo <<= 1
+		p.bgShiftHi <<= 1
+		p.bgAttrLo <<= 1
+		p.bgAttrHi <<= 1
+
+		if (dot-1)%8 == 0 {
+			p.reloadBackground()
+		}
+	}
+
+	if dot == 0 || dot > 256 && dot < 321 || dot > 336 {
+		return
+	}
+
+	if dot == 321 {
+		p.notifyFetch(ines.FetchBackground)
+	}
+
+	v := uint16(p.vramAddr)
+
+	switch dot % 8 {
+	case 1:
+		p.bgTile = p.readVRAM(0x2000 | v&0x0FFF)
+	case 3:
+		attr := p.readVRAM(0x23C0 | v&0x0C00 | v>>4&0x38 | v>>2&0x07)
+		if p.vramAddr.coarseY()&0x02 != 0 {
+			attr >>= 4
+		}
+		if p.vramAddr.coarseX()&0x02 != 0 {
+			attr >>= 2
+		}
+		p.bgAttr = attr & 0x03
+	case 5:
+		addr := p.tilePatternTableOffset() + uint16(p.bgTile)*16 + p.vramAddr.fineY()
+		p.bgLo = p.readVRAM(addr)
+	case 7:
+		addr := p.tilePatternTableOffset() + uint16(p.bgTile)*16 + p.vramAddr.fineY()
+		p.bgHi = p.readVRAM(addr + 8)
+		p.notifyPattern(addr + 8)
+	}
+}
+
+// reloadBackground loads the fetched tile into the low 8 bits of the shift
+// registers. The attribute is expanded to 8 bits, so that it can be shifted
+// along with the pattern.
+func (p *PPU) reloadBackground() {
+	p.bgShiftLo = p.bgShiftLo&0xFF00 | uint16(p.bgLo)
+	p.bgShiftHi = p.bgShiftHi&0xFF00 | uint16(p.bgHi)
+	p.bgAttrLo = p.bgAttrLo & 0xFF00
+	p.bgAttrHi = p.bgAttrHi & 0xFF00
+
+	if p.bgAttr&0x01 != 0 {
+		p.bgAttrLo |= 0x00FF
+	}
+
+	if p.bgAttr&0x02 != 0 {
+		p.bgAttrHi |= 0x00FF
+	}
+}
+
+// tickScroll updates the VRAM address the same way the real PPU does during
+// rendering: coarse X is incremented after every tile, fine Y at the end of the
+// scanline, and the horizontal and vertical position are reloaded from the
+// temporary address on dot 257 and during dots 280-304 of the pre-render line.
+func (p *PPU) tickScroll(dot int) {
+	if dot%8 == 0 && (dot >= 8 && dot <= 256 || dot == 328 || dot == 336) {
+		p.vramAddr.incrementX()
+	}
+
+	if dot == 256 {
+		p.vramAddr.incrementY()
+	}
+
+	if dot == 257 {
+		p.vramAddr.setNametableX(p.tmpAddr.nametableX())
+		p.vramAddr.setCoarseX(p.tmpAddr.coarseX())
+	}
+
+	if p.scanline == -1 && dot >= 280 && dot <= 304 {
+		p.vramAddr.setNametableY(p.tmpAddr.nametableY())
+		p.vramAddr.setCoarseY(p.tmpAddr.coarseY())
+		p.vramAddr.setFineY(p.tmpAddr.fineY())
+	}
+}
+
+// tickSprites runs the sprite evaluation for the next scanline and fetches the
+// found sprites. There is no evaluation on the pre-render line, so there are
+// never any sprites on the first scanline.
+func (p *PPU) tickSprites(dot int, visible bool) {
+	switch {
+	case visible && dot == 64:
+		p.evalN, p.evalM = 0, 0
+		p.evalCount = 0
+		p.evalDone = false
+
+	case visible && dot >= 65 && dot <= 256:
+		p.evaluateSpriteDot(dot)
+
+	case dot == 257:
+		p.notifyFetch(ines.FetchSprite)
+
+		p.spriteCount = 0
+		if visible {
+			p.spriteCount = p.evalCount
+		}
+	}
+
+	if dot >= 257 && dot <= 320 {
+		p.oamAddr = 0
+
+		// The pattern fetches of each of the 8 slots start 4 dots in.
+		if dot%8 == 5 {
+			p.fetchSpriteSlot((dot - 257) / 8)
+		}
+	}
+}
+
+// spriteInRange tells whether a sprite with the given Y coordinate is visible
+// on the next scanline.
+func (p *PPU) spriteInRange(y uint8) bool {
+	row := p.scanline - int(y)
+	return row >= 0 && row < p.spriteHeight()
+}
+
+// evaluateSpriteDot runs one dot of the sprite evaluation. On odd dots a byte
+// is read from OAM, and on even dots it is either copied to the secondary OAM
+// or compared with the current scanline, depending on the state. Only the OAM
+// indices are kept, since the sprite data is read again when fetched.
+func (p *PPU) evaluateSpriteDot(dot int) {
+	if dot%2 == 1 {
+		p.oamLatch = p.oamData[(p.evalN*4+p.evalM)&0xFF]
+		return
+	}
+
+	if p.evalDone {
+		return
+	}
+
+	full := p.evalCount >= 8
+
+	switch {
+	case full && !p.NoSpriteLimit:
+		// Due to a hardware bug, the PPU increments both the sprite and the
+		// byte index when looking for the ninth sprite, so it compares the
+		// wrong bytes with the scanline and the overflow flag is unreliable.
+		if p.spriteInRange(p.oamLatch) {
+			p.setStatus(StatusSpriteOverflow, true)
+			p.evalDone = true
+			return
+		}
+
+		p.evalN++
+		p.evalM = (p.evalM + 1) & 0x03
+
+	case p.evalM == 0 && !p.spriteInRange(p.oamLatch):
+		p.evalN++
+
+	default:
+		if p.evalM == 0 {
+			if full {
+				p.setStatus(StatusSpriteOverflow, true)
+			}
+
+			p.evalSprites[p.evalCount] = uint8(p.evalN)
+		}
+
+		// The other three bytes are copied on the following dots.
+		if p.evalM++; p.evalM == 4 {
+			p.evalM = 0
+			p.evalN++
+			p.evalCount++
+		}
+	}
+
+	if p.evalN == 64 {
+		p.evalDone = true
+	}
+}
+
+// fetchSpriteSlot fetches the pattern of the sprite in the given slot of the
+// secondary OAM. Without the sprite limit, the extra sprites are fetched along
+// with the last slot.
+func (p *PPU) fetchSpriteSlot(slot int) {
+	last := slot + 1
+	if slot == 7 {
+		last = max(p.spriteCount, 8)
+	}
+
+	for ; slot < last; slot++ {
+		if slot >= p.spriteCount {
+			// The PPU still fetches tile $FF for the empty slots, which
+			// matters for the mappers that watch the fetched tiles.
+			if p.watches(ines.EventPattern) {
+				addr := p.spriteAddr(p.spritePatternTableOffset(), 0xFF, 0, p.spriteHeight())
+				p.notifyPattern(addr + 8)
+			}
+
+			continue
+		}
+
+		// OAM could have been modified since the evaluation, so keep
+		// the row within the sprite.
+		idx := int(p.evalSprites[slot])
+		row := (p.scanline - int(p.oamData[idx*4])) & (p.spriteHeight() - 1)
+		p.spriteScanline[slot] = p.fetchSpriteScanline(idx, row)
+	}
+}
+
+// renderDot composes the pixel at the given x coordinate of the current
+// scanline from the background shift registers and the sprites fetched on the
+// previous scanline.
+func (p *PPU) renderDot(x int) {
+	var bgPixel, bgPalette uint8
+
+	if p.getMask(MaskShowBackground) && (x >= 8 || p.getMask(MaskShowLeftTiles)) {
+		bit := uint16(0x8000) >> p.fineX
+
+		if p.bgShiftLo&bit != 0 {
+			bgPixel |= 0x01
+		}
+		if p.bgShiftHi&bit != 0 {
+			bgPixel |= 0x02
+		}
+		if p.bgAttrLo&bit != 0 {
+			bgPalette |= 0x01
+		}
+		if p.bgAttrHi&bit != 0 {
+			bgPalette |= 0x02
+		}
+	}
+
+	var (
+		spritePixel   uint8
+		spritePalette uint8
+		spriteBehind  bool
+	)
+
+	if p.getMask(MaskShowSprites) && (x >= 8 || p.getMask(MaskShowLeftSprites)) {
+		for i := 0; i < p.spriteCount; i++ {
+			sprite := &p.spriteScanline[i]
+
+			px := x - int(sprite.X)
+			if px < 0 || px >= 8 || sprite.Pixels[px] == 0 {
+				continue
+			}
+
+			// The sprite zero hit never happens on the last pixel.
+			if sprite.Index == 0 && bgPixel != 0 && x != 255 {
+				p.setStatus(StatusSpriteZeroHit, true)
+			}
+
+			spritePixel = sprite.Pixels[px]
+			spritePalette = sprite.PaletteID
+			spriteBehind = sprite.Behind
+
+			break
+		}
+	}
+
+	if p.FastForward {
+		return
+	}
+
+	colorAddr := uint16(0x3F00)
+
+	switch {
+	case !p.renderingEnabled():
+		// With the rendering disabled, the backdrop color is displayed, unless
+		// the VRAM address points to the palette.
+		if addr := uint16(p.vramAddr) & 0x3FFF; addr >= 0x3F00 {
+			colorAddr = addr
+		}
+	case spritePixel != 0 && (bgPixel == 0 || !spriteBehind):
+		colorAddr = 0x3F10 + uint16(spritePalette)*4 + uint16(spritePixel)
+	case bgPixel != 0:
+		colorAddr = 0x3F00 + uint16(bgPalette)*4 + uint16(bgPixel)
+	}
+
+	p.Indices[p.scanline*FrameWidth+x] = p.paletteIndex(colorAddr)
+}
@@ -0,0 +1,275 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/ines"
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+// newDotTestPPU creates a PPU with the dot renderer enabled, a solid tile at
+// the given tile coordinates, and sprite zero using the same tile. Tile 2 only
+// has the top left pixel set, and tile 3 is solid as well.
+func newDotTestPPU(tileX, tileY int, spriteX, spriteY uint8) *PPU {
+	chr := make([]byte, 0x2000)
+	for i := 0; i < 8; i++ {
+		chr[16+i] = 0xFF // tile 1, low bitplane
+		chr[48+i] = 0xFF // tile 3
+	}
+
+	chr[32] = 0x80 // tile 2
+
+	rom := &ines.ROM{
+		PRG:        make([]byte, 0x4000),
+		CHR:        chr,
+		MirrorMode: ines.MirrorVertical,
+	}
+
+	p := New(ines.NewMapper0(rom))
+	p.DotRendering = true
+	p.Reset()
+
+	p.nameTable[0][tileY*32+tileX] = 1
+	p.oamData[0] = spriteY
+	p.oamData[1] = 1
+	p.oamData[3] = spriteX
+
+	for i := 1; i < 64; i++ {
+		p.oamData[i*4] = 0xFF // offscreen
+	}
+
+	p.paletteTable[0x01] = 0x16
+	p.paletteTable[0x11] = 0x2A
+	p.mask = MaskShowBackground | MaskShowSprites | MaskShowLeftTiles | MaskShowLeftSprites
+
+	return p
+}
+
+// runUntilHit runs the PPU for up to two frames and returns the scanline and
+// the dot on which the sprite zero hit flag was set.
+func runUntilHit(p *PPU) (scanline, dot int, ok bool) {
+	for i := 0; i < 2*341*262; i++ {
+		scanline, dot = p.scanline, p.cycle
+		p.Tick()
+
+		if p.getStatus(StatusSpriteZeroHit) {
+			return scanline, dot, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+func TestPPU_DotSpriteZeroHit(t *testing.T) {
+	tests := map[string]struct {
+		fineX        uint8
+		spriteX      uint8
+		wantScanline int
+		wantDot      int
+		wantHit      bool
+	}{
+		"overlap": {
+			fineX:        0,
+			spriteX:      84,
+			wantScanline: 45, // sprites are drawn one scanline below their Y
+			wantDot:      85, // pixel 84
+			wantHit:      true,
+		},
+		"fine scroll": {
+			fineX:        3,
+			spriteX:      70,
+			wantScanline: 45,
+			wantDot:      78, // the tile is moved 3 pixels left, to 77-84
+			wantHit:      true,
+		},
+		"no overlap": {
+			fineX:   0,
+			spriteX: 100,
+			wantHit: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newDotTestPPU(10, 5, tt.spriteX, 44)
+			p.fineX = tt.fineX
+
+			scanline, dot, hit := runUntilHit(p)
+			testutil.Equal(t, hit, tt.wantHit)
+
+			if tt.wantHit {
+				testutil.Equal(t, scanline, tt.wantScanline)
+				testutil.Equal(t, dot, tt.wantDot)
+			}
+		})
+	}
+}
+
+func TestPPU_DotSpriteZeroHitLastPixel(t *testing.T) {
+	p := newDotTestPPU(31, 5, 255, 44)
+
+	_, _, hit := runUntilHit(p)
+	testutil.Equal(t, hit, false)
+}
+
+func TestPPU_DotFrame(t *testing.T) {
+	p := newDotTestPPU(10, 5, 120, 100)
+
+	for !p.FrameComplete {
+		p.Tick()
+	}
+
+	const (
+		backdrop   uint16 = 0x00
+		background uint16 = 0x16
+		sprite     uint16 = 0x2A
+	)
+
+	testutil.Equal(t, p.Indices[40*FrameWidth+79], backdrop)
+	testutil.Equal(t, p.Indices[40*FrameWidth+80], background)
+	testutil.Equal(t, p.Indices[47*FrameWidth+87], background)
+	testutil.Equal(t, p.Indices[48*FrameWidth+87], backdrop)
+	testutil.Equal(t, p.Indices[100*FrameWidth+120], backdrop)
+	testutil.Equal(t, p.Indices[101*FrameWidth+120], sprite)
+	testutil.Equal(t, p.Indices[108*FrameWidth+127], sprite)
+}
+
+func TestPPU_DotSpriteZeroHitLeftClip(t *testing.T) {
+	tests := map[string]struct {
+		mask    uint8
+		wantDot int
+	}{
+		"both shown":      {mask: MaskShowLeftTiles | MaskShowLeftSprites, wantDot: 5},
+		"sprites clipped": {mask: MaskShowLeftTiles, wantDot: 9},
+		"tiles clipped":   {mask: MaskShowLeftSprites, wantDot: 9},
+		"both clipped":    {mask: 0, wantDot: 9},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newDotTestPPU(0, 5, 4, 44)
+			p.nameTable[0][5*32+1] = 1
+			p.mask = MaskShowBackground | MaskShowSprites | tt.mask
+
+			scanline, dot, hit := runUntilHit(p)
+			testutil.Equal(t, hit, true)
+			testutil.Equal(t, scanline, 45)
+			testutil.Equal(t, dot, tt.wantDot)
+		})
+	}
+}
+
+func TestPPU_DotSpriteZeroHitEdges(t *testing.T) {
+	tests := map[string]struct {
+		tileX, tileY     int
+		spriteX, spriteY uint8
+		wantScanline     int
+		wantDot          int
+		wantHit          bool
+	}{
+		"right edge": {
+			tileX: 31, tileY: 5,
+			spriteX: 254, spriteY: 44,
+			wantScanline: 45, wantDot: 255, wantHit: true,
+		},
+		"bottom line": {
+			tileX: 10, tileY: 29,
+			spriteX: 80, spriteY: 238,
+			wantScanline: 239, wantDot: 81, wantHit: true,
+		},
+		"below the screen": {
+			tileX: 10, tileY: 29,
+			spriteX: 80, spriteY: 239,
+			wantHit: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newDotTestPPU(tt.tileX, tt.tileY, tt.spriteX, tt.spriteY)
+
+			scanline, dot, hit := runUntilHit(p)
+			testutil.Equal(t, hit, tt.wantHit)
+
+			if tt.wantHit {
+				testutil.Equal(t, scanline, tt.wantScanline)
+				testutil.Equal(t, dot, tt.wantDot)
+			}
+		})
+	}
+}
+
+func TestPPU_DotSpriteZeroHitFlip(t *testing.T) {
+	tests := map[string]struct {
+		attr         uint8
+		wantScanline int
+		wantDot      int
+	}{
+		"no flip":   {attr: 0, wantScanline: 40, wantDot: 81},
+		"flip x":    {attr: spriteAttrFlipX, wantScanline: 40, wantDot: 88},
+		"flip y":    {attr: spriteAttrFlipY, wantScanline: 47, wantDot: 81},
+		"flip both": {attr: spriteAttrFlipX | spriteAttrFlipY, wantScanline: 47, wantDot: 88},
+		"behind":    {attr: spriteAttrPriority, wantScanline: 40, wantDot: 81},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newDotTestPPU(10, 5, 80, 39)
+
+			p.oamData[1] = 2
+			p.oamData[2] = tt.attr
+
+			scanline, dot, hit := runUntilHit(p)
+			testutil.Equal(t, hit, true)
+			testutil.Equal(t, scanline, tt.wantScanline)
+			testutil.Equal(t, dot, tt.wantDot)
+		})
+	}
+}
+
+func TestPPU_DotSpriteZeroHitTallSprite(t *testing.T) {
+	p := newDotTestPPU(10, 5, 80, 31)
+	p.ctrl |= CtrlSpriteSize
+
+	// The top half (tile 2) is on the lines 32-39, above the background, so
+	// the hit comes from the bottom half (tile 3).
+	p.oamData[1] = 2
+
+	scanline, dot, hit := runUntilHit(p)
+	testutil.Equal(t, hit, true)
+	testutil.Equal(t, scanline, 40)
+	testutil.Equal(t, dot, 81)
+}
+
+func TestPPU_DotSpriteZeroHitDisabled(t *testing.T) {
+	for name, mask := range map[string]uint8{
+		"no background": MaskShowSprites | MaskShowLeftTiles | MaskShowLeftSprites,
+		"no sprites":    MaskShowBackground | MaskShowLeftTiles | MaskShowLeftSprites,
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := newDotTestPPU(10, 5, 84, 44)
+			p.mask = mask
+
+			_, _, hit := runUntilHit(p)
+			testutil.Equal(t, hit, false)
+		})
+	}
+}
+
+func TestPPU_DotSpriteZeroHitClear(t *testing.T) {
+	p := newDotTestPPU(10, 5, 84, 44)
+
+	if _, _, hit := runUntilHit(p); !hit {
+		t.Fatal("no sprite zero hit")
+	}
+
+	// The flag stays set through the vertical blank, and is cleared on the
+	// second dot of the pre-render line.
+	for p.scanline != -1 || p.cycle != 1 {
+		p.Tick()
+		testutil.Equal(t, p.getStatus(StatusSpriteZeroHit), true)
+	}
+
+	p.Tick()
+	testutil.Equal(t, p.getStatus(StatusSpriteZeroHit), false)
+}
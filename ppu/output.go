@@ -0,0 +1,37 @@
+package ppu
+
+import "image/color"
+
+// FrameFilter turns the frames produced by the PPU, made of the color indices
+// (see PPU.Indices), into RGB images. The PPU itself knows nothing about RGB,
+// so that the frontends can choose how the colors are made: a palette lookup,
+// a simulation of the video signal, or anything else. The returned image may be
+// of a different size than the PPU frame, and is reused between the calls.
+type FrameFilter interface {
+	Apply(indices []uint16) []color.RGBA
+}
+
+var (
+	_ FrameFilter = (*PaletteFilter)(nil)
+)
+
+// PaletteFilter is the basic FrameFilter that looks up the colors in a palette.
+type PaletteFilter struct {
+	palette *Palette
+	frame   []color.RGBA
+}
+
+func NewPaletteFilter(pal *Palette) *PaletteFilter {
+	return &PaletteFilter{
+		palette: pal,
+		frame:   make([]color.RGBA, FrameWidth*FrameHeight),
+	}
+}
+
+func (f *PaletteFilter) Apply(indices []uint16) []color.RGBA {
+	for i, idx := range indices {
+		f.frame[i] = f.palette[idx&0x1FF]
+	}
+
+	return f.frame
+}
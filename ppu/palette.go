@@ -0,0 +1,212 @@
+package ppu
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+var ErrInvalidPalette = errors.New("invalid palette file")
+
+// ParsePalette parses a .pal file in the format used by FCEUX, Mesen and most
+// other emulators: a list of RGB triplets, either the 64 base colors, or all
+// 512 colors with the emphasis variations. The emphasis is approximated for
+// the files that only have the base colors.
+func ParsePalette(data []byte) (*Palette, error) {
+	switch len(data) {
+	case 64 * 3:
+		var base [64]color.RGBA
+
+		for i := range base {
+			base[i] = color.RGBA{R: data[i*3], G: data[i*3+1], B: data[i*3+2], A: 0xFF}
+		}
+
+		return emphasize(base), nil
+
+	case 512 * 3:
+		var pal Palette
+
+		for i := range pal {
+			pal[i] = color.RGBA{R: data[i*3], G: data[i*3+1], B: data[i*3+2], A: 0xFF}
+		}
+
+		return &pal, nil
+
+	default:
+		return nil, fmt.Errorf("%w: expected 192 or 1536 bytes, got %d", ErrInvalidPalette, len(data))
+	}
+}
+
+// NTSCParams are the knobs of the palette generator, similar to the ones of a
+// TV set. Zero hue, brightness and unit saturation and contrast is the picture
+// as decoded, without any adjustments.
+type NTSCParams struct {
+	Hue        float64 // hue rotation in degrees
+	Saturation float64 // multiplier of the chroma
+	Contrast   float64 // multiplier of the luma
+	Brightness float64 // added to the luma, 0 to 1
+}
+
+// DefaultNTSCParams are the parameters of the "ntsc" palette.
+var DefaultNTSCParams = NTSCParams{
+	Hue:        0,
+	Saturation: 1,
+	Contrast:   1,
+	Brightness: 0,
+}
+
+// Voltage levels of the NTSC PPU video signal, for each of the 4 luma levels.
+// https://www.nesdev.org/wiki/NTSC_video
+var (
+	signalLow  = [4]float64{0.350, 0.518, 0.962, 1.550}
+	signalHigh = [4]float64{1.094, 1.506, 1.962, 1.962}
+)
+
+const (
+	signalBlack       = 0.518
+	signalWhite       = 1.962
+	signalAttenuation = 0.746
+)
+
+// GeneratePalette generates the palette by simulating the composite signal
+// of the NTSC PPU. The 12 samples of one color cycle are decoded to YIQ the way
+// a TV would, and then converted to RGB. Unlike the files, the generated palette
+// has the exact emphasis colors, as the emphasis attenuates the signal during
+// the corresponding part of the color cycle.
+func GeneratePalette(params NTSCParams) *Palette {
+	var pal Palette
+
+	for i := range pal {
+		var y, iq, q float64
+
+		for phase := 0; phase < 12; phase++ {
+			v := CompositeSignal(uint16(i), phase) / 12
+			ci, cq := params.Carrier(phase)
+
+			y += v
+			iq += v * ci
+			q += v * cq
+		}
+
+		pal[i] = params.RGB(y, iq, q)
+	}
+
+	return &pal
+}
+
+// CompositeSignal returns the level of the NTSC video signal produced by the
+// PPU for the color index (emphasis<<6 | color) at the given phase of the color
+// subcarrier (0-11), where 0 is black and 1 is white. Each color is a square
+// wave, alternating between the low and the high voltage of its luma level,
+// with the hue defining the phase of the wave.
+func CompositeSignal(idx uint16, phase int) float64 {
+	var (
+		col      = int(idx & 0x0F)
+		level    = int(idx >> 4 & 0x03)
+		emphasis = idx >> 6
+	)
+
+	// Colors $xE-$xF are always black.
+	if col > 13 {
+		level = 1
+	}
+
+	low, high := signalLow[level], signalHigh[level]
+
+	// Grays ($x0 and $xD) are a flat signal.
+	if col == 0 {
+		low = high
+	}
+	if col > 12 {
+		high = low
+	}
+
+	inPhase := func(col int) bool {
+		return (col+phase)%12 < 6
+	}
+
+	signal := low
+	if inPhase(col) {
+		signal = high
+	}
+
+	if emphasis&0x01 != 0 && inPhase(0xC) ||
+		emphasis&0x02 != 0 && inPhase(0x4) ||
+		emphasis&0x04 != 0 && inPhase(0x8) {
+		signal *= signalAttenuation
+	}
+
+	return (signal - signalBlack) / (signalWhite - signalBlack)
+}
+
+// Carrier returns the reference I and Q carriers the signal is multiplied by at
+// the given phase to demodulate the chroma. The demodulation is offset by 4
+// samples (120 degrees) from the PPU phase, which gives the hues of a properly
+// tuned TV.
+func (p NTSCParams) Carrier(phase int) (i, q float64) {
+	angle := math.Pi * (float64(phase+4) + p.Hue/30) / 6
+	return math.Cos(angle), math.Sin(angle)
+}
+
+// RGB converts the decoded YIQ color to RGB, applying the adjustments.
+func (p NTSCParams) RGB(y, i, q float64) color.RGBA {
+	y = y*p.Contrast + p.Brightness
+	i *= p.Saturation
+	q *= p.Saturation
+
+	return color.RGBA{
+		R: colorChannel(y + 0.946882*i + 0.623557*q),
+		G: colorChannel(y - 0.274788*i - 0.635691*q),
+		B: colorChannel(y - 1.108545*i + 1.709007*q),
+		A: 0xFF,
+	}
+}
+
+func colorChannel(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 1:
+		return 0xFF
+	default:
+		return uint8(v*255 + 0.5)
+	}
+}
+
+var builtinPalettes = []struct {
+	name    string
+	palette func() *Palette
+}{
+	{"default", DefaultPalette},
+	{"ntsc", func() *Palette {
+		return GeneratePalette(DefaultNTSCParams)
+	}},
+	{"ntsc-vivid", func() *Palette {
+		return GeneratePalette(NTSCParams{Saturation: 1.4, Contrast: 1.1})
+	}},
+	{"ntsc-muted", func() *Palette {
+		return GeneratePalette(NTSCParams{Saturation: 0.7, Contrast: 0.95, Brightness: 0.03})
+	}},
+}
+
+// PaletteNames returns the names of the built-in palettes.
+func PaletteNames() []string {
+	names := make([]string, len(builtinPalettes))
+	for i, p := range builtinPalettes {
+		names[i] = p.name
+	}
+
+	return names
+}
+
+// LookupPalette returns the built-in palette with the given name.
+func LookupPalette(name string) (*Palette, bool) {
+	for _, p := range builtinPalettes {
+		if p.name == name {
+			return p.palette(), true
+		}
+	}
+
+	return nil, false
+}
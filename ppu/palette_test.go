@@ -0,0 +1,88 @@
+package ppu
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func TestParsePalette(t *testing.T) {
+	data := make([]byte, 64*3)
+	data[0x16*3] = 0xB5
+	data[0x16*3+1] = 0x31
+	data[0x16*3+2] = 0x20
+
+	pal, err := ParsePalette(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, pal[0x16], color.RGBA{R: 0xB5, G: 0x31, B: 0x20, A: 0xFF})
+	testutil.Equal(t, pal[1<<6|0x16].R, 0xB5)
+	testutil.Equal(t, pal[1<<6|0x16].G < 0x31, true)
+
+	data = make([]byte, 512*3)
+	data[(4<<6|0x16)*3+2] = 0xFF
+
+	pal, err = ParsePalette(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, pal[4<<6|0x16], color.RGBA{B: 0xFF, A: 0xFF})
+
+	_, err = ParsePalette(make([]byte, 100))
+	testutil.Equal(t, errors.Is(err, ErrInvalidPalette), true)
+}
+
+func TestGeneratePalette(t *testing.T) {
+	pal := GeneratePalette(DefaultNTSCParams)
+
+	testutil.Equal(t, pal[0x0F], color.RGBA{A: 0xFF})
+	testutil.Equal(t, pal[0x20], color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF})
+
+	// Blue, red and green hues are where they should be.
+	testutil.Equal(t, pal[0x12].B > pal[0x12].R && pal[0x12].B > pal[0x12].G, true)
+	testutil.Equal(t, pal[0x16].R > pal[0x16].G && pal[0x16].R > pal[0x16].B, true)
+	testutil.Equal(t, pal[0x1A].G > pal[0x1A].R && pal[0x1A].G > pal[0x1A].B, true)
+
+	// Red emphasis attenuates the signal, except for the reddish part.
+	c, e := pal[0x30], pal[1<<6|0x30]
+	testutil.Equal(t, e.R > e.G && e.R > e.B, true)
+	testutil.Equal(t, e.G < c.G, true)
+
+	// The saturation of zero gives shades of gray.
+	gray := GeneratePalette(NTSCParams{Contrast: 1})
+	testutil.Equal(t, gray[0x16].R, gray[0x16].G)
+	testutil.Equal(t, gray[0x16].G, gray[0x16].B)
+}
+
+func TestPaletteFilter(t *testing.T) {
+	pal := GeneratePalette(DefaultNTSCParams)
+	f := NewPaletteFilter(pal)
+
+	indices := make([]uint16, FrameWidth*FrameHeight)
+	indices[1] = 0x16
+	indices[2] = 4<<6 | 0x16
+
+	frame := f.Apply(indices)
+	testutil.Equal(t, len(frame), FrameWidth*FrameHeight)
+	testutil.Equal(t, frame[0], pal[0x00])
+	testutil.Equal(t, frame[1], pal[0x16])
+	testutil.Equal(t, frame[2], pal[4<<6|0x16])
+}
+
+func TestLookupPalette(t *testing.T) {
+	for _, name := range PaletteNames() {
+		_, ok := LookupPalette(name)
+		testutil.Equal(t, ok, true)
+	}
+
+	pal, _ := LookupPalette("default")
+	testutil.Equal(t, *pal, *DefaultPalette())
+
+	_, ok := LookupPalette("unknown")
+	testutil.Equal(t, ok, false)
+}
@@ -2,9 +2,9 @@ package ppu
 
 import (
 	"fmt"
-	"image/color"
 	"log"
 
+	"github.com/maxpoletaev/dendy/consts"
 	"github.com/maxpoletaev/dendy/ines"
 )
 
@@ -50,11 +50,12 @@ type (
 )
 
 type PPU struct {
-	Frame       []color.RGBA // 256*240
-	transparent []bool       // 256*240
+	Indices     []uint16 // 256*240, emphasis<<6 | color, see FrameFilter
+	transparent []bool   // 256*240
 
 	NoSpriteLimit    bool
 	FastForward      bool
+	DotRendering     bool // see dot.go
 	PendingNMI       bool
 	ScanlineComplete bool
 	FrameComplete    bool
@@ -65,9 +66,13 @@ type PPU struct {
 	status       StatusFlags    // $2002
 	oamAddr      uint8          // $2003
 	oamData      [256]byte      // $2004
-	nameTable    [2][1024]byte  // $2000-$2FFF
+	nameTable    [4][1024]byte  // $2000-$2FFF, the last two are four-screen VRAM on the cartridge
 	paletteTable [32]byte       // $3F00-$3FFF
 
+	bus       ines.PPUBus    // optional, see ines.PPUBus
+	busEvents ines.PPUEvent  // the events the cartridge wants to see
+	fetchKind ines.FetchKind // what is being fetched at the moment
+
 	vramAddr   vramAddr
 	tmpAddr    vramAddr
 	vramBuffer uint8
@@ -78,17 +83,123 @@ type PPU struct {
 	spriteCount    int
 	spriteScanline [64]Sprite
 
+	// Dot renderer state, see dot.go.
+	bgTile      uint8
+	bgAttr      uint8
+	bgLo        uint8
+	bgHi        uint8
+	bgShiftLo   uint16
+	bgShiftHi   uint16
+	bgAttrLo    uint16
+	bgAttrHi    uint16
+	oamLatch    uint8
+	evalSprites [64]uint8 // OAM indices of the sprites found for the next scanline
+	evalCount   int
+	evalN       int
+	evalM       int
+	evalDone    bool
+
 	cycle       int
 	scanline    int
+	timing      consts.Timing
 	dmaCallback dmaFunc
 }
 
 func New(cart ines.Cartridge) *PPU {
-	return &PPU{
+	p := &PPU{
 		cart:        cart,
+		timing:      consts.RegionNTSC.Timing(),
 		transparent: make([]bool, FrameWidth*FrameHeight),
-		Frame:       make([]color.RGBA, FrameWidth*FrameHeight),
+		Indices:     make([]uint16, FrameWidth*FrameHeight),
+	}
+
+	// The cartridge is connected to the PPU bus once, so that the mappers that
+	// don't need to see it do not slow down rendering.
+	if bus, ok := ines.As[ines.PPUBus](cart); ok {
+		p.bus = bus
+		p.busEvents = bus.ConnectPPU(p.ciram())
+	}
+
+	return p
+}
+
+// watches tells whether the cartridge wants to see the given bus event.
+func (p *PPU) watches(event ines.PPUEvent) bool {
+	return p.busEvents&event != 0
+}
+
+// notifyBus reports a bus event to the cartridge, if it has asked for it.
+func (p *PPU) notifyBus(event ines.PPUEvent, addr uint16, dot int) {
+	if p.busEvents&event != 0 {
+		p.bus.PPUAccess(ines.BusAccess{
+			Event:       event,
+			Kind:        p.fetchKind,
+			Addr:        addr,
+			Scanline:    p.scanline,
+			Dot:         dot,
+			TallSprites: p.getCtrl(CtrlSpriteSize),
+		})
+	}
+}
+
+// notifyFetch tells the cartridge what the PPU is about to fetch.
+func (p *PPU) notifyFetch(kind ines.FetchKind) {
+	p.fetchKind = kind
+	p.notifyBus(ines.EventFetchStart, 0, p.cycle)
+}
+
+// notifyA12 tells the cartridge about a CPU access to the PPU address space
+// when the address has A12 set. Rendering fetches are reported by tickA12.
+func (p *PPU) notifyA12(addr uint16) {
+	if addr&0x1000 != 0 {
+		p.notifyBus(ines.EventA12, addr, p.cycle)
+	}
+}
+
+// notifyPattern tells the cartridge that a tile row has been fetched during
+// rendering. addr is the address of its high bitplane.
+func (p *PPU) notifyPattern(addr uint16) {
+	p.notifyBus(ines.EventPattern, addr, p.cycle)
+}
+
+// tickA12 reports the pattern fetches from $1000-$1FFF to the cartridge. Since
+// the scanline is rendered at once, it is based on the fetch timing of the real
+// PPU rather than on the actual reads: every 8 dots there is a pattern fetch
+// on dots 1-256 and 321-336 for the background, and on dots 257-320 for the
+// eight sprite slots of the next scanline. Empty slots fetch tile $FF.
+func (p *PPU) tickA12() {
+	dot := p.cycle
+	if dot == 0 || dot > 336 || dot%8 != 5 {
+		return
+	}
+
+	var high bool
+
+	if dot >= 257 && dot <= 320 {
+		slot := (dot - 257) / 8
+
+		switch {
+		case !p.getCtrl(CtrlSpriteSize):
+			high = p.getCtrl(CtrlSpritePatternAddr)
+		case slot < p.spriteCount:
+			// In 8x16 mode, the table is selected by the tile number.
+			high = p.oamData[p.spriteScanline[slot].Index*4+1]&0x01 != 0
+		default:
+			high = true
+		}
+	} else {
+		high = p.getCtrl(CtrlPatternTableSelect)
 	}
+
+	if high {
+		p.notifyBus(ines.EventA12, 0x1000, dot)
+	}
+}
+
+// SetRegion sets the video timing (number of scanlines, vblank position) to
+// the one of the given region. Should be followed by a reset.
+func (p *PPU) SetRegion(region consts.Region) {
+	p.timing = region.Timing()
 }
 
 func (p *PPU) Reset() {
@@ -158,6 +269,8 @@ func (p *PPU) Read(addr uint16) uint8 {
 		}
 		return data
 	case 0x2007:
+		p.notifyA12(uint16(p.vramAddr))
+
 		if p.vramAddr >= 0x3F00 {
 			// Palette reads are not delayed.
 			data := p.readVRAM(uint16(p.vramAddr))
@@ -203,15 +316,17 @@ func (p *PPU) Write(addr uint16, data uint8) {
 		}
 	case 0x2006:
 		if !p.addrLatch {
-			p.tmpAddr = vramAddr(data)
+			p.tmpAddr = p.tmpAddr&0x00FF | vramAddr(data&0x3F)<<8
 			p.addrLatch = true
 		} else {
-			p.vramAddr = p.tmpAddr<<8 | vramAddr(data)
+			p.tmpAddr = p.tmpAddr&0xFF00 | vramAddr(data)
+			p.vramAddr = p.tmpAddr
 			p.addrLatch = false
-			p.tmpAddr = 0
+			p.notifyA12(uint16(p.vramAddr))
 		}
 	case 0x2007:
 		writeAddr := uint16(p.vramAddr) % 0x4000
+		p.notifyA12(writeAddr)
 		p.writeVRAM(writeAddr, data)
 		p.incrementAddr()
 	}
@@ -226,7 +341,13 @@ func (p *PPU) TransferOAM(pageAddr uint8) {
 	p.dmaCallback(addr, p.oamData[:])
 }
 
-// nameTableIdx returns the index of the nametable (0 or 1) for the given vram
+// ciram returns the console’s internal 2 KB of VRAM, without the four-screen
+// part, which is what the mappers with custom nametable mapping work with.
+func (p *PPU) ciram() *[2][1024]byte {
+	return (*[2][1024]byte)(p.nameTable[:2])
+}
+
+// nameTableIdx returns the index of the nametable (0-3) for the given vram
 // address, based on the cartridge’s mirroring mode.
 func (p *PPU) nameTableIdx(addr uint16) uint {
 	var (
@@ -253,6 +374,8 @@ func (p *PPU) nameTableIdx(addr uint16) uint {
 		return 0
 	case ines.MirrorSingle1:
 		return 1
+	case ines.MirrorFourScreen:
+		return uint(idx)
 	default:
 		panic(fmt.Sprintf("invalid mirroring mode: %d", mode))
 	}
@@ -267,6 +390,16 @@ func (p *PPU) readVRAM(addr uint16) uint8 {
 		return p.cart.ReadCHR(addr)
 	case addr <= 0x3EFF:
 		addr = addr & 0x2FFF
+		if p.watches(ines.EventNametableRead) {
+			return p.bus.PPUAccess(ines.BusAccess{
+				Event:    ines.EventNametableRead,
+				Kind:     p.fetchKind,
+				Addr:     addr,
+				Scanline: p.scanline,
+				Dot:      p.cycle,
+			})
+		}
+
 		idx := p.nameTableIdx(addr)
 		return p.nameTable[idx][addr%1024]
 	case addr <= 0x3FFF:
@@ -294,6 +427,18 @@ func (p *PPU) writeVRAM(addr uint16, data uint8) {
 		p.cart.WriteCHR(addr, data)
 	case addr <= 0x3EFF:
 		addr = addr & 0x2FFF
+		if p.watches(ines.EventNametableWrite) {
+			p.bus.PPUAccess(ines.BusAccess{
+				Event:    ines.EventNametableWrite,
+				Kind:     p.fetchKind,
+				Addr:     addr,
+				Data:     data,
+				Scanline: p.scanline,
+				Dot:      p.cycle,
+			})
+			return
+		}
+
 		idx := p.nameTableIdx(addr)
 		p.nameTable[idx][addr%1024] = data
 	case addr <= 0x3FFF:
@@ -309,33 +454,67 @@ func (p *PPU) writeVRAM(addr uint16, data uint8) {
 }
 
 // clearFrame fills the frame with the given color.
-func (p *PPU) clearFrame(c color.RGBA) {
+func (p *PPU) clearFrame(idx uint16) {
 	if p.FastForward {
 		return
 	}
 
-	p.Frame[0] = c
+	p.Indices[0] = idx
 	p.transparent[0] = false
 
 	// Incremental copy optimization.
 	// See https://gist.github.com/taylorza/df2f89d5f9ab3ffd06865062a4cf015d
-	for i := 1; i < len(p.Frame); i *= 2 {
-		copy(p.Frame[i:], p.Frame[:i])
+	for i := 1; i < len(p.Indices); i *= 2 {
+		copy(p.Indices[i:], p.Indices[:i])
 		copy(p.transparent[i:], p.transparent[:i])
 	}
 }
 
-func (p *PPU) backdropColor() color.RGBA {
-	idx := p.readVRAM(0x3F00)
-	return Colors[idx]
+// clearScanline fills the current scanline with the given color.
+func (p *PPU) clearScanline(idx uint16) {
+	row := p.scanline * FrameWidth
+
+	for x := 0; x < FrameWidth; x++ {
+		p.Indices[row+x] = idx
+		p.transparent[row+x] = true
+	}
+}
+
+// paletteIndex returns the output color of the given palette entry, as an index
+// in the Palette. Grayscale is applied when the palette is read, and the color
+// emphasis bits select one of the eight sets of colors.
+func (p *PPU) paletteIndex(addr uint16) uint16 {
+	idx := uint16(p.readVRAM(addr) & 0x3F)
+	emphasis := uint16(p.mask >> 5)
+
+	if p.timing.SwapEmphasis {
+		emphasis = emphasis&0x04 | emphasis&0x01<<1 | emphasis&0x02>>1
+	}
+
+	return emphasis<<6 | idx
+}
+
+func (p *PPU) backdropIndex() uint16 {
+	return p.paletteIndex(0x3F00)
 }
 
 func (p *PPU) renderScanline() {
 	if p.FastForward {
+		// Nothing is drawn, but the pattern fetches are still reported.
+		if p.watches(ines.EventPattern) && p.getMask(MaskShowBackground) {
+			p.notifyFetch(ines.FetchBackground)
+			p.skipTileScanline()
+		}
+
 		return
 	}
 
+	// The backdrop is drawn for every scanline, since the mask (and thus
+	// the emphasis) can change between them.
+	p.clearScanline(p.backdropIndex())
+
 	if p.getMask(MaskShowBackground) {
+		p.notifyFetch(ines.FetchBackground)
 		p.renderTileScanline()
 	}
 
@@ -364,24 +543,35 @@ func (p *PPU) fastSpriteZeroHit() bool {
 	}
 
 	pixelX, pixelY := frameX%8, frameY%8
+
+	// This is not a real fetch, so the cartridge is not told about it, and the
+	// mappers that track the nametable reads (MMC5) must not count it either.
+	kind := p.fetchKind
+	p.fetchKind = ines.FetchPeek
 	spritePixel := p.fetchSpritePixel(0, frameX-spriteX, frameY-spriteY)
-	tilePixel := p.fetchTileScanline(frameX/8, frameY/8, pixelY).Pixels[pixelX]
+	tile, _ := p.readTileScanline(frameX/8, frameY/8, pixelY)
+	tilePixel := tile.Pixels[pixelX]
+	p.fetchKind = kind
 
 	return spritePixel != 0 && tilePixel != 0
 }
 
 func (p *PPU) Tick() {
-	// Pre-render + visible scanlines.
-	if p.scanline >= -1 && p.scanline <= 238 {
+	if p.DotRendering {
+		if p.scanline >= -1 && p.scanline < FrameHeight {
+			p.tickDot()
+		}
+	} else if p.scanline >= -1 && p.scanline <= 238 {
+		// Pre-render + visible scanlines.
 		if p.scanline == -1 && p.cycle == 1 {
 			p.setStatus(StatusSpriteOverflow, false)
 			p.setStatus(StatusSpriteZeroHit, false)
 			p.setStatus(StatusVBlank, false)
-			p.clearFrame(p.backdropColor())
+			p.clearFrame(p.backdropIndex())
 		}
 
-		// Skip the first cycle of the first scanline on odd frames.
-		if p.scanline == 0 {
+		// Skip the first cycle of the first scanline on odd frames (NTSC only).
+		if p.scanline == 0 && p.timing.SkipOddFrameDot {
 			if p.cycle == 0 && p.oddFrame {
 				p.cycle = 1
 			}
@@ -421,6 +611,7 @@ func (p *PPU) Tick() {
 			}
 
 			if p.renderingEnabled() {
+				p.notifyFetch(ines.FetchSprite)
 				p.evaluateSprites()
 			}
 
@@ -445,10 +636,19 @@ func (p *PPU) Tick() {
 		}
 	}
 
-	// Start of vertical blank.
-	if p.scanline == 241 {
+	// Pattern fetches for the cartridges watching A12 (MMC3).
+	if p.watches(ines.EventA12) && p.scanline >= -1 && p.scanline <= 239 {
+		if p.renderingEnabled() {
+			p.tickA12()
+		}
+	}
+
+	// Start of vertical blank. Dendy has 50 extra post-render scanlines
+	// before the vblank, while PAL has them after.
+	if p.scanline == p.timing.VBlankScanline {
 		if p.cycle == 1 {
 			p.setStatus(StatusVBlank, true)
+			p.notifyFetch(ines.FetchIdle)
 			p.FrameComplete = true
 
 			if p.getCtrl(CtrlNMI) {
@@ -462,7 +662,7 @@ func (p *PPU) Tick() {
 		p.cycle = 0
 		p.scanline++
 
-		if p.scanline == 261 {
+		if p.scanline == p.timing.Scanlines-1 {
 			p.oddFrame = !p.oddFrame
 			p.scanline = -1
 		}
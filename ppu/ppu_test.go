@@ -0,0 +1,138 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/maxpoletaev/dendy/ines"
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func newTestPPU() *PPU {
+	rom := &ines.ROM{
+		PRG:        make([]byte, 0x4000),
+		CHR:        make([]byte, 0x2000),
+		MirrorMode: ines.MirrorVertical,
+	}
+
+	p := New(ines.NewMapper0(rom))
+	p.Reset()
+
+	return p
+}
+
+func TestPPU_WriteAddr(t *testing.T) {
+	p := newTestPPU()
+
+	p.Write(0x2006, 0x21)
+	p.Write(0x2006, 0x08)
+	testutil.Equal(t, p.vramAddr, 0x2108)
+	testutil.Equal(t, p.tmpAddr, 0x2108)
+
+	// The highest bit of the 15-bit address is cleared by the first write.
+	p.Write(0x2006, 0xFF)
+	p.Write(0x2006, 0x00)
+	testutil.Equal(t, p.vramAddr, 0x3F00)
+
+	// The temporary address is shared with $2000, so the nametable bits
+	// written in between the two writes end up in the address.
+	p.Write(0x2006, 0x2C)
+	p.Write(0x2000, 0x01)
+	p.Write(0x2006, 0x40)
+	testutil.Equal(t, p.vramAddr, 0x2440)
+}
+
+type patternRecorder struct {
+	*ines.Mapper0
+	fetches []uint16
+}
+
+func (r *patternRecorder) ConnectPPU(ciram *[2][1024]byte) ines.PPUEvent {
+	return ines.EventPattern
+}
+
+func (r *patternRecorder) PPUAccess(a ines.BusAccess) byte {
+	r.fetches = append(r.fetches, a.Addr)
+	return 0
+}
+
+func TestPPU_PatternFetchFastForward(t *testing.T) {
+	record := func(fastForward bool) []uint16 {
+		rom := &ines.ROM{
+			PRG:        make([]byte, 0x4000),
+			CHR:        make([]byte, 0x2000),
+			MirrorMode: ines.MirrorVertical,
+		}
+
+		cart := &patternRecorder{Mapper0: ines.NewMapper0(rom)}
+		p := New(cart)
+		p.FastForward = fastForward
+		p.Reset()
+
+		for i := range p.nameTable[0] {
+			p.nameTable[0][i] = uint8(i)
+		}
+
+		p.oamData[0] = 20 // sprite zero on scanlines 21-28
+		p.oamData[3] = 0  // left edge, so the probe runs on every line
+		p.fineX = 3
+		p.mask = MaskShowBackground | MaskShowSprites
+
+		for !p.FrameComplete {
+			p.Tick()
+		}
+
+		return cart.fetches
+	}
+
+	normal := record(false)
+	fast := record(true)
+
+	testutil.Equal(t, len(fast), len(normal))
+	for i := range normal {
+		testutil.Equal(t, fast[i], normal[i])
+	}
+}
+
+func TestPPU_FastSpriteZeroHitPeek(t *testing.T) {
+	header := []byte{'N', 'E', 'S', 0x1A, 2, 1, 0x50, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	data := append(header, make([]byte, 0x8000+0x2000)...)
+
+	rom, err := ines.NewFromBuffer(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := ines.NewCartridge(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cart.Reset()
+	p := New(cart)
+	p.Reset()
+
+	// Vertical split on the leftmost tile, which shows the ExRAM tiles.
+	cart.WritePRG(0x5104, 0x02)
+	cart.WritePRG(0x5C00, 0x22)
+	cart.WritePRG(0x5C01, 0x33)
+	cart.WritePRG(0x5104, 0x00)
+	cart.WritePRG(0x5200, 0x81)
+
+	for i := range p.nameTable[0] {
+		p.nameTable[0][i] = 0x11
+	}
+
+	p.notifyFetch(ines.FetchBackground)
+	testutil.Equal(t, p.readVRAM(0x2000), 0x22)
+	testutil.Equal(t, p.readVRAM(0x2001), 0x11)
+
+	// The probe reads the first tile in the middle of the background fetches,
+	// which must not move MMC5 to the next tile.
+	p.notifyFetch(ines.FetchBackground)
+	p.oamData[0], p.oamData[3] = 0, 0
+	p.scanline, p.cycle = 1, 0
+	p.fastSpriteZeroHit()
+
+	testutil.Equal(t, p.readVRAM(0x2000), 0x22)
+	testutil.Equal(t, p.readVRAM(0x2001), 0x11)
+}
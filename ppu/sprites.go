@@ -1,7 +1,7 @@
 package ppu
 
 import (
-	"image/color"
+	"github.com/maxpoletaev/dendy/ines"
 )
 
 const (
@@ -100,6 +100,7 @@ func (p *PPU) fetchSpriteScanline(idx int, y int) Sprite {
 	addr := p.spriteAddr(tableAddr, spriteID, y, height)
 	p1 := p.readVRAM(addr + 0)
 	p2 := p.readVRAM(addr + 8)
+	p.notifyPattern(addr + 8)
 
 	for x := 0; x < 8; x++ {
 		px := p1 & (0x80 >> x) >> (7 - x) << 0
@@ -144,13 +145,21 @@ func (p *PPU) evaluateSprites() {
 
 		p.spriteCount++
 	}
+
+	// The PPU still fetches tile $FF for the empty sprite slots, which
+	// matters for the mappers that watch the fetched tiles.
+	if p.watches(ines.EventPattern) {
+		addr := p.spriteAddr(p.spritePatternTableOffset(), 0xFF, 0, height)
+		for i := p.spriteCount; i < 8; i++ {
+			p.notifyPattern(addr + 8)
+		}
+	}
 }
 
-// readSpriteColor returns the RGBA color for the given pixel value and palette ID.
-func (p *PPU) readSpriteColor(pixel, paletteID uint8) color.RGBA {
+// readSpriteColor returns the color index for the given pixel value and palette ID.
+func (p *PPU) readSpriteColor(pixel, paletteID uint8) uint16 {
 	colorAddr := 0x3F10 + uint16(paletteID)*4 + uint16(pixel)
-	colorIdx := p.readVRAM(colorAddr)
-	return Colors[colorIdx%64]
+	return p.paletteIndex(colorAddr)
 }
 
 // renderSpriteScanline renders the sprites currently in the p.spriteScanline array.
@@ -185,7 +194,7 @@ func (p *PPU) renderSpriteScanline() {
 				continue
 			}
 
-			p.Frame[frameY*FrameWidth+frameX] = p.readSpriteColor(
+			p.Indices[frameY*FrameWidth+frameX] = p.readSpriteColor(
 				sprite.Pixels[pixelX],
 				sprite.PaletteID,
 			)
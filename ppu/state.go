@@ -23,19 +23,38 @@ func (p *PPU) SaveState(w *binario.Writer) error {
 		w.WriteUint8(p.fineX),
 		w.WriteByteSlice(p.nameTable[0][:]),
 		w.WriteByteSlice(p.nameTable[1][:]),
+		w.WriteByteSlice(p.nameTable[2][:]),
+		w.WriteByteSlice(p.nameTable[3][:]),
 		w.WriteByteSlice(p.paletteTable[:]),
 		w.WriteUint64(uint64(p.cycle)),
 		w.WriteUint64(uint64(p.scanline)),
 		w.WriteBool(p.oddFrame),
+		w.WriteUint8(p.bgTile),
+		w.WriteUint8(p.bgAttr),
+		w.WriteUint8(p.bgLo),
+		w.WriteUint8(p.bgHi),
+		w.WriteUint16(p.bgShiftLo),
+		w.WriteUint16(p.bgShiftHi),
+		w.WriteUint16(p.bgAttrLo),
+		w.WriteUint16(p.bgAttrHi),
+		w.WriteUint8(p.oamLatch),
+		w.WriteByteSlice(p.evalSprites[:]),
+		w.WriteUint8(uint8(p.evalCount)),
+		w.WriteUint8(uint8(p.evalN)),
+		w.WriteUint8(uint8(p.evalM)),
+		w.WriteBool(p.evalDone),
 	)
 }
 
 func (p *PPU) LoadState(r *binario.Reader) error {
 	var (
-		currAddr uint16
-		tmpAddr  uint16
-		cycle    uint64
-		scanline uint64
+		currAddr  uint16
+		tmpAddr   uint16
+		cycle     uint64
+		scanline  uint64
+		evalCount uint8
+		evalN     uint8
+		evalM     uint8
 	)
 
 	err := errors.Join(
@@ -54,16 +73,35 @@ func (p *PPU) LoadState(r *binario.Reader) error {
 		r.ReadUint8To(&p.fineX),
 		r.ReadByteSliceTo(p.nameTable[0][:]),
 		r.ReadByteSliceTo(p.nameTable[1][:]),
+		r.ReadByteSliceTo(p.nameTable[2][:]),
+		r.ReadByteSliceTo(p.nameTable[3][:]),
 		r.ReadByteSliceTo(p.paletteTable[:]),
 		r.ReadUint64To(&cycle),
 		r.ReadUint64To(&scanline),
 		r.ReadBoolTo(&p.oddFrame),
+		r.ReadUint8To(&p.bgTile),
+		r.ReadUint8To(&p.bgAttr),
+		r.ReadUint8To(&p.bgLo),
+		r.ReadUint8To(&p.bgHi),
+		r.ReadUint16To(&p.bgShiftLo),
+		r.ReadUint16To(&p.bgShiftHi),
+		r.ReadUint16To(&p.bgAttrLo),
+		r.ReadUint16To(&p.bgAttrHi),
+		r.ReadUint8To(&p.oamLatch),
+		r.ReadByteSliceTo(p.evalSprites[:]),
+		r.ReadUint8To(&evalCount),
+		r.ReadUint8To(&evalN),
+		r.ReadUint8To(&evalM),
+		r.ReadBoolTo(&p.evalDone),
 	)
 
 	p.vramAddr = vramAddr(currAddr)
 	p.tmpAddr = vramAddr(tmpAddr)
 	p.scanline = int(scanline)
 	p.cycle = int(cycle)
+	p.evalCount = int(evalCount)
+	p.evalN = int(evalN)
+	p.evalM = int(evalM)
 
 	return err
 }
@@ -1,9 +1,5 @@
 package ppu
 
-import (
-	"image/color"
-)
-
 type Tile struct {
 	Pixels    [8]uint8
 	PaletteID uint8
@@ -18,9 +14,19 @@ func (p *PPU) tilePatternTableOffset() uint16 {
 	return 0
 }
 
-// fetchTileLine fetches a 8x1 tile line from the pattern table.
-// We usually don’t need the full tile, just the line we’re currently rendering.
-func (p *PPU) fetchTileScanline(tileX, tileY, y int) (tile Tile) {
+// fetchTileScanline fetches a 8x1 tile line from the pattern table and reports
+// the fetch to the cartridge.
+func (p *PPU) fetchTileScanline(tileX, tileY, y int) Tile {
+	tile, addr := p.readTileScanline(tileX, tileY, y)
+	p.notifyPattern(addr)
+
+	return tile
+}
+
+// readTileScanline reads a 8x1 tile line from the pattern table, along with the
+// address of its high bitplane. We usually don’t need the full tile, just the
+// line we’re currently rendering.
+func (p *PPU) readTileScanline(tileX, tileY, y int) (tile Tile, addr uint16) {
 	nametableID := p.vramAddr.nametable()
 
 	if tileX >= 32 {
@@ -49,14 +55,29 @@ func (p *PPU) fetchTileScanline(tileX, tileY, y int) (tile Tile) {
 	blockID := uint16(tileX%4/2) + uint16(tileY%4/2)*2
 	tile.PaletteID = (attr >> (blockID * 2)) & 0x03
 
-	return tile
+	return tile, tileAddr + uint16(y) + 8
 }
 
-// readTileColor returns the color for the given pixel and palette ID.
-func (p *PPU) readTileColor(pixel, paletteID uint8) color.RGBA {
+// readTileColor returns the color index for the given pixel and palette ID.
+func (p *PPU) readTileColor(pixel, paletteID uint8) uint16 {
 	colorAddr := 0x3F00 + uint16(paletteID)*4 + uint16(pixel)
-	colorIdx := p.readVRAM(colorAddr)
-	return Colors[colorIdx%64]
+	return p.paletteIndex(colorAddr)
+}
+
+// skipTileScanline fetches the background tiles of the current scanline without
+// rendering them. Used in fast-forward for the mappers that watch the fetched
+// tiles (MMC2/MMC4), so that they end up in the same state.
+func (p *PPU) skipTileScanline() {
+	var (
+		scrollX = int(p.vramAddr.coarseX()*8 + uint16(p.fineX))
+		scrollY = p.vramAddr.coarseY()*8 + p.vramAddr.fineY()
+	)
+
+	tileY, pixelY := int(scrollY/8), int(scrollY%8)
+
+	for tileX := scrollX / 8; tileX <= (scrollX+255)/8; tileX++ {
+		p.fetchTileScanline(tileX, tileY, pixelY)
+	}
 }
 
 // renderTileScanline renders the current scanline using the background tiles.
@@ -75,10 +96,6 @@ func (p *PPU) renderTileScanline() {
 	)
 
 	for frameX := 0; frameX < 256; frameX++ {
-		if !showLeftTiles && frameX < 8 {
-			continue
-		}
-
 		scrolledX := frameX + int(scrollX)
 		pixelX := scrolledX % 8
 		tileX := scrolledX / 8
@@ -91,13 +108,19 @@ func (p *PPU) renderTileScanline() {
 			lastTileX = tileX
 		}
 
+		// The leftmost tiles are still fetched when hidden, since some
+		// mappers count the fetches to know the horizontal position.
+		if !showLeftTiles && frameX < 8 {
+			continue
+		}
+
 		pixel := tile.Pixels[pixelX]
 		if pixel == 0 {
 			p.transparent[frameY*FrameWidth+frameX] = true
 			continue
 		}
 
-		p.Frame[frameY*FrameWidth+frameX] = p.readTileColor(pixel, tile.PaletteID)
+		p.Indices[frameY*FrameWidth+frameX] = p.readTileColor(pixel, tile.PaletteID)
 		p.transparent[frameY*FrameWidth+frameX] = false
 	}
 }
@@ -73,10 +73,11 @@ func (c *Client) Close() error {
 	return c.relayConn.Close()
 }
 
-func (c *Client) CreateSession(romCRC32 uint32, public bool) (string, error) {
+func (c *Client) CreateSession(romCRC32 uint32, gameTitle string, public bool) (string, error) {
 	err := send(c.relayConn, &CreateSessionMsg{
-		RomCRC32: romCRC32,
-		Public:   public,
+		RomCRC32:  romCRC32,
+		GameTitle: gameTitle,
+		Public:    public,
 	})
 
 	if err != nil {
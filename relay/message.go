@@ -26,13 +26,15 @@ type Message interface {
 type HelloMsg struct{}
 
 type CreateSessionMsg struct {
-	RomCRC32 uint32
-	Public   bool
+	RomCRC32  uint32
+	GameTitle string
+	Public    bool
 }
 
 func (m *CreateSessionMsg) ToBytes(w *binario.Writer) error {
 	return errors.Join(
 		w.WriteUint32(m.RomCRC32),
+		w.WriteString(m.GameTitle),
 		w.WriteBool(m.Public),
 	)
 }
@@ -40,6 +42,7 @@ func (m *CreateSessionMsg) ToBytes(w *binario.Writer) error {
 func (m *CreateSessionMsg) FromBytes(r *binario.Reader) error {
 	return errors.Join(
 		r.ReadUint32To(&m.RomCRC32),
+		r.ReadStringTo(&m.GameTitle),
 		r.ReadBoolTo(&m.Public),
 	)
 }
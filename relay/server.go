@@ -95,6 +95,7 @@ func (s *Server) handleCreateSession(conn net.Conn, msg *CreateSessionMsg) {
 	session := NewSession()
 	session.HostAddr = *addr
 	session.RomCRC32 = msg.RomCRC32
+	session.GameTitle = msg.GameTitle
 
 	if ok := s.limiter.Acquire(addr.IP.String()); !ok {
 		log.Printf("[WARN] rate limited: %s", addr.IP.String())
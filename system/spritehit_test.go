@@ -0,0 +1,67 @@
+//go:build testrom
+
+package system
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/ines"
+	"github.com/maxpoletaev/dendy/input"
+	"github.com/maxpoletaev/dendy/internal/loglevel"
+)
+
+// The sprite_hit_tests_2005.10.05 ROMs by blargg are not included, copy the
+// .nes files into testdata/sprite_hit to run them. Each ROM writes its result
+// code to $00F8 when done: 1 means passed, anything else is the number of the
+// failed check.
+const (
+	spriteHitDir    = "testdata/sprite_hit"
+	spriteHitFrames = 600
+	spriteHitResult = 0x00F8
+)
+
+func TestSpriteHitROMs(t *testing.T) {
+	log.SetOutput(loglevel.New(os.Stderr, loglevel.LevelNone))
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	files, err := filepath.Glob(filepath.Join(spriteHitDir, "*.nes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Skipf("no test roms in %s", spriteHitDir)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			rom, err := ines.NewFromFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cart, err := ines.NewCartridge(rom)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nes := New(cart, input.NewJoystick(), input.NewJoystick())
+			nes.SetDotRendering(true)
+
+			for frames := 0; frames < spriteHitFrames; {
+				nes.Tick()
+
+				if nes.FrameReady() {
+					frames++
+				}
+			}
+
+			if code := nes.ram[spriteHitResult]; code != 1 {
+				t.Fatalf("failed with code %d", code)
+			}
+		})
+	}
+}
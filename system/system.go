@@ -11,6 +11,7 @@ import (
 	"time"
 
 	apupkg "github.com/maxpoletaev/dendy/apu"
+	"github.com/maxpoletaev/dendy/consts"
 	cpupkg "github.com/maxpoletaev/dendy/cpu"
 	"github.com/maxpoletaev/dendy/disasm"
 	"github.com/maxpoletaev/dendy/ines"
@@ -35,13 +36,19 @@ type System struct {
 	ppu   *ppupkg.PPU
 	apu   *apupkg.APU
 	cart  ines.Cartridge
+	clock ines.CPUTicker // optional, see ines.CPUTicker
+	irqs  bool           // poll the cartridge IRQ line on every CPU cycle
 	port1 input.Device
 	port2 input.Device
 
 	scanlineReady bool
 	frameReady    bool
 	cycles        uint64
+	cpuClock      int
+	region        consts.Region
+	timing        consts.Timing
 	debugWriter   io.StringWriter
+	frameFilter   ppupkg.FrameFilter
 
 	autoSaves      *ringbuf.Buffer[[]byte]
 	removedBuffers chan []byte
@@ -66,10 +73,25 @@ func New(cart ines.Cartridge, port1, port2 input.Device) *System {
 		port1:          port1,
 		port2:          port2,
 		bus:            newBus(ram, ppu, apu, cart, port1, port2),
+		timing:         consts.RegionNTSC.Timing(),
+		frameFilter:    ppupkg.NewPaletteFilter(ppupkg.DefaultPalette()),
 		autoSaves:      ringbuf.New[[]byte](maxAutoSaves),
 		removedBuffers: make(chan []byte, maxAutoSaves),
 	}
 
+	// Not every cartridge needs to be clocked, so only keep it for those that do,
+	// to avoid calling an empty method on every CPU cycle.
+	s.clock, _ = ines.As[ines.CPUTicker](cart)
+
+	// Cartridges with cycle-based IRQ counters, or the ones clocked by the PPU
+	// bus, may raise an interrupt at any time, not only at the end of a scanline.
+	_, onPPUBus := ines.As[ines.PPUBus](cart)
+	s.irqs = s.clock != nil || onPPUBus
+
+	if exp, ok := ines.As[apupkg.Expansion](cart); ok {
+		apu.SetExpansion(exp)
+	}
+
 	s.initDMACallbacks()
 
 	s.Reset()
@@ -110,10 +132,36 @@ func (s *System) Reset() {
 	s.cpu.Reset(s.bus)
 
 	s.cycles = 0
+	s.cpuClock = 0
 	s.frameReady = false
 	s.scanlineReady = false
 }
 
+// SetRegion switches the system to the timing of the given region
+// (NTSC, PAL or Dendy) and resets it.
+func (s *System) SetRegion(region consts.Region) {
+	s.region = region
+	s.timing = region.Timing()
+	s.ppu.SetRegion(region)
+	s.apu.SetRegion(region)
+	s.Reset()
+}
+
+// Region returns the region the system is running in.
+func (s *System) Region() consts.Region {
+	return s.region
+}
+
+// BatteryRAM returns the battery-backed memory of the cartridge, or nil if
+// the cartridge has no battery.
+func (s *System) BatteryRAM() []byte {
+	if b, ok := ines.As[ines.BatteryBacked](s.cart); ok {
+		return b.BatteryRAM()
+	}
+
+	return nil
+}
+
 func (s *System) disassemble() {
 	_, err1 := s.debugWriter.WriteString(disasm.DebugStep(s.bus, s.cpu))
 	_, err2 := s.debugWriter.WriteString("\n")
@@ -127,7 +175,12 @@ func (s *System) disassemble() {
 func (s *System) Tick() {
 	s.cycles++
 
-	if s.cycles%3 == 0 {
+	// The CPU is clocked once per 3 PPU cycles on NTSC/Dendy, and 5 times per
+	// 16 PPU cycles on PAL. Accumulate the fraction to know when it's time.
+	s.cpuClock += s.timing.CPUCycles
+
+	if s.cpuClock >= s.timing.PPUDots {
+		s.cpuClock -= s.timing.PPUDots
 		instructionComplete := s.cpu.Tick(s.bus)
 
 		if instructionComplete && s.debugWriter != nil {
@@ -136,6 +189,14 @@ func (s *System) Tick() {
 
 		s.apu.Tick()
 
+		if s.clock != nil {
+			s.clock.TickCPU()
+		}
+
+		if s.irqs && s.cart.PendingIRQ() {
+			s.cpu.TriggerIRQ()
+		}
+
 		if s.apu.PendingIRQ {
 			s.apu.PendingIRQ = false
 			s.cpu.TriggerIRQ()
@@ -182,6 +243,13 @@ func (s *System) SetNoSpriteLimit(v bool) {
 	s.ppu.NoSpriteLimit = v
 }
 
+// SetDotRendering switches the PPU between the scanline and the dot renderer.
+// The dot renderer is slower, but handles mid-scanline effects correctly. In
+// netplay, both sides must use the same renderer, or the games may desync.
+func (s *System) SetDotRendering(v bool) {
+	s.ppu.DotRendering = v
+}
+
 // ScanlineReady returns true if a scanline has just completed.
 func (s *System) ScanlineReady() (v bool) {
 	if s.scanlineReady {
@@ -200,10 +268,23 @@ func (s *System) FrameReady() (v bool) {
 	return false
 }
 
-// Frame returns a pointer to the current frame buffer.
-// The returned buffer is only valid until the next call to Tick.
+// Frame returns the current frame converted to RGB with the frame filter. The
+// conversion is done on every call, and the returned buffer is only valid until
+// the next one.
 func (s *System) Frame() []color.RGBA {
-	return s.ppu.Frame
+	return s.frameFilter.Apply(s.ppu.Indices)
+}
+
+// FrameIndices returns the current frame as the PPU color indices, see
+// ppu.FrameFilter. The returned buffer is only valid until the next call to Tick.
+func (s *System) FrameIndices() []uint16 {
+	return s.ppu.Indices
+}
+
+// SetFrameFilter sets the filter used by Frame to convert the frames to RGB.
+// By default, the colors are looked up in ppu.DefaultPalette.
+func (s *System) SetFrameFilter(f ppupkg.FrameFilter) {
+	s.frameFilter = f
 }
 
 // AudioSample returns the next audio sample from the APU.
@@ -245,6 +326,9 @@ func (s *System) LoadState(r *binario.Reader) error {
 		s.port2.LoadState(r),
 	)
 
+	// The CPU clock phase is not saved, since it can be derived from the cycle counter.
+	s.cpuClock = int(s.cycles * uint64(s.timing.CPUCycles) % uint64(s.timing.PPUDots))
+
 	return err
 }
 
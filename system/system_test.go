@@ -0,0 +1,78 @@
+package system
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxpoletaev/dendy/ines"
+	"github.com/maxpoletaev/dendy/input"
+	"github.com/maxpoletaev/dendy/internal/battery"
+	"github.com/maxpoletaev/dendy/internal/binario"
+	"github.com/maxpoletaev/dendy/internal/testutil"
+)
+
+func newTestSystem(t *testing.T, header []byte) *System {
+	t.Helper()
+
+	var (
+		prg = make([]byte, 0x4000)
+		chr = make([]byte, 0x2000)
+	)
+
+	rom, err := ines.NewFromBuffer(bytes.Join([][]byte{header, prg, chr}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := ines.NewCartridge(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(cart, input.NewJoystick(), input.NewJoystick())
+}
+
+// The save state has its own copy of the battery-backed RAM, and loading it
+// overrides the .sav file loaded before it. The .sav file then gets the RAM
+// from the state on the next flush.
+func TestSystem_LoadStateOverridesBatteryRAM(t *testing.T) {
+	header := []byte{'N', 'E', 'S', 0x1A, 1, 1, 0x12, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	savFile := filepath.Join(t.TempDir(), "game.sav")
+
+	nes := newTestSystem(t, header)
+	copy(nes.BatteryRAM(), bytes.Repeat([]byte{0xAA}, 0x2000))
+
+	var state bytes.Buffer
+	if err := nes.SaveState(binario.NewWriter(&state, binary.LittleEndian)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(savFile, bytes.Repeat([]byte{0x55}, 0x2000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	nes = newTestSystem(t, header)
+	sav := battery.New(nes.BatteryRAM(), battery.File(savFile))
+
+	ok, err := sav.Load()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, true)
+	testutil.Equal(t, nes.BatteryRAM()[0], 0x55)
+
+	if err := nes.LoadState(binario.NewReader(&state, binary.LittleEndian)); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.Equal(t, nes.BatteryRAM()[0], 0xAA)
+
+	ok, err = sav.Flush()
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, ok, true)
+
+	data, err := os.ReadFile(savFile)
+	testutil.Equal(t, err, nil)
+	testutil.Equal(t, bytes.Equal(data, bytes.Repeat([]byte{0xAA}, 0x2000)), true)
+}
@@ -23,6 +23,8 @@ type Window struct {
 	ResyncDelegate func()
 	ResetDelegate  func()
 	RewindDelegate func()
+	DiskDelegate   func()
+	RenderDelegate func()
 	ShowPing       bool
 	ShowFPS        bool
 	FPS            int
@@ -81,6 +83,19 @@ func (w *Window) EnableCRT() {
 	w.shader = newShader(shaders.ScanlineFragment)
 }
 
+// SetFrameSize changes the size of the frames passed to Refresh, for the video
+// filters that output more pixels than the PPU. The frame is stretched to
+// displayWidth pixels (before scaling), to keep the aspect ratio.
+func (w *Window) SetFrameSize(width, height, displayWidth int) {
+	rl.UnloadRenderTexture(w.viewport)
+	w.viewport = rl.LoadRenderTexture(int32(width), int32(height))
+	rl.SetTextureFilter(w.viewport.Texture, rl.FilterBilinear)
+
+	w.width = displayWidth * w.scale
+	w.height = height * w.scale
+	rl.SetWindowSize(w.width, w.height)
+}
+
 func (w *Window) SetTitle(title string) {
 	rl.SetWindowTitle(title)
 }
@@ -218,6 +233,16 @@ func (w *Window) HandleHotKeys() {
 			w.ResetDelegate()
 		}
 
+	case w.isModifierPressed() && rl.IsKeyPressed(rl.KeyD):
+		if w.DiskDelegate != nil {
+			w.DiskDelegate()
+		}
+
+	case w.isModifierPressed() && rl.IsKeyPressed(rl.KeyP):
+		if w.RenderDelegate != nil {
+			w.RenderDelegate()
+		}
+
 	case w.isModifierPressed() && rl.IsKeyPressed(rl.KeyX):
 		if w.ResyncDelegate != nil {
 			w.ResyncDelegate()
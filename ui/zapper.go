@@ -1,8 +1,6 @@
 package ui
 
 import (
-	"image/color"
-
 	"github.com/gen2brain/raylib-go/raylib"
 
 	"github.com/maxpoletaev/dendy/ppu"
@@ -11,12 +9,12 @@ import (
 func (w *Window) getFrameMousePosition() (int, int, bool) {
 	pos := rl.GetMousePosition()
 
-	x := int(pos.X) / w.scale
+	x := int(pos.X) * ppu.FrameWidth / w.width
 	if x < 0 || x >= ppu.FrameWidth {
 		return 0, 0, false
 	}
 
-	y := int(pos.Y) / w.scale
+	y := int(pos.Y) * ppu.FrameHeight / w.height
 	if y < 0 || y >= ppu.FrameHeight {
 		return 0, 0, false
 	}
@@ -29,7 +27,11 @@ func (w *Window) isTriggerPressed() bool {
 		rl.IsMouseButtonPressed(rl.MouseLeftButton)
 }
 
-func (w *Window) UpdateZapper(ppuFrame []color.RGBA) {
+// zapperPalette is used to tell how bright the pixel under the cursor is, no
+// matter how the frame is displayed.
+var zapperPalette = ppu.DefaultPalette()
+
+func (w *Window) UpdateZapper(ppuFrame []uint16) {
 	if w.ZapperDelegate == nil {
 		return
 	}
@@ -44,7 +46,7 @@ func (w *Window) UpdateZapper(ppuFrame []color.RGBA) {
 		return
 	}
 
-	rgb := ppuFrame[y*ppu.FrameWidth+x]
+	rgb := zapperPalette[ppuFrame[y*ppu.FrameWidth+x]&0x1FF]
 
 	brightness := (rgb.R + rgb.G + rgb.B) / 3
 
@@ -12,21 +12,60 @@ const wasmReady = WebAssembly.instantiateStreaming(fetch("dendy.wasm"), go.impor
 });
 
 Promise.all([wasmReady, documentReady]).then(async () => {
-  const WIDTH = 256;
-  const HEIGHT = 240;
-  const TARGET_FPS = 60;
-
   // ========================
   // Canvas setup
   // ========================
 
   let canvas = document.getElementById("canvas");
-  canvas.width = WIDTH;
-  canvas.height = HEIGHT;
-  canvas.style.imageRendering = "pixelated";
-
   let ctx = canvas.getContext("2d");
-  ctx.imageSmoothingEnabled = false;
+
+  function resizeCanvas() {
+    let width = go.GetFrameWidth();
+    let height = go.GetFrameHeight();
+    let ntsc = width !== 256;
+
+    canvas.width = width;
+    canvas.height = height;
+
+    // The NTSC filter output is twice as wide and has the 8:7 pixel aspect ratio built in.
+    canvas.style.aspectRatio = ntsc ? `${width / 2} / ${height}` : `${width} / ${height}`;
+    canvas.style.imageRendering = ntsc ? "auto" : "pixelated";
+    ctx.imageSmoothingEnabled = false;
+  }
+
+  resizeCanvas();
+
+  // ========================
+  //  NTSC filter
+  // ========================
+
+  let ntscCheckbox = document.getElementById("ntsc-checkbox");
+
+  ntscCheckbox.addEventListener("change", function () {
+    go.SetNTSCFilter(this.checked);
+    resizeCanvas();
+    this.blur();
+  });
+
+  if (ntscCheckbox.checked) {
+    go.SetNTSCFilter(true);
+    resizeCanvas();
+  }
+
+  // ========================
+  //  Dot renderer
+  // ========================
+
+  let dotCheckbox = document.getElementById("dot-checkbox");
+
+  dotCheckbox.addEventListener("change", function () {
+    go.SetDotRendering(this.checked);
+    this.blur();
+  });
+
+  if (dotCheckbox.checked) {
+    go.SetDotRendering(true);
+  }
 
   // ========================
   //  Audio setup
@@ -218,6 +257,21 @@ Promise.all([wasmReady, documentReady]).then(async () => {
     fileInput.dispatchEvent(new Event("input"));
   });
 
+  // ========================
+  //  Battery saves
+  // ========================
+
+  const BATTERY_FLUSH_INTERVAL = 5000;
+
+  setInterval(() => go.FlushBatteryRAM(), BATTERY_FLUSH_INTERVAL);
+  window.addEventListener("pagehide", () => go.FlushBatteryRAM());
+
+  document.addEventListener("visibilitychange", () => {
+    if (document.visibilityState === "hidden") {
+      go.FlushBatteryRAM();
+    }
+  });
+
   // ========================
   //  Game loop
   // ========================
@@ -237,7 +291,8 @@ Promise.all([wasmReady, documentReady]).then(async () => {
 
       if (frameReady) {
         let framePtr = go.GetFrameBufferPtr();
-        let image = new ImageData(new Uint8ClampedArray(getMemoryBuffer(), framePtr, WIDTH * HEIGHT * 4), WIDTH, HEIGHT);
+        let width = canvas.width, height = canvas.height;
+        let image = new ImageData(new Uint8ClampedArray(getMemoryBuffer(), framePtr, width * height * 4), width, height);
         ctx.putImageData(image, 0, 0);
         return;
       }
@@ -249,11 +304,11 @@ Promise.all([wasmReady, documentReady]).then(async () => {
   }
 
   let lastFrameTime = performance.now();
-  const frameTime = 1000 / TARGET_FPS;
 
   function loop() {
     requestAnimationFrame(loop);
 
+    const frameTime = 1000 / go.GetFrameRate(); // 60 for NTSC, 50 for PAL/Dendy
     const now = performance.now();
     const elapsed = now - lastFrameTime;
     if (elapsed < frameTime) return;